@@ -0,0 +1,566 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"lxdweb/config"
+	"lxdweb/database"
+	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+	"lxdweb/services"
+	"lxdweb/utils"
+
+	"gorm.io/gorm"
+)
+
+const cliUsage = `用法: lxdweb [命令] [参数]
+
+不带命令时启动 Web 服务。
+
+命令:
+  serve                                 启动 Web 服务
+  admin reset-password <用户名>          重置管理员密码，解除登录锁定并吊销全部会话
+  admin create --username <用户名>       创建管理员
+  node add --name <名称> --address <地址> --api-key <密钥>
+                                        添加节点
+  node list                             列出节点
+  node test <ID|名称>                    测试节点连接
+  sync run --node <ID|名称> | --all      立即同步节点容器
+  db backup [--output <文件>]            在线备份数据库
+  secrets rotate-key                    生成新主密钥并重新加密全部节点 API 密钥
+
+使用 "lxdweb <命令> <子命令> -h" 查看参数说明。
+`
+
+// runCLI 执行命令行子命令，返回进程退出码。命令行复用配置、数据库和服务层，不启动 HTTP 服务
+func runCLI(args []string) int {
+	var err error
+	switch cmd := strings.Join(args[:min(2, len(args))], " "); {
+	case args[0] == "help" || args[0] == "-h" || args[0] == "--help":
+		fmt.Print(cliUsage)
+		return 0
+	case cmd == "admin reset-password":
+		err = cliAdminResetPassword(args[2:])
+	case cmd == "admin create":
+		err = cliAdminCreate(args[2:])
+	case cmd == "node add":
+		err = cliNodeAdd(args[2:])
+	case cmd == "node list":
+		err = cliNodeList(args[2:])
+	case cmd == "node test":
+		err = cliNodeTest(args[2:])
+	case cmd == "sync run":
+		err = cliSyncRun(args[2:])
+	case cmd == "db backup":
+		err = cliDBBackup(args[2:])
+	case cmd == "secrets rotate-key":
+		err = cliSecretsRotateKey(args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n\n%s", strings.Join(args, " "), cliUsage)
+		return 2
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// initCLI 加载配置并连接数据库，运行日志输出到标准错误，命令结果输出到标准输出
+func initCLI() {
+	initRuntime()
+	database.InitDB()
+	initSecrets()
+}
+
+func cliAdminResetPassword(args []string) error {
+	fs := flag.NewFlagSet("admin reset-password", flag.ContinueOnError)
+	password := fs.String("password", "", "新密码，留空则随机生成")
+	passwordStdin := fs.Bool("password-stdin", false, "从标准输入读取新密码")
+	noForceChange := fs.Bool("no-force-change", false, "登录后不要求修改密码")
+	reset2FA := fs.Bool("reset-2fa", false, "同时关闭两步验证")
+	username, err := parseWithArg(fs, args, "用户名")
+	if err != nil {
+		return err
+	}
+
+	initCLI()
+	var admin models.Admin
+	if err := database.DB.Where("username = ?", username).First(&admin).Error; err != nil {
+		return fmt.Errorf("管理员 %s 不存在", username)
+	}
+
+	plain, generated, err := resolvePassword(*password, *passwordStdin, admin.Username)
+	if err != nil {
+		return err
+	}
+	admin.Password = plain
+	if err := admin.HashPassword(); err != nil {
+		return fmt.Errorf("密码加密失败: %v", err)
+	}
+	err = database.DB.Model(&admin).Updates(map[string]interface{}{
+		"password":             admin.Password,
+		"must_change_password": !*noForceChange,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("更新密码失败: %v", err)
+	}
+
+	if *reset2FA && admin.TOTPEnabled {
+		if err := services.DisableTwoFactor(admin.ID); err != nil {
+			return fmt.Errorf("关闭两步验证失败: %v", err)
+		}
+		fmt.Println("已关闭两步验证")
+	}
+	var lockouts []models.LoginLockout
+	database.DB.Where("scope = ? AND value = ? AND locked_until > ?", models.LockoutScopeUser, admin.Username, time.Now()).Find(&lockouts)
+	for i := range lockouts {
+		services.UnlockLogin(&lockouts[i], "cli")
+	}
+	revoked := services.RevokeAdminSessions(admin.ID, "")
+
+	fmt.Printf("管理员 %s 的密码已重置，吊销会话 %d 个\n", admin.Username, revoked)
+	if generated {
+		fmt.Printf("新密码: %s\n", plain)
+	}
+	if !*noForceChange {
+		fmt.Println("登录后需修改密码")
+	}
+	return nil
+}
+
+func cliAdminCreate(args []string) error {
+	fs := flag.NewFlagSet("admin create", flag.ContinueOnError)
+	username := fs.String("username", "", "用户名（必填）")
+	role := fs.String("role", models.RoleOwner, "角色: owner | operator | readonly")
+	email := fs.String("email", "", "邮箱")
+	password := fs.String("password", "", "密码，留空则随机生成")
+	passwordStdin := fs.Bool("password-stdin", false, "从标准输入读取密码")
+	noForceChange := fs.Bool("no-force-change", false, "登录后不要求修改密码")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	name := strings.TrimSpace(*username)
+	if name == "" {
+		return errors.New("请指定 --username")
+	}
+	if !models.ValidRole(*role) {
+		return errors.New("角色无效，可选 owner、operator、readonly")
+	}
+
+	initCLI()
+	var count int64
+	database.DB.Unscoped().Model(&models.Admin{}).Where("username = ?", name).Count(&count)
+	if count > 0 {
+		return errors.New("用户名已存在")
+	}
+
+	plain, generated, err := resolvePassword(*password, *passwordStdin, name)
+	if err != nil {
+		return err
+	}
+	admin := models.Admin{
+		Username:           name,
+		Password:           plain,
+		Email:              *email,
+		Role:               *role,
+		MustChangePassword: !*noForceChange,
+	}
+	if err := admin.HashPassword(); err != nil {
+		return fmt.Errorf("密码加密失败: %v", err)
+	}
+	if err := database.DB.Create(&admin).Error; err != nil {
+		return fmt.Errorf("创建失败: %v", err)
+	}
+
+	fmt.Printf("管理员 %s 创建成功，ID: %d，角色: %s\n", admin.Username, admin.ID, admin.Role)
+	if generated {
+		fmt.Printf("初始密码: %s\n", plain)
+	}
+	return nil
+}
+
+func cliNodeAdd(args []string) error {
+	fs := flag.NewFlagSet("node add", flag.ContinueOnError)
+	name := fs.String("name", "", "节点名称（必填）")
+	address := fs.String("address", "", "lxdapi 地址，如 https://1.2.3.4:8443（必填）")
+	apiKey := fs.String("api-key", "", "lxdapi 的 API 密钥（必填）")
+	description := fs.String("description", "", "描述")
+	tlsMode := fs.String("tls-mode", "", "TLS 信任模式: pin | ca | system，https 地址默认 pin，http 地址不可设置")
+	fingerprint := fs.String("fingerprint", "", "证书 SHA256 指纹，pin 模式留空则添加时获取并固定当前证书")
+	caFile := fs.String("ca-file", "", "ca 模式使用的 CA 证书文件")
+	autoSync := fs.Bool("auto-sync", true, "自动同步容器")
+	syncInterval := fs.Int("sync-interval", 300, "自动同步间隔（秒）")
+	batchSize := fs.Int("batch-size", 5, "每批同步数量")
+	batchInterval := fs.Int("batch-interval", 5, "批次间隔（秒）")
+	test := fs.Bool("test", false, "添加后立即测试连接")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" || *address == "" || *apiKey == "" {
+		return errors.New("请指定 --name、--address 和 --api-key")
+	}
+
+	var caCert string
+	if *caFile != "" {
+		data, err := os.ReadFile(*caFile)
+		if err != nil {
+			return fmt.Errorf("读取 CA 证书失败: %v", err)
+		}
+		caCert = string(data)
+	}
+	mode, fp, err := services.NormalizeNodeTrust(*address, *tlsMode, *fingerprint, caCert)
+	if err != nil {
+		return err
+	}
+
+	initCLI()
+	var count int64
+	database.DB.Unscoped().Model(&models.Node{}).Where("name = ?", *name).Count(&count)
+	if count > 0 {
+		return errors.New("节点名称已存在")
+	}
+
+	encrypted, err := services.EncryptSecret(*apiKey)
+	if err != nil {
+		return fmt.Errorf("加密 API 密钥失败: %v", err)
+	}
+	node := models.Node{
+		Name:           *name,
+		Description:    *description,
+		Address:        strings.TrimRight(*address, "/"),
+		APIKey:         encrypted,
+		Status:         "inactive",
+		AutoSync:       *autoSync,
+		SyncInterval:   positiveOr(*syncInterval, 300),
+		BatchSize:      positiveOr(*batchSize, 5),
+		BatchInterval:  positiveOr(*batchInterval, 5),
+		TLSMode:        mode,
+		TLSFingerprint: fp,
+		TLSCACert:      caCert,
+	}
+	if err := database.DB.Create(&node).Error; err != nil {
+		return fmt.Errorf("创建失败: %v", err)
+	}
+	fmt.Printf("节点 %s 添加成功，ID: %d\n", node.Name, node.ID)
+
+	if *test {
+		return testNode(node)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pinned, err := services.PinNodeFingerprint(ctx, &node)
+	if err != nil {
+		return fmt.Errorf("获取节点证书指纹失败，节点确认指纹前不会同步: %v", err)
+	}
+	if pinned {
+		fmt.Printf("已固定证书指纹: %s\n", node.TLSFingerprint)
+	}
+	return nil
+}
+
+func cliNodeList(args []string) error {
+	fs := flag.NewFlagSet("node list", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	initCLI()
+	var nodes []models.Node
+	database.DB.Order("id").Find(&nodes)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\t名称\t地址\t状态\tTLS\t自动同步\t最近检查")
+	for _, n := range nodes {
+		lastCheck := "-"
+		if n.LastCheck != nil {
+			lastCheck = n.LastCheck.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%t\t%s\n",
+			n.ID, n.Name, n.Address, n.Status, services.NodeTrust(n).Mode, n.AutoSync, lastCheck)
+	}
+	return w.Flush()
+}
+
+func cliNodeTest(args []string) error {
+	fs := flag.NewFlagSet("node test", flag.ContinueOnError)
+	ref, err := parseWithArg(fs, args, "节点ID或名称")
+	if err != nil {
+		return err
+	}
+
+	initCLI()
+	node, err := findNode(ref)
+	if err != nil {
+		return err
+	}
+	return testNode(*node)
+}
+
+func testNode(node models.Node) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	trust, pinned, err := services.TestNodeConnection(ctx, node)
+	if err != nil {
+		if mismatch, ok := lxdapi.AsFingerprintMismatch(err); ok {
+			return fmt.Errorf("节点证书已变更，拒绝连接。记录的指纹: %s，当前证书指纹: %s", mismatch.Expected, mismatch.Actual)
+		}
+		if apiErr, ok := lxdapi.AsAPIError(err); ok {
+			return fmt.Errorf("连接失败: %s", apiErr.Msg)
+		}
+		return fmt.Errorf("连接失败: %v", err)
+	}
+
+	fmt.Printf("节点 %s 连接成功，TLS 模式: %s\n", node.Name, trust.Mode)
+	if pinned {
+		fmt.Printf("已固定证书指纹: %s\n", trust.Fingerprint)
+	}
+	if err := services.RefreshNodeCache(node.ID); err != nil {
+		fmt.Fprintf(os.Stderr, "刷新节点信息失败: %v\n", err)
+	}
+	return nil
+}
+
+func cliSyncRun(args []string) error {
+	fs := flag.NewFlagSet("sync run", flag.ContinueOnError)
+	ref := fs.String("node", "", "节点ID或名称")
+	all := fs.Bool("all", false, "同步全部活动节点")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*ref == "") == !*all {
+		return errors.New("请指定 --node 或 --all 其中之一")
+	}
+
+	initCLI()
+	var nodes []models.Node
+	if *all {
+		database.DB.Where("status IN ?", []string{"active", "degraded"}).Order("id").Find(&nodes)
+		if len(nodes) == 0 {
+			fmt.Println("没有活动节点")
+			return nil
+		}
+	} else {
+		node, err := findNode(*ref)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, *node)
+	}
+
+	failed := 0
+	for _, node := range nodes {
+		fmt.Printf("正在同步节点 %s (ID: %d)...\n", node.Name, node.ID)
+		if err := services.SyncNodeContainers(node.ID, true); err != nil {
+			fmt.Fprintf(os.Stderr, "  同步失败: %v\n", err)
+			failed++
+			continue
+		}
+		var task models.SyncTask
+		if database.DB.Where("node_id = ?", node.ID).Order("id desc").First(&task).Error == nil {
+			fmt.Printf("  %s: 共 %d 个容器，成功 %d，失败 %d\n", task.Status, task.TotalCount, task.SuccessCount, task.FailedCount)
+			if task.ErrorMessage != "" {
+				fmt.Printf("  %s\n", task.ErrorMessage)
+			}
+			if task.Status == "failed" {
+				failed++
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个节点同步失败", failed)
+	}
+	return nil
+}
+
+func cliDBBackup(args []string) error {
+	fs := flag.NewFlagSet("db backup", flag.ContinueOnError)
+	output := fs.String("output", "", "备份文件路径，默认在数据库所在目录生成带时间戳的文件")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	initCLI()
+	path := *output
+	if path == "" {
+		dbPath := config.AppConfig.Database.Path
+		base := strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+		path = filepath.Join(filepath.Dir(dbPath), fmt.Sprintf("%s-backup-%s.db", base, time.Now().Format("20060102-150405")))
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("文件 %s 已存在", path)
+	}
+
+	// VACUUM INTO 生成一致的快照，Web 服务运行时也可以执行
+	if err := database.DB.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return fmt.Errorf("备份失败: %v", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("设置备份文件权限失败: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("数据库已备份到 %s (%d 字节)\n", path, info.Size())
+	return nil
+}
+
+func cliSecretsRotateKey(args []string) error {
+	fs := flag.NewFlagSet("secrets rotate-key", flag.ContinueOnError)
+	output := fs.String("output", "", "新主密钥的保存路径，主密钥来自环境变量时必填")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	initCLI()
+	keyFile := config.AppConfig.Secrets.MasterKeyFile
+	fromEnv := services.MasterKeyFromEnv()
+	newPath := *output
+	if newPath == "" {
+		if fromEnv {
+			return fmt.Errorf("主密钥来自环境变量 %s，请用 --output 指定新密钥的保存路径", config.AppConfig.Secrets.MasterKeyEnv)
+		}
+		newPath = keyFile + ".new"
+	}
+	// 新密钥不写回当前密钥文件时，运行中的服务在重启前一直使用旧密钥加密，轮换后写入的数据将无法解密
+	if (fromEnv || *output != "") && serverRunning() {
+		return fmt.Errorf("lxdweb 服务正在运行 (%s)，新主密钥需要修改配置并重启后才能生效，请先停止服务再轮换", config.AppConfig.Server.Address)
+	}
+
+	newKey, err := services.GenerateMasterKey()
+	if err != nil {
+		return err
+	}
+	// 先写入新密钥再重新加密，任何一步失败都不会丢失可用的密钥
+	if err := services.WriteMasterKeyFile(newPath, newKey); err != nil {
+		return fmt.Errorf("写入新主密钥失败: %v", err)
+	}
+	count, keyID, err := services.RotateMasterKey(newKey)
+	if err != nil {
+		os.Remove(newPath)
+		return fmt.Errorf("重新加密失败，数据未改变: %v", err)
+	}
+	fmt.Printf("已使用新主密钥 (ID: %s) 重新加密 %d 个节点的 API 密钥\n", keyID, count)
+
+	if fromEnv || *output != "" {
+		fmt.Printf("新主密钥已保存到 %s，请更新主密钥配置后再启动 lxdweb 服务\n", newPath)
+		return nil
+	}
+	// 旧密钥只用于解密轮换前的数据库备份，确认无需后请删除
+	oldPath := fmt.Sprintf("%s.%s.old", keyFile, time.Now().Format("20060102-150405"))
+	if err := os.Rename(keyFile, oldPath); err != nil {
+		return fmt.Errorf("备份旧主密钥失败，新主密钥位于 %s，请手动替换 %s: %v", newPath, keyFile, err)
+	}
+	if err := os.Rename(newPath, keyFile); err != nil {
+		return fmt.Errorf("替换主密钥失败，新主密钥位于 %s，请手动替换 %s: %v", newPath, keyFile, err)
+	}
+	fmt.Printf("主密钥文件 %s 已更新，旧密钥保存为 %s，仅用于解密轮换前的数据库备份\n", keyFile, oldPath)
+
+	// 替换密钥文件前运行中的服务可能仍用旧密钥写入了节点 API 密钥
+	swept, err := services.ReencryptPreviousKeySecrets()
+	if err != nil {
+		return fmt.Errorf("重新加密轮换期间写入的 API 密钥失败，请重启 lxdweb 服务后重新执行轮换: %v", err)
+	}
+	if swept > 0 {
+		fmt.Printf("已重新加密轮换期间写入的 %d 个节点 API 密钥\n", swept)
+	}
+	if serverRunning() {
+		fmt.Println("运行中的 lxdweb 服务会在下次读写 API 密钥时自动加载新主密钥，建议在业务低峰期重启服务以确保完全生效")
+	}
+	return nil
+}
+
+// serverRunning 尝试连接配置的监听地址，判断 Web 服务是否正在运行
+func serverRunning() bool {
+	host, port, err := net.SplitHostPort(config.AppConfig.Server.Address)
+	if err != nil {
+		return false
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// parseWithArg 解析参数并返回一个必填的位置参数，位置参数可以写在选项前面
+func parseWithArg(fs *flag.FlagSet, args []string, name string) (string, error) {
+	var positional string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		positional, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if positional == "" && fs.NArg() > 0 {
+		positional = fs.Arg(0)
+	}
+	if positional == "" {
+		return "", fmt.Errorf("请指定%s", name)
+	}
+	return positional, nil
+}
+
+// resolvePassword 返回命令行指定、标准输入读取或随机生成的密码，指定的密码需符合密码策略
+func resolvePassword(password string, fromStdin bool, username string) (string, bool, error) {
+	if fromStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", false, fmt.Errorf("读取密码失败: %v", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if password == "" {
+		generated, err := utils.GeneratePassword(20)
+		return generated, true, err
+	}
+	if err := services.ValidatePassword(password, username); err != nil {
+		return "", false, err
+	}
+	return password, false, nil
+}
+
+// findNode 按 ID 或名称查找节点
+func findNode(ref string) (*models.Node, error) {
+	var node models.Node
+	query := database.DB.Where("name = ?", ref)
+	if id, err := strconv.ParseUint(ref, 10, 32); err == nil {
+		query = database.DB.Where("id = ? OR name = ?", id, ref)
+	}
+	if err := query.First(&node).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("节点 %s 不存在", ref)
+		}
+		return nil, err
+	}
+	return &node, nil
+}
+
+func positiveOr(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
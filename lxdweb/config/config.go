@@ -7,17 +7,28 @@ import (
 	"gopkg.in/yaml.v3"
 )
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Admin    AdminConfig    `yaml:"admin"`
-	Database DatabaseConfig `yaml:"database"`
-	Sync     SyncConfig     `yaml:"sync"`
-	Logging  LoggingConfig  `yaml:"logging"`
+	Server         ServerConfig         `yaml:"server"`
+	Session        SessionConfig        `yaml:"session"`
+	Admin          AdminConfig          `yaml:"admin"`
+	Database       DatabaseConfig       `yaml:"database"`
+	Secrets        SecretsConfig        `yaml:"secrets"`
+	Sync           SyncConfig           `yaml:"sync"`
+	Node           NodeConfig           `yaml:"node"`
+	Health         HealthConfig         `yaml:"health"`
+	Metrics        MetricsConfig        `yaml:"metrics"`
+	Quota          QuotaConfig          `yaml:"quota"`
+	Login          LoginConfig          `yaml:"login"`
+	OIDC           OIDCConfig           `yaml:"oidc"`
+	PasswordPolicy PasswordPolicyConfig `yaml:"password_policy"`
+	Prometheus     PrometheusConfig     `yaml:"prometheus"`
+	Logging        LoggingConfig        `yaml:"logging"`
 }
 
 type AdminConfig struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-	Email    string `yaml:"email"`
+	Username            string `yaml:"username"`
+	Password            string `yaml:"password"`
+	Email               string `yaml:"email"`
+	InitialPasswordFile string `yaml:"initial_password_file"`
 }
 type ServerConfig struct {
 	Address       string `yaml:"address"`
@@ -27,14 +38,89 @@ type ServerConfig struct {
 	CertFile      string `yaml:"cert_file"`
 	KeyFile       string `yaml:"key_file"`
 }
+type SessionConfig struct {
+	IdleTimeout     int    `yaml:"idle_timeout"`
+	AbsoluteTimeout int    `yaml:"absolute_timeout"`
+	CookieSecure    *bool  `yaml:"cookie_secure"`
+	CookieHTTPOnly  *bool  `yaml:"cookie_http_only"`
+	CookieSameSite  string `yaml:"cookie_same_site"`
+}
 type DatabaseConfig struct {
 	Path string `yaml:"path"`
 }
+type SecretsConfig struct {
+	MasterKeyFile string `yaml:"master_key_file"`
+	MasterKeyEnv  string `yaml:"master_key_env"`
+}
 type SyncConfig struct {
 	Interval      int `yaml:"interval"`
 	BatchSize     int `yaml:"batch_size"`
 	BatchInterval int `yaml:"batch_interval"`
 }
+type NodeConfig struct {
+	MaxConcurrent       int `yaml:"max_concurrent"`
+	InteractiveReserved int `yaml:"interactive_reserved"`
+	IdleConnTimeout     int `yaml:"idle_conn_timeout"`
+	BreakerThreshold    int `yaml:"breaker_threshold"`
+	BreakerCooldown     int `yaml:"breaker_cooldown"`
+	RetryAttempts       int `yaml:"retry_attempts"`
+	RetryBackoff        int `yaml:"retry_backoff"`
+}
+type HealthConfig struct {
+	Interval         int `yaml:"interval"`
+	Timeout          int `yaml:"timeout"`
+	DegradedLatency  int `yaml:"degraded_latency"`
+	FailThreshold    int `yaml:"fail_threshold"`
+	RecoverThreshold int `yaml:"recover_threshold"`
+	Retention        int `yaml:"retention"`
+}
+type MetricsConfig struct {
+	RawRetention int             `yaml:"raw_retention"`
+	Rollups      []MetricsRollup `yaml:"rollups"`
+}
+type MetricsRollup struct {
+	Step      int `yaml:"step"`
+	Retention int `yaml:"retention"`
+}
+type QuotaConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	Interval        int    `yaml:"interval"`
+	AnchorDay       int    `yaml:"anchor_day"`
+	WarnThresholds  []int  `yaml:"warn_thresholds"`
+	OverQuotaAction string `yaml:"over_quota_action"`
+	EventRetention  int    `yaml:"event_retention"`
+}
+type LoginConfig struct {
+	Window          int `yaml:"window"`
+	MaxUserFailures int `yaml:"max_user_failures"`
+	MaxIPFailures   int `yaml:"max_ip_failures"`
+	LockoutDuration int `yaml:"lockout_duration"`
+	Retention       int `yaml:"retention"`
+}
+type PasswordPolicyConfig struct {
+	MinLength  int `yaml:"min_length"`
+	MinClasses int `yaml:"min_classes"`
+}
+type OIDCConfig struct {
+	Enabled        bool     `yaml:"enabled"`
+	Issuer         string   `yaml:"issuer"`
+	ClientID       string   `yaml:"client_id"`
+	ClientSecret   string   `yaml:"client_secret"`
+	RedirectURL    string   `yaml:"redirect_url"`
+	Scopes         []string `yaml:"scopes"`
+	ButtonText     string   `yaml:"button_text"`
+	UsernameClaim  string   `yaml:"username_claim"`
+	GroupsClaim    string   `yaml:"groups_claim"`
+	OwnerGroups    []string `yaml:"owner_groups"`
+	OperatorGroups []string `yaml:"operator_groups"`
+	ReadOnlyGroups []string `yaml:"readonly_groups"`
+	DefaultRole    string   `yaml:"default_role"`
+	AutoProvision  *bool    `yaml:"auto_provision"`
+}
+type PrometheusConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Token   string `yaml:"token"`
+}
 type LoggingConfig struct {
 	Level      string `yaml:"level"`
 	File       string `yaml:"file"`
@@ -75,17 +161,46 @@ func LoadConfig() error {
 	if AppConfig.Server.KeyFile == "" {
 		AppConfig.Server.KeyFile = "key.pem"
 	}
+	if AppConfig.Session.IdleTimeout <= 0 {
+		AppConfig.Session.IdleTimeout = 1800
+	}
+	if AppConfig.Session.AbsoluteTimeout <= 0 {
+		AppConfig.Session.AbsoluteTimeout = 43200
+	}
+	if AppConfig.Session.CookieSecure == nil {
+		secure := AppConfig.Server.EnableHTTPS
+		AppConfig.Session.CookieSecure = &secure
+	}
+	if AppConfig.Session.CookieHTTPOnly == nil {
+		httpOnly := true
+		AppConfig.Session.CookieHTTPOnly = &httpOnly
+	}
+	if AppConfig.Session.CookieSameSite == "" {
+		AppConfig.Session.CookieSameSite = "lax"
+	}
 	
 	if AppConfig.Admin.Username == "" {
 		AppConfig.Admin.Username = "admin"
 	}
-	if AppConfig.Admin.Password == "" {
-		AppConfig.Admin.Password = "admin123"
+	if AppConfig.Admin.InitialPasswordFile == "" {
+		AppConfig.Admin.InitialPasswordFile = "initial_admin_password.txt"
+	}
+	if AppConfig.PasswordPolicy.MinLength <= 0 {
+		AppConfig.PasswordPolicy.MinLength = 10
+	}
+	if AppConfig.PasswordPolicy.MinClasses <= 0 || AppConfig.PasswordPolicy.MinClasses > 4 {
+		AppConfig.PasswordPolicy.MinClasses = 3
 	}
 	
 	if AppConfig.Database.Path == "" {
 		AppConfig.Database.Path = "lxdweb.db"
 	}
+	if AppConfig.Secrets.MasterKeyFile == "" {
+		AppConfig.Secrets.MasterKeyFile = "master.key"
+	}
+	if AppConfig.Secrets.MasterKeyEnv == "" {
+		AppConfig.Secrets.MasterKeyEnv = "LXDWEB_MASTER_KEY"
+	}
 	if AppConfig.Sync.Interval <= 0 {
 		AppConfig.Sync.Interval = 300  
 	}
@@ -95,6 +210,100 @@ func LoadConfig() error {
 	if AppConfig.Sync.BatchInterval <= 0 {
 		AppConfig.Sync.BatchInterval = 2
 	}
+	if AppConfig.Node.MaxConcurrent <= 0 {
+		AppConfig.Node.MaxConcurrent = 8
+	}
+	if AppConfig.Node.InteractiveReserved <= 0 {
+		AppConfig.Node.InteractiveReserved = 2
+	}
+	if AppConfig.Node.IdleConnTimeout <= 0 {
+		AppConfig.Node.IdleConnTimeout = 90
+	}
+	if AppConfig.Node.BreakerThreshold <= 0 {
+		AppConfig.Node.BreakerThreshold = 5
+	}
+	if AppConfig.Node.BreakerCooldown <= 0 {
+		AppConfig.Node.BreakerCooldown = 60
+	}
+	if AppConfig.Node.RetryAttempts <= 0 {
+		AppConfig.Node.RetryAttempts = 3
+	}
+	if AppConfig.Node.RetryBackoff <= 0 {
+		AppConfig.Node.RetryBackoff = 500
+	}
+	if AppConfig.Health.Interval <= 0 {
+		AppConfig.Health.Interval = 30
+	}
+	if AppConfig.Health.Timeout <= 0 {
+		AppConfig.Health.Timeout = 5
+	}
+	if AppConfig.Health.DegradedLatency <= 0 {
+		AppConfig.Health.DegradedLatency = 1500
+	}
+	if AppConfig.Health.FailThreshold <= 0 {
+		AppConfig.Health.FailThreshold = 3
+	}
+	if AppConfig.Health.RecoverThreshold <= 0 {
+		AppConfig.Health.RecoverThreshold = 2
+	}
+	if AppConfig.Health.Retention <= 0 {
+		AppConfig.Health.Retention = 7
+	}
+	if AppConfig.Metrics.RawRetention <= 0 {
+		AppConfig.Metrics.RawRetention = 24
+	}
+	if len(AppConfig.Metrics.Rollups) == 0 {
+		AppConfig.Metrics.Rollups = []MetricsRollup{
+			{Step: 300, Retention: 168},
+			{Step: 3600, Retention: 2160},
+		}
+	}
+	if AppConfig.Quota.Interval <= 0 {
+		AppConfig.Quota.Interval = 300
+	}
+	if AppConfig.Quota.AnchorDay <= 0 {
+		AppConfig.Quota.AnchorDay = 1
+	}
+	if len(AppConfig.Quota.WarnThresholds) == 0 {
+		AppConfig.Quota.WarnThresholds = []int{80, 100}
+	}
+	if AppConfig.Quota.OverQuotaAction == "" {
+		AppConfig.Quota.OverQuotaAction = "none"
+	}
+	if AppConfig.Quota.EventRetention <= 0 {
+		AppConfig.Quota.EventRetention = 90
+	}
+	if AppConfig.Login.Window <= 0 {
+		AppConfig.Login.Window = 900
+	}
+	if AppConfig.Login.MaxUserFailures <= 0 {
+		AppConfig.Login.MaxUserFailures = 5
+	}
+	if AppConfig.Login.MaxIPFailures <= 0 {
+		AppConfig.Login.MaxIPFailures = 20
+	}
+	if AppConfig.Login.LockoutDuration <= 0 {
+		AppConfig.Login.LockoutDuration = 900
+	}
+	if AppConfig.Login.Retention <= 0 {
+		AppConfig.Login.Retention = 30
+	}
+	if len(AppConfig.OIDC.Scopes) == 0 {
+		AppConfig.OIDC.Scopes = []string{"openid", "profile", "email"}
+	}
+	if AppConfig.OIDC.ButtonText == "" {
+		AppConfig.OIDC.ButtonText = "使用单点登录"
+	}
+	if AppConfig.OIDC.UsernameClaim == "" {
+		AppConfig.OIDC.UsernameClaim = "preferred_username"
+	}
+	if AppConfig.OIDC.GroupsClaim == "" {
+		AppConfig.OIDC.GroupsClaim = "groups"
+	}
+	if AppConfig.OIDC.AutoProvision == nil {
+		autoProvision := true
+		AppConfig.OIDC.AutoProvision = &autoProvision
+	}
 	if AppConfig.Logging.Level == "" {
 		AppConfig.Logging.Level = "info"
 	}
@@ -129,16 +338,43 @@ func createDefaultConfig(filename string) error {
   # 密钥文件路径
   key_file: "key.pem"
 
-# 默认管理员账号（首次启动自动创建）
+session:
+  # 无操作超时（秒），超时后需重新登录
+  idle_timeout: 1800
+  # 登录后的最长有效期（秒），到期后无论是否活跃都需重新登录
+  absolute_timeout: 43200
+  # Cookie 仅通过 HTTPS 发送，不设置时跟随 server.enable_https
+  # cookie_secure: true
+  # 禁止页面脚本读取 Cookie
+  cookie_http_only: true
+  # SameSite 策略: lax | strict | none（none 需要同时启用 cookie_secure）
+  cookie_same_site: "lax"
+
+# 默认管理员账号（首次启动自动创建，首次登录后必须修改密码）
 admin:
   username: "admin"
-  password: "admin123"
+  # 初始密码，留空则随机生成并写入 initial_password_file（权限 0600），不会输出到日志
+  password: ""
   email: ""
+  initial_password_file: "initial_admin_password.txt"
+
+password_policy:
+  # 密码最小长度
+  min_length: 10
+  # 大写字母、小写字母、数字、符号中至少包含几种（1-4）
+  min_classes: 3
 
 database:
   # 数据库文件路径
   path: "lxdweb.db"
 
+secrets:
+  # 节点 API 密钥加密使用的主密钥文件（32 字节，base64 编码），不存在时自动生成，权限 0600
+  # 请与数据库分开备份，丢失后已保存的节点 API 密钥无法解密
+  master_key_file: "master.key"
+  # 设置了该环境变量时优先使用环境变量中的主密钥
+  master_key_env: "LXDWEB_MASTER_KEY"
+
 sync:
   # 同步间隔（秒）
   interval: 300
@@ -147,6 +383,103 @@ sync:
   # 批次间隔（秒）
   batch_interval: 2
 
+node:
+  # 每个节点同时进行的最大请求数（同步、缓存刷新、用户操作共用）
+  max_concurrent: 8
+  # 为用户操作保留的请求数，后台同步不会占用
+  interactive_reserved: 2
+  # 空闲连接保持时间（秒）
+  idle_conn_timeout: 90
+  # 连续失败多少次后熔断，熔断期间请求直接失败并将节点标记为异常
+  breaker_threshold: 5
+  # 熔断后多久放行一次探测请求（秒）
+  breaker_cooldown: 60
+  # 只读请求失败时的最大尝试次数
+  retry_attempts: 3
+  # 首次重试等待时间（毫秒），之后每次翻倍
+  retry_backoff: 500
+
+health:
+  # 节点健康检查间隔（秒）
+  interval: 30
+  # 单次检查超时（秒）
+  timeout: 5
+  # 响应时间超过该值（毫秒）视为降级
+  degraded_latency: 1500
+  # 连续失败多少次标记为异常
+  fail_threshold: 3
+  # 连续正常多少次后恢复
+  recover_threshold: 2
+  # 健康记录保留天数
+  retention: 7
+
+metrics:
+  # 原始采样保留时间（小时）
+  raw_retention: 24
+  # 降采样层级，step 为聚合粒度（秒，须为上一层的整数倍），retention 为保留时间（小时）
+  rollups:
+    - step: 300
+      retention: 168
+    - step: 3600
+      retention: 2160
+
+quota:
+  # 启用流量配额调度（按账单日自动重置流量、超额告警）
+  enabled: false
+  # 检查间隔（秒）
+  interval: 300
+  # 默认账单日（1-31，超过当月天数时取月末），可按容器单独设置
+  anchor_day: 1
+  # 用量达到限额百分比时告警
+  warn_thresholds: [80, 100]
+  # 超出限额后的处理: none 仅告警 | suspend 暂停容器，下个周期重置后自动恢复
+  over_quota_action: "none"
+  # 配额事件保留天数
+  event_retention: 90
+
+login:
+  # 统计登录失败次数的滑动窗口（秒）
+  window: 900
+  # 窗口内同一用户名失败次数达到该值后锁定账号
+  max_user_failures: 5
+  # 窗口内同一 IP 失败次数达到该值后锁定 IP
+  max_ip_failures: 20
+  # 锁定时长（秒），可通过接口提前解锁
+  lockout_duration: 900
+  # 登录记录保留天数
+  retention: 30
+
+oidc:
+  # 启用 OpenID Connect 单点登录，本地账号密码登录始终可用
+  enabled: false
+  # 身份提供方地址，需提供 /.well-known/openid-configuration
+  issuer: "https://sso.example.com/realms/main"
+  client_id: "lxdweb"
+  client_secret: ""
+  # 回调地址，需在身份提供方登记
+  redirect_url: "https://lxdweb.example.com/login/oidc/callback"
+  scopes: ["openid", "profile", "email", "groups"]
+  # 登录页按钮文字
+  button_text: "使用单点登录"
+  # 用作用户名的声明
+  username_claim: "preferred_username"
+  # 用户组声明，ID Token 中没有时从 userinfo 接口读取
+  groups_claim: "groups"
+  # 按用户组映射角色，同时匹配多个时取最高角色；每次登录都会按用户组更新角色
+  owner_groups: []
+  operator_groups: []
+  readonly_groups: []
+  # 未匹配任何用户组时的角色，留空则拒绝登录
+  default_role: ""
+  # 首次登录时自动创建账号
+  auto_provision: true
+
+prometheus:
+  # 启用 /metrics 指标接口
+  enabled: true
+  # 访问令牌，留空则不校验（请求头 Authorization: Bearer <token>）
+  token: ""
+
 logging:
   # 日志级别: debug | info | warn | error
   level: "info"
@@ -1,9 +1,12 @@
 package database
 import (
 	"database/sql"
+	"fmt"
 	"log"
+	"os"
 	"lxdweb/config"
 	"lxdweb/models"
+	"lxdweb/utils"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -26,12 +29,26 @@ func InitDB() {
 	}
 	err = DB.AutoMigrate(
 		&models.Admin{},
+		&models.AdminNode{},
+		&models.AdminRecoveryCode{},
+		&models.Setting{},
+		&models.APIToken{},
+		&models.AdminSession{},
 		&models.Node{},
 		&models.Container{},
 		&models.ContainerCache{},
+		&models.NATRuleCache{},
+		&models.IPv6BindingCache{},
 		&models.SyncTask{},
 		&models.NodeInfoCache{},
+		&models.NodeHealthCheck{},
+		&models.ContainerMetric{},
+		&models.TrafficLedger{},
+		&models.TrafficQuota{},
+		&models.TrafficQuotaEvent{},
 		&models.OperationLog{},
+		&models.LoginAttempt{},
+		&models.LoginLockout{},
 		&models.Image{},
 	)
 	if err != nil {
@@ -42,27 +59,82 @@ func InitDB() {
 func CheckAdminExists() {
 	var count int64
 	DB.Model(&models.Admin{}).Count(&count)
-	if count == 0 {
-		log.Printf("[WARN] 未检测到管理员账号，正在创建默认管理员...")
-		
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(config.AppConfig.Admin.Password), bcrypt.DefaultCost)
-		if err != nil {
-			log.Fatalf("[ERROR] 密码加密失败: %v", err)
+	if count > 0 {
+		flagLegacyDefaultPasswords()
+		return
+	}
+	log.Printf("[WARN] 未检测到管理员账号，正在创建默认管理员...")
+
+	password := config.AppConfig.Admin.Password
+	generated := password == ""
+	if generated {
+		var err error
+		if password, err = utils.GeneratePassword(20); err != nil {
+			log.Fatalf("[ERROR] 生成初始密码失败: %v", err)
 		}
-		
-		admin := models.Admin{
-			Username: config.AppConfig.Admin.Username,
-			Password: string(hashedPassword),
-			Email:    config.AppConfig.Admin.Email,
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Fatalf("[ERROR] 密码加密失败: %v", err)
+	}
+
+	// 先写入密码文件再创建账号，避免产生无人知道密码的管理员
+	passwordFile := config.AppConfig.Admin.InitialPasswordFile
+	if generated {
+		if err := writeInitialPassword(passwordFile, config.AppConfig.Admin.Username, password); err != nil {
+			log.Fatalf("[ERROR] 写入初始密码文件失败: %v", err)
+		}
+	}
+
+	admin := models.Admin{
+		Username:           config.AppConfig.Admin.Username,
+		Password:           string(hashedPassword),
+		Email:              config.AppConfig.Admin.Email,
+		Role:               models.RoleOwner,
+		MustChangePassword: true,
+	}
+	if err := DB.Create(&admin).Error; err != nil {
+		if generated {
+			os.Remove(passwordFile)
 		}
-		
-		if err := DB.Create(&admin).Error; err != nil {
-			log.Fatalf("[ERROR] 创建默认管理员失败: %v", err)
+		log.Fatalf("[ERROR] 创建默认管理员失败: %v", err)
+	}
+
+	log.Printf("[SUCCESS] 默认管理员创建成功，用户名: %s", admin.Username)
+	if generated {
+		log.Printf("  初始密码已写入 %s（权限 0600），登录后请删除该文件", passwordFile)
+	} else {
+		log.Printf("  使用配置文件中的初始密码")
+	}
+	log.Printf("  首次登录后必须修改密码")
+}
+
+// writeInitialPassword 将随机生成的初始密码写入仅所有者可读的文件
+func writeInitialPassword(path, username, password string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// 文件已存在时 OpenFile 不会修改权限
+	if err := f.Chmod(0600); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "username: %s\npassword: %s\n", username, password)
+	return err
+}
+
+// legacyDefaultPassword 旧版本默认管理员密码，仍在使用的账号登录后需修改密码
+const legacyDefaultPassword = "admin123"
+
+func flagLegacyDefaultPasswords() {
+	var admins []models.Admin
+	DB.Where("must_change_password = ? AND auth_source = ?", false, models.AuthSourceLocal).Find(&admins)
+	for _, admin := range admins {
+		if admin.CheckPassword(legacyDefaultPassword) {
+			DB.Model(&admin).Update("must_change_password", true)
+			log.Printf("[WARN] 管理员 %s 仍在使用默认密码，登录后需修改密码", admin.Username)
 		}
-		
-		log.Printf("[SUCCESS] 默认管理员创建成功")
-		log.Printf("  用户名: %s", config.AppConfig.Admin.Username)
-		log.Printf("  密码: %s", config.AppConfig.Admin.Password)
-		log.Printf("  请登录后及时修改密码！")
 	}
 }
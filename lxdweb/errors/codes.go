@@ -29,6 +29,9 @@ const (
 	ERR_AUTH_EXPIRED        = 5003
 	ERR_AUTH_NO_PERMISSION  = 5004
 	ERR_AUTH_INVALID_CAPTCHA = 5005
+	ERR_AUTH_2FA_REQUIRED    = 5006
+	ERR_AUTH_CSRF            = 5007
+	ERR_AUTH_PASSWORD_CHANGE = 5008
 
 	ERR_SYNC_FAILED       = 6001
 	ERR_SYNC_IN_PROGRESS  = 6002
@@ -75,6 +78,9 @@ var ErrorMessages = map[int]string{
 	ERR_AUTH_EXPIRED:         "Token expired",
 	ERR_AUTH_NO_PERMISSION:   "No permission",
 	ERR_AUTH_INVALID_CAPTCHA: "Invalid captcha",
+	ERR_AUTH_2FA_REQUIRED:    "Two-factor authentication required",
+	ERR_AUTH_CSRF:            "CSRF token invalid",
+	ERR_AUTH_PASSWORD_CHANGE: "Password change required",
 
 	ERR_SYNC_FAILED:      "Sync failed",
 	ERR_SYNC_IN_PROGRESS: "Sync already in progress",
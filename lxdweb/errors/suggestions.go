@@ -29,6 +29,9 @@ var errorSuggestions = map[int]string{
 	ERR_AUTH_EXPIRED:         "登录已过期，请重新登录",
 	ERR_AUTH_NO_PERMISSION:   "您没有权限执行此操作",
 	ERR_AUTH_INVALID_CAPTCHA: "验证码错误，请重新输入",
+	ERR_AUTH_2FA_REQUIRED:    "系统要求启用两步验证，请先在账号设置中完成绑定",
+	ERR_AUTH_CSRF:            "页面已过期，请刷新页面后重试",
+	ERR_AUTH_PASSWORD_CHANGE: "请先修改初始密码",
 
 	ERR_SYNC_FAILED:      "同步失败，请检查节点连接",
 	ERR_SYNC_IN_PROGRESS: "节点正在同步中，请稍后再试",
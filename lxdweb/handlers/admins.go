@@ -0,0 +1,472 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"lxdweb/database"
+	"lxdweb/middleware"
+	"lxdweb/models"
+	"lxdweb/services"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetAdmins 获取管理员列表
+// @Summary 获取管理员列表
+// @Description 返回全部管理员及其角色，仅 owner 可用
+// @Tags 管理员
+// @Produce json
+// @Success 200 {object} map[string]interface{} "成功返回管理员列表"
+// @Failure 403 {object} map[string]interface{} "权限不足"
+// @Router /api/admins [get]
+func GetAdmins(c *gin.Context) {
+	var admins []models.Admin
+	database.DB.Order("id asc").Find(&admins)
+	for i := range admins {
+		loadAdminNodes(&admins[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": admins,
+	})
+}
+
+// CreateAdmin 创建管理员
+// @Summary 创建管理员
+// @Description 创建新的管理员账号，角色为 owner、operator 或 readonly；node_scoped 为 true 时只能访问 node_ids 中的节点；密码需符合密码策略，must_change_password 默认为 true，首次登录后需修改密码
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Param body body object true "管理员信息(username, password, email, role, node_scoped, node_ids, must_change_password)"
+// @Success 200 {object} map[string]interface{} "创建成功"
+// @Failure 400 {object} map[string]interface{} "参数错误"
+// @Failure 403 {object} map[string]interface{} "权限不足"
+// @Router /api/admins [post]
+func CreateAdmin(c *gin.Context) {
+	var req struct {
+		Username   string `json:"username" binding:"required"`
+		Password   string `json:"password" binding:"required"`
+		Email      string `json:"email"`
+		Role       string `json:"role" binding:"required"`
+		NodeScoped bool   `json:"node_scoped"`
+		NodeIDs    []uint `json:"node_ids"`
+		// MustChangePassword 默认 true，要求首次登录后修改密码
+		MustChangePassword *bool `json:"must_change_password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	if msg := validateAdminInput(req.Role, req.Password, req.Username); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  msg,
+		})
+		return
+	}
+	if msg := validateNodeIDs(req.NodeIDs); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  msg,
+		})
+		return
+	}
+
+	var count int64
+	database.DB.Unscoped().Model(&models.Admin{}).Where("username = ?", req.Username).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "用户名已存在",
+		})
+		return
+	}
+
+	admin := models.Admin{
+		Username:           req.Username,
+		Password:           req.Password,
+		Email:              req.Email,
+		Role:               req.Role,
+		NodeScoped:         req.NodeScoped,
+		MustChangePassword: req.MustChangePassword == nil || *req.MustChangePassword,
+	}
+	if err := admin.HashPassword(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "密码加密失败",
+		})
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&admin).Error; err != nil {
+			return err
+		}
+		return saveAdminNodes(tx, admin.ID, req.NodeIDs)
+	})
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "创建失败: " + err.Error(),
+		})
+		return
+	}
+	loadAdminNodes(&admin)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "管理员创建成功",
+		"data": admin,
+	})
+}
+
+// UpdateAdmin 更新管理员
+// @Summary 更新管理员
+// @Description 修改管理员邮箱、角色、节点授权或重置密码，不能移除最后一个 owner；传入 node_ids 时整体替换授权节点；重置密码会吊销该管理员的全部会话，并默认要求其下次登录后修改密码（must_change_password）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Param id path string true "管理员ID"
+// @Param body body object true "更新字段(email, role, password, node_scoped, node_ids)"
+// @Success 200 {object} map[string]interface{} "更新成功"
+// @Failure 400 {object} map[string]interface{} "参数错误"
+// @Failure 403 {object} map[string]interface{} "权限不足"
+// @Failure 404 {object} map[string]interface{} "管理员不存在"
+// @Router /api/admins/{id} [put]
+func UpdateAdmin(c *gin.Context) {
+	var admin models.Admin
+	if err := database.DB.First(&admin, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "管理员不存在",
+		})
+		return
+	}
+
+	var req struct {
+		Email      *string `json:"email"`
+		Role       string  `json:"role"`
+		Password   string  `json:"password"`
+		NodeScoped *bool   `json:"node_scoped"`
+		NodeIDs    *[]uint `json:"node_ids"`
+		// MustChangePassword 重置密码时默认 true
+		MustChangePassword *bool `json:"must_change_password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Email != nil {
+		updates["email"] = *req.Email
+	}
+	if req.Role != "" && req.Role != admin.Role {
+		if !models.ValidRole(req.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "角色无效，可选 owner、operator、readonly",
+			})
+			return
+		}
+		if admin.Role == models.RoleOwner && isLastOwner() {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "至少需要保留一个 owner",
+			})
+			return
+		}
+		updates["role"] = req.Role
+	}
+	if req.Password != "" {
+		if err := services.ValidatePassword(req.Password, admin.Username); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		admin.Password = req.Password
+		if err := admin.HashPassword(); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"code": 500,
+				"msg":  "密码加密失败",
+			})
+			return
+		}
+		updates["password"] = admin.Password
+		updates["must_change_password"] = req.MustChangePassword == nil || *req.MustChangePassword
+	} else if req.MustChangePassword != nil {
+		updates["must_change_password"] = *req.MustChangePassword
+	}
+	if req.NodeScoped != nil {
+		updates["node_scoped"] = *req.NodeScoped
+	}
+	if req.NodeIDs != nil {
+		if msg := validateNodeIDs(*req.NodeIDs); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  msg,
+			})
+			return
+		}
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&admin).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.NodeIDs != nil {
+			return saveAdminNodes(tx, admin.ID, *req.NodeIDs)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "更新失败: " + err.Error(),
+		})
+		return
+	}
+	if req.Password != "" {
+		services.RevokeAdminSessions(admin.ID, currentSessionKey(c, admin.ID))
+	}
+	database.DB.First(&admin, admin.ID)
+	loadAdminNodes(&admin)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "管理员更新成功",
+		"data": admin,
+	})
+}
+
+// DeleteAdmin 删除管理员
+// @Summary 删除管理员
+// @Description 删除管理员账号，不能删除自己或最后一个 owner
+// @Tags 管理员
+// @Produce json
+// @Param id path string true "管理员ID"
+// @Success 200 {object} map[string]interface{} "删除成功"
+// @Failure 400 {object} map[string]interface{} "不允许删除"
+// @Failure 403 {object} map[string]interface{} "权限不足"
+// @Failure 404 {object} map[string]interface{} "管理员不存在"
+// @Router /api/admins/{id} [delete]
+func DeleteAdmin(c *gin.Context) {
+	var admin models.Admin
+	if err := database.DB.First(&admin, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "管理员不存在",
+		})
+		return
+	}
+
+	if current := middleware.CurrentAdmin(c); current != nil && current.ID == admin.ID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "不能删除当前登录的账号",
+		})
+		return
+	}
+	if admin.Role == models.RoleOwner && isLastOwner() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "至少需要保留一个 owner",
+		})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("admin_id = ?", admin.ID).Delete(&models.AdminNode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("admin_id = ?", admin.ID).Delete(&models.AdminRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("admin_id = ?", admin.ID).Delete(&models.APIToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("admin_id = ?", admin.ID).Delete(&models.AdminSession{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&admin).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "删除失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "管理员删除成功",
+	})
+}
+
+// GetAccount 获取当前账号
+// @Summary 获取当前账号
+// @Description 返回当前登录管理员的信息、角色和授权节点
+// @Tags 管理员
+// @Produce json
+// @Success 200 {object} map[string]interface{} "成功返回账号信息"
+// @Router /api/account [get]
+func GetAccount(c *gin.Context) {
+	admin := middleware.CurrentAdmin(c)
+	loadAdminNodes(admin)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": admin,
+	})
+}
+
+// ChangePassword 修改自己的密码
+// @Summary 修改自己的密码
+// @Description 校验原密码后设置新密码，所有角色可用；新密码需符合密码策略，修改后清除强制改密标记并吊销该账号的其他登录会话
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Param body body object true "密码(old_password, new_password)"
+// @Success 200 {object} map[string]interface{} "修改成功"
+// @Failure 400 {object} map[string]interface{} "参数错误或原密码错误"
+// @Router /api/account/password [post]
+func ChangePassword(c *gin.Context) {
+	var req struct {
+		OldPassword string `json:"old_password" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "请填写原密码和新密码",
+		})
+		return
+	}
+
+	admin := middleware.CurrentAdmin(c)
+	if !admin.CheckPassword(req.OldPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "原密码错误",
+		})
+		return
+	}
+	if req.NewPassword == req.OldPassword {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "新密码不能与原密码相同",
+		})
+		return
+	}
+	if err := services.ValidatePassword(req.NewPassword, admin.Username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	admin.Password = req.NewPassword
+	if err := admin.HashPassword(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "密码加密失败",
+		})
+		return
+	}
+	err := database.DB.Model(admin).Updates(map[string]interface{}{
+		"password":             admin.Password,
+		"must_change_password": false,
+	}).Error
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "修改失败: " + err.Error(),
+		})
+		return
+	}
+
+	services.RevokeAdminSessions(admin.ID, sessions.Default(c).ID())
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "密码修改成功，其他设备上的登录已失效",
+	})
+}
+
+func validateAdminInput(role, password, username string) string {
+	if !models.ValidRole(role) {
+		return "角色无效，可选 owner、operator、readonly"
+	}
+	if err := services.ValidatePassword(password, username); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func isLastOwner() bool {
+	var count int64
+	database.DB.Model(&models.Admin{}).Where("role = ?", models.RoleOwner).Count(&count)
+	return count <= 1
+}
+
+// validateNodeIDs 检查授权节点是否都存在
+func validateNodeIDs(ids []uint) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	var count int64
+	database.DB.Model(&models.Node{}).Where("id IN ?", ids).Distinct("id").Count(&count)
+	if int(count) != len(uniqueIDs(ids)) {
+		return "授权节点不存在"
+	}
+	return ""
+}
+
+// saveAdminNodes 以 ids 整体替换管理员的授权节点
+func saveAdminNodes(tx *gorm.DB, adminID uint, ids []uint) error {
+	if err := tx.Where("admin_id = ?", adminID).Delete(&models.AdminNode{}).Error; err != nil {
+		return err
+	}
+	for _, id := range uniqueIDs(ids) {
+		if err := tx.Create(&models.AdminNode{AdminID: adminID, NodeID: id}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadAdminNodes(admin *models.Admin) {
+	admin.NodeIDs = []uint{}
+	database.DB.Model(&models.AdminNode{}).Where("admin_id = ?", admin.ID).Order("node_id asc").Pluck("node_id", &admin.NodeIDs)
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
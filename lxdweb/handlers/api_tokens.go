@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"lxdweb/database"
+	"lxdweb/middleware"
+	"lxdweb/models"
+	"lxdweb/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTokenExpireDays 令牌有效期上限（天）
+const maxTokenExpireDays = 3650
+
+// GetAccountTokens 获取自己的API令牌
+// @Summary 获取自己的API令牌
+// @Description 返回当前管理员创建的API令牌，不包含令牌明文
+// @Tags 管理员
+// @Produce json
+// @Success 200 {object} map[string]interface{} "成功返回令牌列表"
+// @Router /api/account/tokens [get]
+func GetAccountTokens(c *gin.Context) {
+	admin := middleware.CurrentAdmin(c)
+
+	var tokens []models.APIToken
+	database.DB.Where("admin_id = ?", admin.ID).Order("created_at desc").Find(&tokens)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": tokens,
+	})
+}
+
+// CreateAccountToken 创建API令牌
+// @Summary 创建API令牌
+// @Description 创建个人访问令牌，通过请求头 Authorization: Bearer <token> 调用 /api/* 接口。scope 为 read（只读）或 write；node_ids 限定可访问的节点；expires_in 为有效天数，0 表示永不过期。令牌明文只在创建时返回一次
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Param body body object true "令牌参数(name, scope, node_ids, expires_in)"
+// @Success 200 {object} map[string]interface{} "创建成功，返回令牌明文"
+// @Failure 400 {object} map[string]interface{} "参数错误"
+// @Router /api/account/tokens [post]
+func CreateAccountToken(c *gin.Context) {
+	var req struct {
+		Name      string `json:"name" binding:"required"`
+		Scope     string `json:"scope"`
+		NodeIDs   []uint `json:"node_ids"`
+		ExpiresIn int    `json:"expires_in"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "令牌名称不能为空",
+		})
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = models.TokenScopeRead
+	}
+	if req.Scope != models.TokenScopeRead && req.Scope != models.TokenScopeWrite {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "权限范围只能是 read 或 write",
+		})
+		return
+	}
+	if req.ExpiresIn < 0 || req.ExpiresIn > maxTokenExpireDays {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "有效期必须在 0-3650 天之间",
+		})
+		return
+	}
+	req.NodeIDs = uniqueIDs(req.NodeIDs)
+	if msg := validateNodeIDs(req.NodeIDs); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  msg,
+		})
+		return
+	}
+	for _, id := range req.NodeIDs {
+		if !middleware.CanAccessNode(c, id) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "不能授权自己无权访问的节点",
+			})
+			return
+		}
+	}
+
+	raw, prefix, hash, err := services.GenerateAPIToken()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "生成令牌失败",
+		})
+		return
+	}
+	token := models.APIToken{
+		AdminID:   middleware.CurrentAdmin(c).ID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		TokenHash: hash,
+		Scope:     req.Scope,
+		NodeIDs:   req.NodeIDs,
+	}
+	if req.ExpiresIn > 0 {
+		expires := time.Now().AddDate(0, 0, req.ExpiresIn)
+		token.ExpiresAt = &expires
+	}
+	if err := database.DB.Create(&token).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "创建失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "令牌已创建，请立即保存，之后将无法再次查看",
+		"data": gin.H{
+			"token": raw,
+			"info":  token,
+		},
+	})
+}
+
+// DeleteAccountToken 删除自己的API令牌
+// @Summary 删除自己的API令牌
+// @Description 吊销当前管理员的API令牌，立即失效
+// @Tags 管理员
+// @Produce json
+// @Param id path string true "令牌ID"
+// @Success 200 {object} map[string]interface{} "删除成功"
+// @Failure 404 {object} map[string]interface{} "令牌不存在"
+// @Router /api/account/tokens/{id} [delete]
+func DeleteAccountToken(c *gin.Context) {
+	admin := middleware.CurrentAdmin(c)
+	result := database.DB.Where("id = ? AND admin_id = ?", c.Param("id"), admin.ID).Delete(&models.APIToken{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "令牌不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "令牌已吊销",
+	})
+}
+
+// GetAPITokens 获取全部API令牌
+// @Summary 获取全部API令牌
+// @Description 返回所有管理员的API令牌及所属用户名，仅 owner 可用
+// @Tags 管理员
+// @Produce json
+// @Param admin_id query int false "管理员ID"
+// @Success 200 {object} map[string]interface{} "成功返回令牌列表"
+// @Router /api/tokens [get]
+func GetAPITokens(c *gin.Context) {
+	query := database.DB.Order("created_at desc")
+	if v := c.Query("admin_id"); v != "" {
+		query = query.Where("admin_id = ?", v)
+	}
+	var tokens []models.APIToken
+	query.Find(&tokens)
+
+	var admins []models.Admin
+	database.DB.Select("id", "username").Find(&admins)
+	names := make(map[uint]string, len(admins))
+	for _, a := range admins {
+		names[a.ID] = a.Username
+	}
+
+	result := make([]gin.H, 0, len(tokens))
+	for _, t := range tokens {
+		result = append(result, gin.H{
+			"token":    t,
+			"username": names[t.AdminID],
+			"expired":  t.Expired(time.Now()),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": result,
+	})
+}
+
+// RevokeAPIToken 吊销API令牌
+// @Summary 吊销API令牌
+// @Description 吊销任意管理员的API令牌，仅 owner 可用
+// @Tags 管理员
+// @Produce json
+// @Param id path string true "令牌ID"
+// @Success 200 {object} map[string]interface{} "吊销成功"
+// @Failure 404 {object} map[string]interface{} "令牌不存在"
+// @Router /api/tokens/{id} [delete]
+func RevokeAPIToken(c *gin.Context) {
+	result := database.DB.Where("id = ?", c.Param("id")).Delete(&models.APIToken{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "令牌不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "令牌已吊销",
+	})
+}
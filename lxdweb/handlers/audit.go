@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lxdweb/database"
+	"lxdweb/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxAuditExport CSV 导出的最大行数
+const maxAuditExport = 50000
+
+// GetAuditLogs 查询操作审计记录
+// @Summary 查询操作审计记录
+// @Description 按操作人、动作、目标、结果、IP 和时间范围筛选审计记录，支持分页，format=csv 时导出全部匹配记录
+// @Tags 系统管理
+// @Produce json
+// @Produce text/csv
+// @Param admin_id query int false "管理员ID"
+// @Param username query string false "用户名"
+// @Param action query string false "动作，如 container.start，支持前缀 container.*"
+// @Param target_type query string false "目标类型：node、container、admin、system"
+// @Param target_id query int false "目标ID"
+// @Param target_name query string false "目标名称"
+// @Param status query string false "结果：success、failed"
+// @Param ip query string false "来源IP"
+// @Param keyword query string false "在请求详情和错误信息中搜索"
+// @Param from query string false "开始时间（Unix 秒或 RFC3339）"
+// @Param to query string false "结束时间（Unix 秒或 RFC3339）"
+// @Param page query int false "页码，默认1"
+// @Param page_size query int false "每页条数，默认20，最大200"
+// @Param format query string false "csv 导出"
+// @Success 200 {object} map[string]interface{} "成功返回审计记录"
+// @Failure 400 {object} map[string]interface{} "参数错误"
+// @Router /api/audit [get]
+func GetAuditLogs(c *gin.Context) {
+	query, err := auditQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		exportAuditCSV(c, query)
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > 200 {
+		pageSize = 200
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var logs []models.OperationLog
+	query.Order("created_at desc, id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": gin.H{
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+			"items":     logs,
+		},
+	})
+}
+
+func auditQuery(c *gin.Context) (*gorm.DB, error) {
+	query := database.DB.Model(&models.OperationLog{})
+
+	if v := c.Query("admin_id"); v != "" {
+		query = query.Where("admin_id = ?", v)
+	}
+	if v := c.Query("username"); v != "" {
+		query = query.Where("username = ?", v)
+	}
+	if v := c.Query("action"); v != "" {
+		if len(v) > 1 && v[len(v)-1] == '*' {
+			query = query.Where("operation_type LIKE ?", v[:len(v)-1]+"%")
+		} else {
+			query = query.Where("operation_type = ?", v)
+		}
+	}
+	if v := c.Query("target_type"); v != "" {
+		query = query.Where("target_type = ?", v)
+	}
+	if v := c.Query("target_id"); v != "" {
+		query = query.Where("target_id = ?", v)
+	}
+	if v := c.Query("target_name"); v != "" {
+		query = query.Where("target_name = ?", v)
+	}
+	if v := c.Query("status"); v != "" {
+		query = query.Where("status = ?", v)
+	}
+	if v := c.Query("ip"); v != "" {
+		query = query.Where("ip_address = ?", v)
+	}
+	if v := c.Query("keyword"); v != "" {
+		like := "%" + v + "%"
+		query = query.Where("details LIKE ? OR error_message LIKE ?", like, like)
+	}
+	if v := c.Query("from"); v != "" {
+		from, err := parseQueryTime(v)
+		if err != nil {
+			return nil, fmt.Errorf("开始时间格式错误")
+		}
+		query = query.Where("created_at >= ?", from)
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := parseQueryTime(v)
+		if err != nil {
+			return nil, fmt.Errorf("结束时间格式错误")
+		}
+		query = query.Where("created_at <= ?", to)
+	}
+	return query.Session(&gorm.Session{}), nil
+}
+
+func exportAuditCSV(c *gin.Context, query *gorm.DB) {
+	filename := fmt.Sprintf("audit_%s.csv", time.Now().Format("20060102_150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+
+	// UTF-8 BOM，Excel 打开中文不乱码
+	c.Writer.Write([]byte("\xEF\xBB\xBF"))
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"时间", "管理员ID", "用户名", "动作", "目标类型", "目标ID", "目标名称", "来源IP", "结果", "错误信息", "详情"})
+
+	for offset := 0; offset < maxAuditExport; offset += 500 {
+		var batch []models.OperationLog
+		query.Order("created_at desc, id desc").Offset(offset).Limit(500).Find(&batch)
+		for _, l := range batch {
+			w.Write([]string{
+				l.CreatedAt.Format("2006-01-02 15:04:05"),
+				strconv.FormatUint(uint64(l.AdminID), 10),
+				l.Username,
+				l.OperationType,
+				l.TargetType,
+				strconv.FormatUint(uint64(l.TargetID), 10),
+				l.TargetName,
+				l.IPAddress,
+				l.Status,
+				l.ErrorMessage,
+				l.Details,
+			})
+		}
+		w.Flush()
+		if len(batch) < 500 {
+			break
+		}
+	}
+	w.Flush()
+}
@@ -2,7 +2,9 @@ package handlers
 import (
 	"lxdweb/database"
 	"lxdweb/models"
+	"lxdweb/services"
 	"net/http"
+	"time"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
@@ -19,13 +21,22 @@ func LoginPage(c *gin.Context) {
 		c.Redirect(http.StatusFound, "/dashboard")
 		return
 	}
-	c.HTML(http.StatusOK, "login.html", gin.H{
+	renderLogin(c, http.StatusOK, "")
+}
+// renderLogin 渲染登录页，单点登录失败时带上错误信息
+func renderLogin(c *gin.Context, status int, errMsg string) {
+	data := gin.H{
 		"title": "登录 - LXD管理后台",
-	})
+		"error": errMsg,
+	}
+	if services.OIDCEnabled() {
+		data["oidc_button"] = services.OIDCButtonText()
+	}
+	c.HTML(status, "login.html", data)
 }
 // Login 用户登录
 // @Summary 用户登录
-// @Description 验证用户名、密码和验证码，登录成功后创建会话
+// @Description 验证用户名、密码和验证码，登录成功后创建会话；已启用两步验证的账号返回 two_factor=true，需继续调用 /login/2fa
 // @Tags 认证管理
 // @Accept json
 // @Produce json
@@ -33,6 +44,7 @@ func LoginPage(c *gin.Context) {
 // @Success 200 {object} map[string]interface{} "登录成功"
 // @Failure 400 {object} map[string]interface{} "参数错误或验证码错误"
 // @Failure 401 {object} map[string]interface{} "用户名或密码错误"
+// @Failure 429 {object} map[string]interface{} "失败次数过多，账号或IP已锁定"
 // @Router /api/login [post]
 func Login(c *gin.Context) {
 	var req struct {
@@ -47,6 +59,12 @@ func Login(c *gin.Context) {
 		})
 		return
 	}
+	ip := c.ClientIP()
+	if lockout, blocked := services.LoginBlocked(ip, req.Username); blocked {
+		services.RecordLoginFailure(ip, req.Username, services.LoginFailLocked)
+		respondLoginLocked(c, lockout)
+		return
+	}
 	session := sessions.Default(c)
 	captchaID := session.Get("captcha_id")
 	if captchaID == nil {
@@ -57,6 +75,7 @@ func Login(c *gin.Context) {
 		return
 	}
 	if !VerifyCaptcha(captchaID.(string), req.Captcha) {
+		services.RecordLoginFailure(ip, req.Username, services.LoginFailCaptcha)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code": 400,
 			"msg":  "验证码错误",
@@ -67,6 +86,7 @@ func Login(c *gin.Context) {
 	session.Save()
 	var admin models.Admin
 	if err := database.DB.Where("username = ?", req.Username).First(&admin).Error; err != nil {
+		services.RecordLoginFailure(ip, req.Username, services.LoginFailPassword)
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"code": 401,
 			"msg":  "用户名或密码错误",
@@ -74,12 +94,34 @@ func Login(c *gin.Context) {
 		return
 	}
 	if !admin.CheckPassword(req.Password) {
+		services.RecordLoginFailure(ip, req.Username, services.LoginFailPassword)
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"code": 401,
 			"msg":  "用户名或密码错误",
 		})
 		return
 	}
+	if admin.TOTPEnabled {
+		// 密码正确但需要第二步验证，暂不写入 admin_id
+		session.Set("2fa_admin_id", admin.ID)
+		session.Set("2fa_expires", time.Now().Add(twoFactorLoginTTL).Unix())
+		session.Set("2fa_attempts", 0)
+		if err := session.Save(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": 500,
+				"msg":  "登录失败",
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"code": 200,
+			"msg":  "请输入两步验证码",
+			"data": gin.H{
+				"two_factor": true,
+			},
+		})
+		return
+	}
 	session.Set("admin_id", admin.ID)
 	session.Set("username", admin.Username)
 	if err := session.Save(); err != nil {
@@ -89,6 +131,7 @@ func Login(c *gin.Context) {
 		})
 		return
 	}
+	services.RecordLoginSuccess(ip, admin.Username)
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"msg":  "登录成功",
@@ -97,6 +140,16 @@ func Login(c *gin.Context) {
 		},
 	})
 }
+// respondLoginLocked 返回 429 和解锁时间
+func respondLoginLocked(c *gin.Context, lockout *models.LoginLockout) {
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"code": 429,
+		"msg":  "登录失败次数过多，请于 " + lockout.LockedUntil.Format("15:04:05") + " 后重试",
+		"data": gin.H{
+			"locked_until": lockout.LockedUntil,
+		},
+	})
+}
 // Logout 用户登出
 // @Summary 用户登出
 // @Description 清除用户会话，退出登录
@@ -1,12 +1,8 @@
 package handlers
 import (
-	"bytes"
-	"crypto/tls"
-	"encoding/json"
 	"fmt"
-	"io"
-	"lxdweb/database"
-	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+	"lxdweb/services"
 	"net/http"
 	"github.com/gin-gonic/gin"
 )
@@ -34,62 +30,27 @@ func CreateConsoleToken(c *gin.Context) {
 		})
 		return
 	}
-	var node models.Node
-	if err := database.DB.First(&node, req.NodeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "节点不存在",
-		})
-		return
-	}
-	tokenReq := map[string]interface{}{
-		"hostname":   req.Hostname,
-		"user_id":    1, 
-		"service_id": 0,
-		"server_ip":  c.ClientIP(),
-		"expires_in": 3600, 
-	}
-	reqBody, _ := json.Marshal(tokenReq)
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	}
-	url := fmt.Sprintf("%s/api/console/create-token", node.Address)
-	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": 500,
-			"msg":  "创建请求失败: " + err.Error(),
-		})
+	node, ok := requestNode(c)
+	if !ok {
 		return
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("apikey", node.APIKey)
-	resp, err := client.Do(httpReq)
+	token, err := services.NodeClient(node).CreateConsoleToken(c.Request.Context(), &lxdapi.ConsoleTokenRequest{
+		Hostname:  req.Hostname,
+		UserID:    1,
+		ServiceID: 0,
+		ServerIP:  c.ClientIP(),
+		ExpiresIn: 3600,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code": 500,
-			"msg":  "调用节点API失败: " + err.Error(),
-		})
-		return
-	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	var result map[string]interface{}
-	json.Unmarshal(body, &result)
-	if result["code"] != float64(200) {
-		c.JSON(http.StatusOK, result)
+		respondNodeError(c, err)
 		return
 	}
-	data := result["data"].(map[string]interface{})
-	token := data["token"].(string)
-	consoleURL := fmt.Sprintf("%s/console?token=%s", node.Address, token)
+	consoleURL := fmt.Sprintf("%s/console?token=%s", node.Address, token.Token)
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"msg":  "success",
 		"data": gin.H{
-			"token":       token,
+			"token":       token.Token,
 			"console_url": consoleURL,
 			"node_url":    node.Address,
 			"hostname":    req.Hostname,
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"lxdweb/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetContainerMetrics 获取容器监控历史
+// @Summary 获取容器监控历史
+// @Description 查询容器 CPU、内存、磁盘、流量的历史数据，根据时间范围自动选择原始或降采样数据
+// @Tags 容器管理
+// @Produce json
+// @Param name path string true "容器名称"
+// @Param node_id query string true "节点ID"
+// @Param from query string false "开始时间（Unix 秒或 RFC3339），默认 1 小时前"
+// @Param to query string false "结束时间（Unix 秒或 RFC3339），默认当前时间"
+// @Param step query int false "数据点间隔（秒），默认按约 500 个点自动计算"
+// @Success 200 {object} map[string]interface{} "成功返回监控数据"
+// @Failure 400 {object} map[string]interface{} "参数错误"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/containers/{name}/metrics [get]
+func GetContainerMetrics(c *gin.Context) {
+	name := c.Param("name")
+	node, ok := requestNode(c)
+	if !ok {
+		return
+	}
+
+	var err error
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		if to, err = parseQueryTime(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "结束时间格式错误",
+			})
+			return
+		}
+	}
+	from := to.Add(-1 * time.Hour)
+	if v := c.Query("from"); v != "" {
+		if from, err = parseQueryTime(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "开始时间格式错误",
+			})
+			return
+		}
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "开始时间必须早于结束时间",
+		})
+		return
+	}
+
+	step, _ := strconv.Atoi(c.Query("step"))
+	points, actualStep := services.QueryContainerMetrics(node.ID, name, from, to, step)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": gin.H{
+			"node_id":  node.ID,
+			"hostname": name,
+			"from":     from,
+			"to":       to,
+			"step":     actualStep,
+			"points":   points,
+		},
+	})
+}
+
+// parseQueryTime 解析 Unix 秒或 RFC3339 格式的时间参数
+func parseQueryTime(v string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
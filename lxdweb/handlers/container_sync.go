@@ -5,10 +5,10 @@ import (
 	"strconv"
 
 	"lxdweb/database"
+	"lxdweb/middleware"
 	"lxdweb/models"
 	"lxdweb/services"
 
-	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
 
@@ -22,9 +22,7 @@ import (
 // @Failure 500 {object} map[string]interface{} "查询失败"
 // @Router /api/sync/all [post]
 func SyncAllNodes(c *gin.Context) {
-	session := sessions.Default(c)
-	username := session.Get("username")
-	if username == nil {
+	if middleware.CurrentAdmin(c) == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"code": 401,
 			"msg":  "未登录",
@@ -33,7 +31,7 @@ func SyncAllNodes(c *gin.Context) {
 	}
 
 	var nodes []models.Node
-	if err := database.DB.Where("status = ?", "active").Find(&nodes).Error; err != nil {
+	if err := middleware.ScopeNodes(c, database.DB, "id").Where("status IN ?", []string{"active", "degraded"}).Find(&nodes).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
 			"msg":  "查询节点失败",
@@ -63,9 +61,7 @@ func SyncAllNodes(c *gin.Context) {
 // @Failure 404 {object} map[string]interface{} "节点不存在"
 // @Router /api/sync/node/{id} [post]
 func SyncNode(c *gin.Context) {
-	session := sessions.Default(c)
-	username := session.Get("username")
-	if username == nil {
+	if middleware.CurrentAdmin(c) == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"code": 401,
 			"msg":  "未登录",
@@ -109,9 +105,7 @@ func SyncNode(c *gin.Context) {
 // @Failure 401 {object} map[string]interface{} "未登录"
 // @Router /api/sync/tasks [get]
 func GetSyncTasks(c *gin.Context) {
-	session := sessions.Default(c)
-	username := session.Get("username")
-	if username == nil {
+	if middleware.CurrentAdmin(c) == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"code": 401,
 			"msg":  "未登录",
@@ -120,7 +114,7 @@ func GetSyncTasks(c *gin.Context) {
 	}
 
 	var tasks []models.SyncTask
-	database.DB.Order("created_at DESC").Limit(50).Find(&tasks)
+	middleware.ScopeNodes(c, database.DB, "node_id").Order("created_at DESC").Limit(50).Find(&tasks)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
@@ -139,9 +133,7 @@ func GetSyncTasks(c *gin.Context) {
 // @Failure 401 {object} map[string]interface{} "未登录"
 // @Router /api/sync/status [get]
 func GetSyncStatus(c *gin.Context) {
-	session := sessions.Default(c)
-	username := session.Get("username")
-	if username == nil {
+	if middleware.CurrentAdmin(c) == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"code": 401,
 			"msg":  "未登录",
@@ -166,7 +158,7 @@ func GetSyncStatus(c *gin.Context) {
 		}
 	} else {
 		var nodes []models.Node
-		database.DB.Find(&nodes)
+		middleware.ScopeNodes(c, database.DB, "id").Find(&nodes)
 		
 		for _, node := range nodes {
 			var lastTask models.SyncTask
@@ -1,12 +1,11 @@
 package handlers
 import (
-	"bytes"
-	"crypto/tls"
-	"encoding/json"
-	"fmt"
-	"io"
+	"context"
 	"lxdweb/database"
+	"lxdweb/middleware"
 	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+	"lxdweb/services"
 	"net/http"
 	"time"
 	"github.com/gin-contrib/sessions"
@@ -37,7 +36,7 @@ func ContainersPage(c *gin.Context) {
 // @Router /api/containers [get]
 func GetContainers(c *gin.Context) {
 	var containers []models.ContainerCache
-	database.DB.Order("node_id ASC, hostname ASC").Find(&containers)
+	middleware.ScopeNodes(c, database.DB, "node_id").Order("node_id ASC, hostname ASC").Find(&containers)
 	
 	allContainers := make([]map[string]interface{}, 0, len(containers))
 	for _, container := range containers {
@@ -82,9 +81,7 @@ func GetContainers(c *gin.Context) {
 // @Failure 401 {object} map[string]interface{} "未登录"
 // @Router /api/containers/cache [get]
 func GetContainersFromCache(c *gin.Context) {
-	session := sessions.Default(c)
-	username := session.Get("username")
-	if username == nil {
+	if middleware.CurrentAdmin(c) == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"code": 401,
 			"msg":  "未登录",
@@ -94,7 +91,7 @@ func GetContainersFromCache(c *gin.Context) {
 
 	// 从本地数据库缓存查询
 	var containers []models.ContainerCache
-	query := database.DB.Order("node_id ASC, hostname ASC")
+	query := middleware.ScopeNodes(c, database.DB, "node_id").Order("node_id ASC, hostname ASC")
 	
 	// 支持按 node_id 筛选
 	if nodeID := c.Query("node_id"); nodeID != "" {
@@ -128,21 +125,20 @@ func GetContainersFromCache(c *gin.Context) {
 // @Router /api/containers/{name} [get]
 func GetContainerDetail(c *gin.Context) {
 	name := c.Param("name")
-	nodeID := c.Query("node_id")
-	var node models.Node
-	if err := database.DB.First(&node, nodeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "节点不存在",
-		})
+	node, ok := requestNode(c)
+	if !ok {
 		return
 	}
-	detail := fetchContainerDetail(node, name)
-	if detail == nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "容器不存在",
-		})
+	detail, err := services.NodeClient(node).Info(c.Request.Context(), name)
+	if err != nil {
+		if _, ok := lxdapi.AsAPIError(err); ok {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code": 404,
+				"msg":  "容器不存在",
+			})
+			return
+		}
+		respondNodeError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
@@ -163,21 +159,18 @@ func GetContainerDetail(c *gin.Context) {
 // @Router /api/containers/{name}/start [post]
 func StartContainer(c *gin.Context) {
 	name := c.Param("name")
-	nodeID := c.Query("node_id")
-	var node models.Node
-	if err := database.DB.First(&node, nodeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "节点不存在",
-		})
+	node, ok := requestNode(c)
+	if !ok {
 		return
 	}
-	result := callNodeAPI(node, "GET", "/api/boot?hostname="+name, nil)
-	if result["code"] == float64(200) {
-		time.Sleep(1 * time.Second)
-		callNodeAPI(node, "GET", fmt.Sprintf("/api/info?hostname=%s", name), nil)
+	client := services.NodeClient(node)
+	res, err := client.Boot(c.Request.Context(), name)
+	if err != nil {
+		respondNodeError(c, err)
+		return
 	}
-	c.JSON(http.StatusOK, result)
+	refreshContainerInfo(client, name, time.Second)
+	respondNodeSuccess(c, res)
 }
 // StopContainer 停止容器
 // @Summary 停止容器
@@ -191,21 +184,18 @@ func StartContainer(c *gin.Context) {
 // @Router /api/containers/{name}/stop [post]
 func StopContainer(c *gin.Context) {
 	name := c.Param("name")
-	nodeID := c.Query("node_id")
-	var node models.Node
-	if err := database.DB.First(&node, nodeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "节点不存在",
-		})
+	node, ok := requestNode(c)
+	if !ok {
 		return
 	}
-	result := callNodeAPI(node, "GET", "/api/stop?hostname="+name, nil)
-	if result["code"] == float64(200) {
-		time.Sleep(1 * time.Second)
-		callNodeAPI(node, "GET", fmt.Sprintf("/api/info?hostname=%s", name), nil)
+	client := services.NodeClient(node)
+	res, err := client.Stop(c.Request.Context(), name)
+	if err != nil {
+		respondNodeError(c, err)
+		return
 	}
-	c.JSON(http.StatusOK, result)
+	refreshContainerInfo(client, name, time.Second)
+	respondNodeSuccess(c, res)
 }
 // RestartContainer 重启容器
 // @Summary 重启容器
@@ -219,21 +209,18 @@ func StopContainer(c *gin.Context) {
 // @Router /api/containers/{name}/restart [post]
 func RestartContainer(c *gin.Context) {
 	name := c.Param("name")
-	nodeID := c.Query("node_id")
-	var node models.Node
-	if err := database.DB.First(&node, nodeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "节点不存在",
-		})
+	node, ok := requestNode(c)
+	if !ok {
 		return
 	}
-	result := callNodeAPI(node, "GET", "/api/reboot?hostname="+name, nil)
-	if result["code"] == float64(200) {
-		time.Sleep(2 * time.Second)
-		callNodeAPI(node, "GET", fmt.Sprintf("/api/info?hostname=%s", name), nil)
+	client := services.NodeClient(node)
+	res, err := client.Reboot(c.Request.Context(), name)
+	if err != nil {
+		respondNodeError(c, err)
+		return
 	}
-	c.JSON(http.StatusOK, result)
+	refreshContainerInfo(client, name, 2*time.Second)
+	respondNodeSuccess(c, res)
 }
 // DeleteContainer 删除容器
 // @Summary 删除容器
@@ -247,21 +234,20 @@ func RestartContainer(c *gin.Context) {
 // @Router /api/containers/{name}/delete [post]
 func DeleteContainer(c *gin.Context) {
 	name := c.Param("name")
-	nodeID := c.Query("node_id")
-	var node models.Node
-	if err := database.DB.First(&node, nodeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "节点不存在",
-		})
+	node, ok := requestNode(c)
+	if !ok {
 		return
 	}
-	result := callNodeAPI(node, "GET", "/api/delete?hostname="+name, nil)
-	if result["code"] == float64(200) {
-		database.DB.Unscoped().Where("node_id = ? AND hostname = ?", node.ID, name).Delete(&models.Container{})
-		database.DB.Unscoped().Where("node_id = ? AND hostname = ?", node.ID, name).Delete(&models.ContainerCache{})
+	res, err := services.NodeClient(node).Delete(c.Request.Context(), name)
+	if err != nil {
+		respondNodeError(c, err)
+		return
 	}
-	c.JSON(http.StatusOK, result)
+	database.DB.Unscoped().Where("node_id = ? AND hostname = ?", node.ID, name).Delete(&models.Container{})
+	database.DB.Unscoped().Where("node_id = ? AND hostname = ?", node.ID, name).Delete(&models.ContainerCache{})
+	services.ClearNATRules(node.ID, name)
+	services.ClearIPv6Bindings(node.ID, name)
+	respondNodeSuccess(c, res)
 }
 
 // RefreshSingleContainer 刷新单个容器信息
@@ -276,20 +262,19 @@ func DeleteContainer(c *gin.Context) {
 // @Router /api/containers/{name}/refresh [post]
 func RefreshSingleContainer(c *gin.Context) {
 	name := c.Param("name")
-	nodeID := c.Query("node_id")
-	var node models.Node
-	if err := database.DB.First(&node, nodeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "节点不存在",
-		})
+	node, ok := requestNode(c)
+	if !ok {
+		return
+	}
+	info, err := services.NodeClient(node).Info(c.Request.Context(), name)
+	if err != nil {
+		respondNodeError(c, err)
 		return
 	}
-	result := callNodeAPI(node, "GET", fmt.Sprintf("/api/info?hostname=%s", name), nil)
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"msg":  "刷新成功",
-		"data": result,
+		"data": info,
 	})
 }
 
@@ -335,43 +320,38 @@ func ReinstallContainer(c *gin.Context) {
 		return
 	}
 
-	var node models.Node
-	if err := database.DB.First(&node, req.NodeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "节点不存在",
-		})
+	node, ok := requestNode(c)
+	if !ok {
 		return
 	}
 
-	reinstallData := map[string]interface{}{
-		"hostname":      name,
-		"system":        req.Image,
-		"password":      req.Password,
-		"cpus":          req.CPUs,
-		"memory":        req.Memory,
-		"disk":          req.Disk,
-		"ingress":       req.Ingress,
-		"egress":        req.Egress,
-		"traffic_limit": req.TrafficLimit,
-		"allow_nesting": req.AllowNesting,
-		"memory_swap":   req.MemorySwap,
-		"max_processes": req.MaxProcesses,
-		"cpu_allowance": req.CPUAllowance,
-		"privileged":    req.Privileged,
-		"enable_lxcfs":  req.EnableLXCFS,
-	}
-	
-	if req.DiskIOLimit != "" {
-		reinstallData["disk_io_limit"] = req.DiskIOLimit
+	reinstallReq := &lxdapi.ReinstallRequest{
+		Hostname:     name,
+		System:       req.Image,
+		Password:     req.Password,
+		CPUs:         req.CPUs,
+		Memory:       req.Memory,
+		Disk:         req.Disk,
+		Ingress:      req.Ingress,
+		Egress:       req.Egress,
+		TrafficLimit: req.TrafficLimit,
+		AllowNesting: req.AllowNesting,
+		MemorySwap:   req.MemorySwap,
+		MaxProcesses: req.MaxProcesses,
+		CPUAllowance: req.CPUAllowance,
+		DiskIOLimit:  req.DiskIOLimit,
+		Privileged:   req.Privileged,
+		EnableLXCFS:  req.EnableLXCFS,
 	}
 
-	result := callNodeAPI(node, "POST", "/api/reinstall", reinstallData)
-	if result["code"] == float64(200) {
-		time.Sleep(2 * time.Second)
-		callNodeAPI(node, "GET", fmt.Sprintf("/api/info?hostname=%s", name), nil)
+	client := services.NodeClient(node)
+	res, err := client.Reinstall(c.Request.Context(), reinstallReq)
+	if err != nil {
+		respondNodeError(c, err)
+		return
 	}
-	c.JSON(http.StatusOK, result)
+	refreshContainerInfo(client, name, 2*time.Second)
+	respondNodeSuccess(c, res)
 }
 
 // ResetContainerPassword 重置容器密码
@@ -402,22 +382,20 @@ func ResetContainerPassword(c *gin.Context) {
 		return
 	}
 
-	var node models.Node
-	if err := database.DB.First(&node, req.NodeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "节点不存在",
-		})
+	node, ok := requestNode(c)
+	if !ok {
 		return
 	}
 
-	passwordData := map[string]interface{}{
-		"hostname": name,
-		"password": req.Password,
+	res, err := services.NodeClient(node).SetPassword(c.Request.Context(), &lxdapi.PasswordRequest{
+		Hostname: name,
+		Password: req.Password,
+	})
+	if err != nil {
+		respondNodeError(c, err)
+		return
 	}
-
-	result := callNodeAPI(node, "POST", "/api/password", passwordData)
-	c.JSON(http.StatusOK, result)
+	respondNodeSuccess(c, res)
 }
 
 // SuspendContainer 暂停容器
@@ -432,23 +410,19 @@ func ResetContainerPassword(c *gin.Context) {
 // @Router /api/containers/{name}/suspend [post]
 func SuspendContainer(c *gin.Context) {
 	name := c.Param("name")
-	nodeID := c.Query("node_id")
-	
-	var node models.Node
-	if err := database.DB.First(&node, nodeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "节点不存在",
-		})
+	node, ok := requestNode(c)
+	if !ok {
 		return
 	}
 	
-	result := callNodeAPI(node, "GET", "/api/suspend?hostname="+name, nil)
-	if result["code"] == float64(200) {
-		time.Sleep(1 * time.Second)
-		callNodeAPI(node, "GET", fmt.Sprintf("/api/info?hostname=%s", name), nil)
+	client := services.NodeClient(node)
+	res, err := client.Suspend(c.Request.Context(), name)
+	if err != nil {
+		respondNodeError(c, err)
+		return
 	}
-	c.JSON(http.StatusOK, result)
+	refreshContainerInfo(client, name, time.Second)
+	respondNodeSuccess(c, res)
 }
 
 // UnsuspendContainer 恢复容器
@@ -463,23 +437,19 @@ func SuspendContainer(c *gin.Context) {
 // @Router /api/containers/{name}/unsuspend [post]
 func UnsuspendContainer(c *gin.Context) {
 	name := c.Param("name")
-	nodeID := c.Query("node_id")
-	
-	var node models.Node
-	if err := database.DB.First(&node, nodeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "节点不存在",
-		})
+	node, ok := requestNode(c)
+	if !ok {
 		return
 	}
 	
-	result := callNodeAPI(node, "GET", "/api/unsuspend?hostname="+name, nil)
-	if result["code"] == float64(200) {
-		time.Sleep(1 * time.Second)
-		callNodeAPI(node, "GET", fmt.Sprintf("/api/info?hostname=%s", name), nil)
+	client := services.NodeClient(node)
+	res, err := client.Unsuspend(c.Request.Context(), name)
+	if err != nil {
+		respondNodeError(c, err)
+		return
 	}
-	c.JSON(http.StatusOK, result)
+	refreshContainerInfo(client, name, time.Second)
+	respondNodeSuccess(c, res)
 }
 
 // ResetContainerTraffic 重置容器流量
@@ -494,19 +464,19 @@ func UnsuspendContainer(c *gin.Context) {
 // @Router /api/containers/{name}/traffic/reset [post]
 func ResetContainerTraffic(c *gin.Context) {
 	name := c.Param("name")
-	nodeID := c.Query("node_id")
-	
-	var node models.Node
-	if err := database.DB.First(&node, nodeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "节点不存在",
-		})
+	node, ok := requestNode(c)
+	if !ok {
 		return
 	}
 	
-	result := callNodeAPI(node, "POST", "/api/traffic/reset?hostname="+name, nil)
-	c.JSON(http.StatusOK, result)
+	res, err := services.NodeClient(node).ResetTraffic(c.Request.Context(), name)
+	if err != nil {
+		respondNodeError(c, err)
+		return
+	}
+	services.MarkTrafficReset(node.ID, name)
+	services.ClearQuotaWarnings(node.ID, name)
+	respondNodeSuccess(c, res)
 }
 // CreateContainer 创建容器
 // @Summary 创建容器
@@ -547,12 +517,8 @@ func CreateContainer(c *gin.Context) {
 		return
 	}
 
-	var node models.Node
-	if err := database.DB.First(&node, req.NodeID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"code": 404,
-			"msg":  "节点不存在",
-		})
+	node, ok := requestNode(c)
+	if !ok {
 		return
 	}
 
@@ -578,126 +544,99 @@ func CreateContainer(c *gin.Context) {
 		req.CPUAllowance = "100%"
 	}
 
-	createData := map[string]interface{}{
-		"hostname":       req.Hostname,
-		"password":       req.Password,
-		"image":          req.Image,
-		"cpus":           req.CPUs,
-		"memory":         req.Memory,
-		"disk":           req.Disk,
-		"ingress":        req.Ingress,
-		"egress":         req.Egress,
-		"traffic_limit":  req.TrafficLimit,
-		"allow_nesting":  req.AllowNesting,
-		"memory_swap":    req.MemorySwap,
-		"max_processes":  req.MaxProcesses,
-		"cpu_allowance":  req.CPUAllowance,
-		"privileged":     req.Privileged,
-	}
-	if req.DiskIOLimit != "" {
-		createData["disk_io_limit"] = req.DiskIOLimit
+	createReq := &lxdapi.CreateRequest{
+		Hostname:     req.Hostname,
+		Password:     req.Password,
+		Image:        req.Image,
+		CPUs:         req.CPUs,
+		Memory:       req.Memory,
+		Disk:         req.Disk,
+		Ingress:      req.Ingress,
+		Egress:       req.Egress,
+		TrafficLimit: req.TrafficLimit,
+		AllowNesting: req.AllowNesting,
+		MemorySwap:   req.MemorySwap,
+		MaxProcesses: req.MaxProcesses,
+		CPUAllowance: req.CPUAllowance,
+		DiskIOLimit:  req.DiskIOLimit,
+		Privileged:   req.Privileged,
 	}
 
-	result := callNodeAPI(node, "POST", "/api/create", createData)
-	if result["code"] == float64(200) {
-		time.Sleep(2 * time.Second)
-		callNodeAPI(node, "GET", fmt.Sprintf("/api/info?hostname=%s", req.Hostname), nil)
+	client := services.NodeClient(node)
+	res, err := client.Create(c.Request.Context(), createReq)
+	if err != nil {
+		respondNodeError(c, err)
+		return
 	}
-	c.JSON(http.StatusOK, result)
+	refreshContainerInfo(client, req.Hostname, 2*time.Second)
+	respondNodeSuccess(c, res)
 }
-func fetchContainersFromNode(node models.Node) []map[string]interface{} {
-	result := callNodeAPI(node, "GET", "/api/list", nil)
-	if result["code"] != float64(200) {
-		return []map[string]interface{}{}
+
+// refreshContainerInfo 等待操作生效后请求一次 /api/info，使节点刷新该容器状态
+func refreshContainerInfo(client *lxdapi.Client, hostname string, delay time.Duration) {
+	time.Sleep(delay)
+	client.Info(context.Background(), hostname)
+}
+
+// respondNodeSuccess 返回节点的提示信息，节点返回了 data 时原样透传
+func respondNodeSuccess(c *gin.Context, res *lxdapi.Result) {
+	resp := gin.H{
+		"code": 200,
+		"msg":  res.Msg,
 	}
-	data, ok := result["data"].([]interface{})
-	if !ok {
-		return []map[string]interface{}{}
-	}
-	containers := make([]map[string]interface{}, 0, len(data))
-	for _, item := range data {
-		if container, ok := item.(map[string]interface{}); ok {
-			hostname, _ := container["hostname"].(string)
-			if hostname != "" {
-				detailResult := callNodeAPI(node, "GET", fmt.Sprintf("/api/info?hostname=%s", hostname), nil)
-				if detailResult["code"] == float64(200) {
-					if detailData, ok := detailResult["data"].(map[string]interface{}); ok {
-						if cpuUsage, ok := detailData["cpu_percent"].(float64); ok {
-							container["cpu_usage"] = cpuUsage
-						} else if cpuUsage, ok := detailData["cpu_usage"].(float64); ok {
-							container["cpu_usage"] = cpuUsage
-						}
-						if memUsageRaw, ok := detailData["memory_usage_raw"].(float64); ok {
-							container["memory_usage"] = uint64(memUsageRaw)
-						}
-						if memTotal, ok := detailData["memory"].(float64); ok {
-							container["memory_total"] = uint64(memTotal * 1024 * 1024) 
-						}
-						if diskUsageRaw, ok := detailData["disk_usage_raw"].(float64); ok {
-							container["disk_usage"] = uint64(diskUsageRaw)
-						}
-						if diskTotal, ok := detailData["disk"].(float64); ok {
-							container["disk_total"] = uint64(diskTotal * 1024 * 1024) 
-						}
-						if trafficRaw, ok := detailData["traffic_usage_raw"].(float64); ok {
-							container["traffic_total"] = uint64(trafficRaw)
-							container["traffic_in"] = uint64(trafficRaw * 0.5)
-							container["traffic_out"] = uint64(trafficRaw * 0.5)
-						}
-					}
-				}
-			}
-			containers = append(containers, container)
-		}
+	if len(res.Data) > 0 {
+		resp["data"] = res.Data
 	}
-	return containers
+	c.JSON(http.StatusOK, resp)
 }
-func fetchContainerDetail(node models.Node, name string) map[string]interface{} {
-	result := callNodeAPI(node, "GET", fmt.Sprintf("/api/info?hostname=%s", name), nil)
-	if result["code"] == float64(200) {
-		if data, ok := result["data"].(map[string]interface{}); ok {
-			return data
-		}
+
+// requestNode 读取 NodeScope 解析出的节点，未指定、无权访问或不存在时写入错误响应
+func requestNode(c *gin.Context) (models.Node, bool) {
+	var node models.Node
+	nodeID, ok := middleware.RequestNodeID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "缺少节点ID",
+		})
+		return node, false
 	}
-	return nil
-}
-func callNodeAPI(node models.Node, method, path string, data interface{}) map[string]interface{} {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	}
-	var body io.Reader
-	if data != nil {
-		jsonData, _ := json.Marshal(data)
-		body = bytes.NewBuffer(jsonData)
-	}
-	req, err := http.NewRequest(method, node.Address+path, body)
-	if err != nil {
-		return map[string]interface{}{
-			"code": 500,
-			"msg":  "请求创建失败: " + err.Error(),
-		}
+	if !middleware.CanAccessNode(c, nodeID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code": 403,
+			"msg":  "无权访问该节点",
+		})
+		return node, false
 	}
-	if node.APIKey != "" {
-		req.Header.Set("apikey", node.APIKey)
+	if err := database.DB.First(&node, nodeID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "节点不存在",
+		})
+		return node, false
 	}
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	if err != nil {
-		return map[string]interface{}{
-			"code": 500,
-			"msg":  "请求失败: " + err.Error(),
-		}
+	return node, true
+}
+
+// respondNodeError 将 lxdapi 错误转换为统一的 JSON 响应
+func respondNodeError(c *gin.Context, err error) {
+	if apiErr, ok := lxdapi.AsAPIError(err); ok {
+		c.JSON(http.StatusOK, gin.H{
+			"code": apiErr.Code,
+			"msg":  apiErr.Msg,
+		})
+		return
 	}
-	defer resp.Body.Close()
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return map[string]interface{}{
-			"code": 500,
-			"msg":  "响应解析失败: " + err.Error(),
-		}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 500,
+		"msg":  "请求失败: " + err.Error(),
+	})
+}
+
+// nodeErrorMsg 返回节点错误的提示信息，节点返回的业务错误只取 msg
+func nodeErrorMsg(err error) string {
+	if apiErr, ok := lxdapi.AsAPIError(err); ok {
+		return apiErr.Msg
 	}
-	return result
+	return err.Error()
 }
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"lxdweb/database"
+	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+	"lxdweb/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// refreshIPv6Bindings 绑定变更后更新 ipv6_bindings 缓存，失败只记录日志，等待下次同步
+func refreshIPv6Bindings(c *gin.Context, client *lxdapi.Client, node models.Node, hostname string) {
+	if err := services.RefreshContainerIPv6Bindings(c.Request.Context(), client, node, hostname); err != nil {
+		log.Printf("[IPV6] 更新容器 %s 的 IPv6 绑定缓存失败: %v", hostname, err)
+	}
+}
+
+// GetContainerIPv6 获取容器的独立 IPv6 绑定
+// @Summary 获取容器的独立 IPv6 绑定
+// @Description 从节点读取容器绑定的独立 IPv6 地址
+// @Tags IPv6绑定
+// @Produce json
+// @Param name path string true "容器名称"
+// @Param node_id query string true "节点ID"
+// @Success 200 {object} map[string]interface{} "成功返回绑定列表"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/containers/{name}/ipv6 [get]
+func GetContainerIPv6(c *gin.Context) {
+	node, ok := requestNode(c)
+	if !ok {
+		return
+	}
+	list, err := services.NodeClient(node).IPv6List(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		respondNodeError(c, err)
+		return
+	}
+	if list == nil {
+		list = []lxdapi.IPv6Binding{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": list,
+	})
+}
+
+// BindContainerIPv6 绑定独立 IPv6 地址
+// @Summary 绑定独立 IPv6 地址
+// @Description 由节点从 IPv6 地址池中分配一个地址绑定到容器
+// @Tags IPv6绑定
+// @Accept json
+// @Produce json
+// @Param name path string true "容器名称"
+// @Param node_id query string true "节点ID"
+// @Param body body models.IPv6BindRequest false "绑定参数"
+// @Success 200 {object} map[string]interface{} "绑定成功"
+// @Failure 400 {object} map[string]interface{} "参数错误"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/containers/{name}/ipv6 [post]
+func BindContainerIPv6(c *gin.Context) {
+	name := c.Param("name")
+	var req models.IPv6BindRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "参数错误: " + err.Error(),
+			})
+			return
+		}
+	}
+	node, ok := requestNode(c)
+	if !ok {
+		return
+	}
+
+	if pool, ok := services.NodeIPv6Pool(node.ID); ok && !pool.Enabled {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "节点未启用 IPv6 独立绑定",
+		})
+		return
+	}
+
+	client := services.NodeClient(node)
+	res, err := client.AddIPv6(c.Request.Context(), name, strings.TrimSpace(req.Description))
+	if err != nil {
+		respondNodeError(c, err)
+		return
+	}
+	refreshIPv6Bindings(c, client, node, name)
+	respondNodeSuccess(c, res)
+}
+
+// UnbindContainerIPv6 解除独立 IPv6 绑定
+// @Summary 解除独立 IPv6 绑定
+// @Description 通过节点解除容器的独立 IPv6 绑定，地址归还地址池
+// @Tags IPv6绑定
+// @Accept json
+// @Produce json
+// @Param name path string true "容器名称"
+// @Param node_id query string true "节点ID"
+// @Param body body models.IPv6UnbindRequest true "要解除的地址"
+// @Success 200 {object} map[string]interface{} "解除成功"
+// @Failure 400 {object} map[string]interface{} "参数错误"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/containers/{name}/ipv6/delete [post]
+func UnbindContainerIPv6(c *gin.Context) {
+	name := c.Param("name")
+	var req models.IPv6UnbindRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误: " + err.Error(),
+		})
+		return
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(req.PublicIPv6))
+	if err != nil || !addr.Is6() || addr.Is4In6() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误: 无效的IPv6地址",
+		})
+		return
+	}
+	node, ok := requestNode(c)
+	if !ok {
+		return
+	}
+
+	client := services.NodeClient(node)
+	res, err := client.DeleteIPv6(c.Request.Context(), name, addr.String())
+	if err != nil {
+		respondNodeError(c, err)
+		return
+	}
+	refreshIPv6Bindings(c, client, node, name)
+	respondNodeSuccess(c, res)
+}
+
+// GetNodeIPv6Pool 获取节点 IPv6 地址池使用情况
+// @Summary 获取节点 IPv6 地址池使用情况
+// @Description 根据节点上报的 ipv6_binding.ipv6_pool 配置和同步得到的绑定缓存统计地址池使用情况
+// @Tags IPv6绑定
+// @Produce json
+// @Param id path string true "节点ID"
+// @Success 200 {object} map[string]interface{} "成功返回地址池使用情况"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/nodes/{id}/ipv6/pool [get]
+func GetNodeIPv6Pool(c *gin.Context) {
+	var node models.Node
+	if err := database.DB.First(&node, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "节点不存在",
+		})
+		return
+	}
+	usage, err := services.BuildIPv6PoolUsage(node)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "读取IPv6绑定缓存失败: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": usage,
+	})
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"lxdweb/database"
+	"lxdweb/middleware"
+	"lxdweb/models"
+	"lxdweb/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLoginLockouts 获取登录锁定列表
+// @Summary 获取登录锁定列表
+// @Description 返回当前被锁定的用户名和 IP，all=1 时包含已过期或已解锁的记录
+// @Tags 系统管理
+// @Produce json
+// @Param all query int false "是否包含已失效的锁定"
+// @Success 200 {object} map[string]interface{} "成功返回锁定列表"
+// @Router /api/security/lockouts [get]
+func GetLoginLockouts(c *gin.Context) {
+	query := database.DB.Order("locked_until desc")
+	if c.Query("all") != "1" {
+		query = query.Where("locked_until > ?", time.Now())
+	}
+
+	var lockouts []models.LoginLockout
+	query.Find(&lockouts)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": lockouts,
+	})
+}
+
+// UnlockLoginLockout 解除登录锁定
+// @Summary 解除登录锁定
+// @Description 提前解除用户名或 IP 的锁定，锁定前的失败记录不再计数
+// @Tags 系统管理
+// @Produce json
+// @Param id path string true "锁定记录ID"
+// @Success 200 {object} map[string]interface{} "解锁成功"
+// @Failure 404 {object} map[string]interface{} "锁定记录不存在"
+// @Router /api/security/lockouts/{id} [delete]
+func UnlockLoginLockout(c *gin.Context) {
+	var lockout models.LoginLockout
+	if err := database.DB.First(&lockout, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "锁定记录不存在",
+		})
+		return
+	}
+
+	by := ""
+	if admin := middleware.CurrentAdmin(c); admin != nil {
+		by = admin.Username
+	}
+	if err := services.UnlockLogin(&lockout, by); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "解锁失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "已解除锁定",
+	})
+}
+
+// GetLoginAttempts 查询登录记录
+// @Summary 查询登录记录
+// @Description 按用户名、IP 和结果筛选登录尝试记录，支持分页
+// @Tags 系统管理
+// @Produce json
+// @Param username query string false "用户名"
+// @Param ip query string false "来源IP"
+// @Param success query string false "结果：true 成功，false 失败"
+// @Param reason query string false "失败原因：captcha、password、2fa、locked"
+// @Param page query int false "页码，默认1"
+// @Param page_size query int false "每页条数，默认20，最大200"
+// @Success 200 {object} map[string]interface{} "成功返回登录记录"
+// @Router /api/security/login-attempts [get]
+func GetLoginAttempts(c *gin.Context) {
+	query := database.DB.Model(&models.LoginAttempt{})
+	if v := c.Query("username"); v != "" {
+		query = query.Where("username = ?", v)
+	}
+	if v := c.Query("ip"); v != "" {
+		query = query.Where("ip_address = ?", v)
+	}
+	if v, err := strconv.ParseBool(c.Query("success")); err == nil {
+		query = query.Where("success = ?", v)
+	}
+	if v := c.Query("reason"); v != "" {
+		query = query.Where("reason = ?", v)
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > 200 {
+		pageSize = 200
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var attempts []models.LoginAttempt
+	query.Order("created_at desc, id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&attempts)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": gin.H{
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+			"items":     attempts,
+		},
+	})
+}
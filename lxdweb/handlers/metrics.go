@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"lxdweb/config"
+	"lxdweb/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var metricsHandler = promhttp.HandlerFor(services.MetricsRegistry, promhttp.HandlerOpts{})
+
+// PrometheusMetrics Prometheus 指标
+// @Summary Prometheus 指标
+// @Description 输出节点、容器、同步任务和 HTTP 请求指标，配置了 prometheus.token 时需携带 Bearer Token
+// @Tags 监控
+// @Produce plain
+// @Success 200 {string} string "Prometheus 文本格式指标"
+// @Failure 401 {object} map[string]interface{} "Token 无效"
+// @Router /metrics [get]
+func PrometheusMetrics(c *gin.Context) {
+	if token := config.AppConfig.Prometheus.Token; token != "" {
+		got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code": 401,
+				"msg":  "Token 无效",
+			})
+			return
+		}
+	}
+	metricsHandler.ServeHTTP(c.Writer, c.Request)
+}
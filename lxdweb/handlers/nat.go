@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"lxdweb/middleware"
+	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+	"lxdweb/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// nodeVisible 返回判断当前管理员能否查看节点的函数，用于隐藏其他节点上的规则信息
+func nodeVisible(c *gin.Context) func(nodeID uint) bool {
+	return func(nodeID uint) bool {
+		return middleware.CanAccessNode(c, nodeID)
+	}
+}
+
+// bindNATRequest 解析并校验端口转发参数，失败时直接写入 400 响应
+func bindNATRequest(c *gin.Context, forDelete bool) (*models.NATRuleRequest, bool) {
+	var req models.NATRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误: " + err.Error(),
+		})
+		return nil, false
+	}
+	if err := services.ValidateNATRequest(&req, forDelete); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误: " + err.Error(),
+		})
+		return nil, false
+	}
+	return &req, true
+}
+
+// refreshNATRules 规则变更后更新 nat_rules 缓存，失败只记录日志，等待下次同步
+func refreshNATRules(c *gin.Context, client *lxdapi.Client, node models.Node, hostname string) {
+	if err := services.RefreshContainerNATRules(c.Request.Context(), client, node, hostname); err != nil {
+		log.Printf("[NAT] 更新容器 %s 的端口转发缓存失败: %v", hostname, err)
+	}
+}
+
+// GetContainerNAT 获取容器端口转发规则
+// @Summary 获取容器端口转发规则
+// @Description 从节点读取容器的 NAT 端口转发规则
+// @Tags 端口转发
+// @Produce json
+// @Param name path string true "容器名称"
+// @Param node_id query string true "节点ID"
+// @Success 200 {object} map[string]interface{} "成功返回规则列表"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/containers/{name}/nat [get]
+func GetContainerNAT(c *gin.Context) {
+	node, ok := requestNode(c)
+	if !ok {
+		return
+	}
+	rules, err := services.NodeClient(node).NATList(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		respondNodeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": rules,
+	})
+}
+
+// CheckContainerNATPort 检查外部端口是否可用
+// @Summary 检查外部端口是否可用
+// @Description 先对比容器已有规则，再请求节点检查端口占用，protocol 为 both 时同时检查 tcp 和 udp
+// @Tags 端口转发
+// @Produce json
+// @Param name path string true "容器名称"
+// @Param node_id query string true "节点ID"
+// @Param protocol query string true "协议: tcp | udp | both"
+// @Param port query int true "外部端口"
+// @Success 200 {object} map[string]interface{} "data.available 表示是否可用"
+// @Failure 400 {object} map[string]interface{} "参数错误"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/containers/{name}/nat/check [get]
+func CheckContainerNATPort(c *gin.Context) {
+	port, _ := strconv.Atoi(c.Query("port"))
+	req := models.NATRuleRequest{
+		Protocol:     c.Query("protocol"),
+		ExternalPort: port,
+		InternalPort: 1,
+	}
+	if err := services.ValidateNATRequest(&req, true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误: " + err.Error(),
+		})
+		return
+	}
+	node, ok := requestNode(c)
+	if !ok {
+		return
+	}
+
+	err := services.CheckNATConflict(c.Request.Context(), services.NodeClient(node), node, c.Param("name"), &req, nodeVisible(c))
+	if conflict, isConflict := services.AsNATConflict(err); isConflict {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 200,
+			"msg":  conflict.Error(),
+			"data": gin.H{"available": false, "reason": conflict.Reason},
+		})
+		return
+	}
+	if err != nil {
+		respondNodeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "端口可用",
+		"data": gin.H{"available": true, "reason": ""},
+	})
+}
+
+// AddContainerNAT 添加端口转发规则
+// @Summary 添加端口转发规则
+// @Description 检查外部端口冲突后通过节点添加规则，外部端口留空时由节点自动分配，支持端口段
+// @Tags 端口转发
+// @Accept json
+// @Produce json
+// @Param name path string true "容器名称"
+// @Param node_id query string true "节点ID"
+// @Param body body models.NATRuleRequest true "转发规则"
+// @Success 200 {object} map[string]interface{} "添加成功"
+// @Failure 400 {object} map[string]interface{} "参数错误"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/containers/{name}/nat [post]
+func AddContainerNAT(c *gin.Context) {
+	name := c.Param("name")
+	req, ok := bindNATRequest(c, false)
+	if !ok {
+		return
+	}
+	node, ok := requestNode(c)
+	if !ok {
+		return
+	}
+
+	client := services.NodeClient(node)
+	if err := services.CheckNATConflict(c.Request.Context(), client, node, name, req, nodeVisible(c)); err != nil {
+		if conflict, isConflict := services.AsNATConflict(err); isConflict {
+			c.JSON(http.StatusOK, gin.H{
+				"code": 409,
+				"msg":  conflict.Error(),
+			})
+			return
+		}
+		respondNodeError(c, err)
+		return
+	}
+
+	res, err := client.AddNAT(c.Request.Context(), services.NATRequestFor(name, req.Protocol, req))
+	if err != nil {
+		respondNodeError(c, err)
+		return
+	}
+	refreshNATRules(c, client, node, name)
+	respondNodeSuccess(c, res)
+}
+
+// DeleteContainerNAT 删除端口转发规则
+// @Summary 删除端口转发规则
+// @Description 通过节点删除规则，protocol 为 both 时分别删除 tcp 和 udp 规则，其中一个删除失败时重新添加已删除的规则
+// @Tags 端口转发
+// @Accept json
+// @Produce json
+// @Param name path string true "容器名称"
+// @Param node_id query string true "节点ID"
+// @Param body body models.NATRuleRequest true "要删除的规则"
+// @Success 200 {object} map[string]interface{} "删除成功"
+// @Failure 400 {object} map[string]interface{} "参数错误"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/containers/{name}/nat/delete [post]
+func DeleteContainerNAT(c *gin.Context) {
+	name := c.Param("name")
+	req, ok := bindNATRequest(c, true)
+	if !ok {
+		return
+	}
+	node, ok := requestNode(c)
+	if !ok {
+		return
+	}
+
+	client := services.NodeClient(node)
+	protocols := services.NATProtocols(req.Protocol)
+	var existing []lxdapi.NATRule
+	if len(protocols) > 1 {
+		// 回滚时用节点上原有规则的描述重新添加
+		existing, _ = client.NATList(c.Request.Context(), name)
+	}
+
+	var msgs []string
+	var res *lxdapi.Result
+	var deleted []*lxdapi.NATRequest
+	for _, protocol := range protocols {
+		natReq := services.NATRequestFor(name, protocol, req)
+		r, err := client.DeleteNAT(c.Request.Context(), natReq)
+		if err != nil {
+			if len(deleted) == 0 {
+				respondNodeError(c, err)
+				return
+			}
+			code := 500
+			if apiErr, ok := lxdapi.AsAPIError(err); ok {
+				code = apiErr.Code
+			}
+			msg := fmt.Sprintf("删除 %s 规则失败: %s，%s", protocol, nodeErrorMsg(err), restoreNATRules(c, client, deleted, existing))
+			refreshNATRules(c, client, node, name)
+			c.JSON(http.StatusOK, gin.H{
+				"code": code,
+				"msg":  msg,
+			})
+			return
+		}
+		deleted = append(deleted, natReq)
+		msgs = append(msgs, r.Msg)
+		res = r
+	}
+	refreshNATRules(c, client, node, name)
+	respondNodeSuccess(c, &lxdapi.Result{Msg: strings.Join(msgs, "; "), Data: res.Data})
+}
+
+// restoreNATRules 重新添加已删除的规则，返回回滚结果说明
+func restoreNATRules(c *gin.Context, client *lxdapi.Client, deleted []*lxdapi.NATRequest, existing []lxdapi.NATRule) string {
+	var restored, failed []string
+	for _, natReq := range deleted {
+		for _, rule := range existing {
+			if rule.Protocol == natReq.Protocol && rule.ExternalPort == natReq.ExternalPort {
+				natReq.Description = rule.Description
+				break
+			}
+		}
+		if _, err := client.AddNAT(c.Request.Context(), natReq); err != nil {
+			log.Printf("[NAT] 恢复容器 %s 的 %s 规则 %d 失败: %v", natReq.Hostname, natReq.Protocol, natReq.ExternalPort, err)
+			failed = append(failed, fmt.Sprintf("%s（%s）", natReq.Protocol, nodeErrorMsg(err)))
+			continue
+		}
+		restored = append(restored, natReq.Protocol)
+	}
+	if len(failed) > 0 {
+		return "恢复已删除的 " + strings.Join(failed, "、") + " 规则失败，请手动检查"
+	}
+	return "已恢复已删除的 " + strings.Join(restored, "、") + " 规则"
+}
+
+// natPortMap 读取节点的端口分配视图，同一 IP 上无权访问的节点只保留端口占用，不显示节点ID和容器信息
+func natPortMap(c *gin.Context) (*services.NATPortMap, bool) {
+	node, ok := requestNode(c)
+	if !ok {
+		return nil, false
+	}
+	portMap, err := services.BuildNATPortMap(node)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "读取端口转发缓存失败: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	nodeIDs := make([]uint, 0, len(portMap.NodeIDs))
+	for _, id := range portMap.NodeIDs {
+		if middleware.CanAccessNode(c, id) {
+			nodeIDs = append(nodeIDs, id)
+		}
+	}
+	portMap.NodeIDs = nodeIDs
+
+	redact := func(u *services.NATPortUsage) {
+		if !middleware.CanAccessNode(c, u.NodeID) {
+			u.NodeID = 0
+			u.NodeName = ""
+			u.Hostname = ""
+			u.InternalPort = 0
+			u.Description = ""
+		}
+	}
+	for _, used := range portMap.Used {
+		for i := range used {
+			redact(&used[i])
+		}
+	}
+	for i := range portMap.Conflicts {
+		redact(&portMap.Conflicts[i].First)
+		redact(&portMap.Conflicts[i].Second)
+	}
+	return portMap, true
+}
+
+// GetNATPorts 获取节点端口分配视图
+// @Summary 获取节点端口分配视图
+// @Description 根据同步得到的 nat_rules 缓存返回节点 IP 上 tcp/udp 已用和空闲的外部端口段，以及端口重叠的规则。使用同一 IP 的节点合并计算
+// @Tags 端口转发
+// @Produce json
+// @Param node_id query string true "节点ID"
+// @Success 200 {object} map[string]interface{} "成功返回端口分配视图"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/nat/ports [get]
+func GetNATPorts(c *gin.Context) {
+	portMap, ok := natPortMap(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": portMap,
+	})
+}
+
+// AllocateNATPorts 推荐空闲外部端口
+// @Summary 推荐空闲外部端口
+// @Description 根据 nat_rules 缓存推荐空闲的外部端口，结果仅供参考，添加规则时仍会请求节点检查
+// @Tags 端口转发
+// @Produce json
+// @Param node_id query string true "节点ID"
+// @Param protocol query string false "协议: tcp | udp | both，默认 tcp"
+// @Param count query int false "推荐数量，默认1，最大100"
+// @Param contiguous query bool false "是否要求端口连续，用于端口段规则"
+// @Param from query int false "从该端口开始查找"
+// @Success 200 {object} map[string]interface{} "成功返回推荐端口"
+// @Failure 400 {object} map[string]interface{} "参数错误"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/nat/ports/allocate [get]
+func AllocateNATPorts(c *gin.Context) {
+	protocol := strings.ToLower(strings.TrimSpace(c.DefaultQuery("protocol", "tcp")))
+	count, _ := strconv.Atoi(c.DefaultQuery("count", "1"))
+	from, _ := strconv.Atoi(c.Query("from"))
+	contiguous := c.Query("contiguous") == "true" || c.Query("contiguous") == "1"
+
+	portMap, ok := natPortMap(c)
+	if !ok {
+		return
+	}
+	ports, err := services.AllocateNATPorts(portMap, protocol, count, contiguous, from)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": gin.H{
+			"node_id":   portMap.NodeID,
+			"node_ip":   portMap.NodeIP,
+			"protocol":  protocol,
+			"ports":     ports,
+			"last_sync": portMap.LastSync,
+		},
+	})
+}
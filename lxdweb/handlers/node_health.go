@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"lxdweb/database"
+	"lxdweb/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxHealthPoints = 500
+
+// GetNodeHealth 获取节点健康记录
+// @Summary 获取节点健康记录
+// @Description 返回指定时间范围内的健康检查记录及可用率，记录过多时按时间段聚合
+// @Tags 节点管理
+// @Produce json
+// @Param id path string true "节点ID"
+// @Param hours query int false "时间范围（小时），默认24，最大168"
+// @Success 200 {object} map[string]interface{} "成功返回健康记录"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/nodes/{id}/health [get]
+func GetNodeHealth(c *gin.Context) {
+	var node models.Node
+	if err := database.DB.First(&node, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "节点不存在",
+		})
+		return
+	}
+
+	hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if hours <= 0 {
+		hours = 24
+	}
+	if hours > 168 {
+		hours = 168
+	}
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+
+	var checks []models.NodeHealthCheck
+	database.DB.Where("node_id = ? AND checked_at >= ?", node.ID, since).Order("checked_at asc").Find(&checks)
+
+	successCount := 0
+	var latencySum int64
+	for _, check := range checks {
+		if check.Success {
+			successCount++
+			latencySum += check.LatencyMs
+		}
+	}
+
+	uptime := 0.0
+	avgLatency := int64(0)
+	if len(checks) > 0 {
+		uptime = float64(successCount) * 100 / float64(len(checks))
+	}
+	if successCount > 0 {
+		avgLatency = latencySum / int64(successCount)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": gin.H{
+			"status":      node.Status,
+			"latency_ms":  node.LatencyMs,
+			"fail_count":  node.FailCount,
+			"last_check":  node.LastCheck,
+			"total":       len(checks),
+			"uptime":      uptime,
+			"avg_latency": avgLatency,
+			"points":      downsampleHealth(checks),
+		},
+	})
+}
+
+// downsampleHealth 将记录聚合为不超过 maxHealthPoints 个点：延迟取成功记录的平均值，状态取区间内最差的一次
+func downsampleHealth(checks []models.NodeHealthCheck) []gin.H {
+	step := (len(checks) + maxHealthPoints - 1) / maxHealthPoints
+	if step < 1 {
+		step = 1
+	}
+
+	severity := map[string]int{"active": 0, "inactive": 1, "degraded": 2, "error": 3}
+	points := make([]gin.H, 0, len(checks)/step+1)
+	for i := 0; i < len(checks); i += step {
+		end := i + step
+		if end > len(checks) {
+			end = len(checks)
+		}
+
+		status := checks[i].Status
+		failed := 0
+		okCount := 0
+		var latency int64
+		lastError := ""
+		for _, check := range checks[i:end] {
+			if severity[check.Status] > severity[status] {
+				status = check.Status
+			}
+			if check.Success {
+				okCount++
+				latency += check.LatencyMs
+			} else {
+				failed++
+				lastError = check.Error
+			}
+		}
+
+		var avg interface{}
+		if okCount > 0 {
+			avg = latency / int64(okCount)
+		}
+		points = append(points, gin.H{
+			"time":       checks[end-1].CheckedAt,
+			"status":     status,
+			"latency_ms": avg,
+			"failed":     failed,
+			"error":      lastError,
+		})
+	}
+	return points
+}
@@ -1,11 +1,13 @@
 package handlers
 import (
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"fmt"
 	"lxdweb/database"
+	"lxdweb/middleware"
 	"lxdweb/models"
 	"lxdweb/pkg/logger"
+	"lxdweb/pkg/lxdapi"
 	"lxdweb/services"
 	"net/http"
 	"strconv"
@@ -91,7 +93,7 @@ func ContainerDetailPage(c *gin.Context) {
 // @Router /api/nodes [get]
 func GetNodes(c *gin.Context) {
 	var nodes []models.Node
-	if err := database.DB.Order("created_at desc").Find(&nodes).Error; err != nil {
+	if err := middleware.ScopeNodes(c, database.DB, "id").Order("created_at desc").Find(&nodes).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
 			"msg":  "查询失败",
@@ -106,26 +108,35 @@ func GetNodes(c *gin.Context) {
 		cacheMap[cache.NodeID] = cache
 	}
 	
+	showSecrets := middleware.CanViewNodeSecrets(c)
 	result := make([]map[string]interface{}, 0, len(nodes))
 	for _, node := range nodes {
+		apiKey := ""
+		if showSecrets {
+			apiKey = services.MaskNodeAPIKey(node)
+		}
 		nodeData := map[string]interface{}{
-			"id":          node.ID,
-			"name":        node.Name,
-			"description": node.Description,
-			"address":     node.Address,
-			"api_key":     node.APIKey,
-			"status":      node.Status,
-			"last_check":  node.LastCheck,
-			"created_at":  node.CreatedAt,
-			"updated_at":  node.UpdatedAt,
+			"id":              node.ID,
+			"name":            node.Name,
+			"description":     node.Description,
+			"address":         node.Address,
+			"api_key":         apiKey,
+			"status":          node.Status,
+			"tls_mode":        node.TLSMode,
+			"tls_fingerprint": node.TLSFingerprint,
+			"last_check":      node.LastCheck,
+			"created_at":      node.CreatedAt,
+			"updated_at":      node.UpdatedAt,
 		}
 
-		if cache, ok := cacheMap[node.ID]; ok {
+		cache, hasCache := cacheMap[node.ID]
+		if hasCache {
 			var sysInfo map[string]interface{}
 			if err := json.Unmarshal([]byte(cache.SystemInfo), &sysInfo); err == nil {
 				nodeData["system_info"] = sysInfo
 			}
 		}
+		nodeData["circuit"] = nodeCircuitData(node.ID, cache, hasCache)
 		
 		result = append(result, nodeData)
 	}
@@ -155,10 +166,17 @@ func GetNode(c *gin.Context) {
 		})
 		return
 	}
+	apiKey := ""
+	if middleware.CanViewNodeSecrets(c) {
+		apiKey = services.MaskNodeAPIKey(node)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"msg":  "success",
-		"data": node,
+		"data": struct {
+			models.Node
+			APIKey string `json:"api_key"`
+		}{node, apiKey},
 	})
 }
 // CreateNode 创建节点
@@ -208,6 +226,15 @@ func CreateNode(c *gin.Context) {
 		return
 	}
 	
+	tlsMode, tlsFingerprint, err := services.NormalizeNodeTrust(req.Address, req.TLSMode, req.TLSFingerprint, req.TLSCACert)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	
 	syncInterval := req.SyncInterval
 	if syncInterval <= 0 {
 		syncInterval = 300
@@ -223,16 +250,32 @@ func CreateNode(c *gin.Context) {
 		batchInterval = 5
 	}
 	
+	apiKey, err := services.EncryptSecret(req.APIKey)
+	if err != nil {
+		logger.Global.Error(ctx, "加密节点API密钥失败",
+			zap.Error(err),
+			zap.String("name", req.Name),
+			zap.String("action", "create_node"))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "加密API密钥失败",
+		})
+		return
+	}
+	
 	node := models.Node{
-		Name:          req.Name,
-		Description:   req.Description,
-		Address:       req.Address,
-		APIKey:        req.APIKey,
-		Status:        "inactive",
-		AutoSync:      req.AutoSync,
-		SyncInterval:  syncInterval,
-		BatchSize:     batchSize,
-		BatchInterval: batchInterval,
+		Name:           req.Name,
+		Description:    req.Description,
+		Address:        req.Address,
+		APIKey:         apiKey,
+		Status:         "inactive",
+		AutoSync:       req.AutoSync,
+		SyncInterval:   syncInterval,
+		BatchSize:      batchSize,
+		BatchInterval:  batchInterval,
+		TLSMode:        tlsMode,
+		TLSFingerprint: tlsFingerprint,
+		TLSCACert:      req.TLSCACert,
 	}
 	
 	logger.Global.Debug(ctx, "准备创建节点",
@@ -259,9 +302,25 @@ func CreateNode(c *gin.Context) {
 		zap.String("name", node.Name),
 		zap.String("action", "create_node"))
 	
+	msg := "创建成功"
+	pinCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if pinned, err := services.PinNodeFingerprint(pinCtx, &node); err != nil {
+		logger.Global.Warn(ctx, "获取节点证书指纹失败",
+			zap.Error(err),
+			zap.Uint("node_id", node.ID),
+			zap.String("action", "create_node"))
+		msg = "创建成功，但获取证书指纹失败，确认指纹前节点不会同步，请稍后测试连接: " + err.Error()
+	} else if pinned {
+		logger.Global.Info(ctx, "已固定节点证书指纹",
+			zap.Uint("node_id", node.ID),
+			zap.String("fingerprint", node.TLSFingerprint),
+			zap.String("action", "create_node"))
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
-		"msg":  "创建成功",
+		"msg":  msg,
 		"data": node,
 	})
 }
@@ -334,7 +393,19 @@ func UpdateNode(c *gin.Context) {
 		updates["address"] = req.Address
 	}
 	if req.APIKey != "" {
-		updates["api_key"] = req.APIKey
+		encrypted, err := services.EncryptSecret(req.APIKey)
+		if err != nil {
+			logger.Global.Error(ctx, "加密节点API密钥失败",
+				zap.Error(err),
+				zap.Uint("node_id", node.ID),
+				zap.String("action", "update_node"))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": 500,
+				"msg":  "加密API密钥失败",
+			})
+			return
+		}
+		updates["api_key"] = encrypted
 	}
 	
 	updates["auto_sync"] = req.AutoSync
@@ -350,6 +421,46 @@ func UpdateNode(c *gin.Context) {
 		updates["batch_interval"] = req.BatchInterval
 	}
 	
+	if req.Address != "" || req.TLSMode != "" || req.TLSFingerprint != "" || req.TLSCACert != "" || req.ResetTLSFingerprint {
+		address := req.Address
+		if address == "" {
+			address = node.Address
+		}
+		mode := req.TLSMode
+		if mode == "" && !services.IsPlainHTTP(address) {
+			mode = node.TLSMode
+		}
+		caCert := req.TLSCACert
+		if caCert == "" {
+			caCert = node.TLSCACert
+		}
+		tlsMode, tlsFingerprint, err := services.NormalizeNodeTrust(address, mode, req.TLSFingerprint, caCert)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		updates["tls_mode"] = tlsMode
+		if tlsFingerprint != "" {
+			updates["tls_fingerprint"] = tlsFingerprint
+		}
+		if req.ResetTLSFingerprint {
+			updates["tls_fingerprint"] = ""
+			logger.Global.Info(ctx, "重置节点证书指纹",
+				zap.Uint("node_id", node.ID),
+				zap.String("action", "update_node"))
+		}
+		if tlsMode == "" {
+			// http 地址不使用 TLS，不保留证书指纹
+			updates["tls_fingerprint"] = ""
+		}
+		if req.TLSCACert != "" {
+			updates["tls_ca_cert"] = req.TLSCACert
+		}
+	}
+	
 	if err := database.DB.Model(&node).Updates(updates).Error; err != nil {
 		logger.Global.Error(ctx, "数据库更新节点失败",
 			zap.Error(err),
@@ -404,27 +515,7 @@ func DeleteNode(c *gin.Context) {
 		zap.String("action", "delete_node"))
 	
 	err = database.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Unscoped().Where("node_id = ?", nodeID).Delete(&models.Container{}).Error; err != nil {
-			return fmt.Errorf("删除容器数据失败: %w", err)
-		}
-		
-		if err := tx.Unscoped().Where("node_id = ?", nodeID).Delete(&models.ContainerCache{}).Error; err != nil {
-			return fmt.Errorf("删除容器缓存失败: %w", err)
-		}
-		
-		if err := tx.Unscoped().Where("node_id = ?", nodeID).Delete(&models.NodeInfoCache{}).Error; err != nil {
-			return fmt.Errorf("删除节点缓存失败: %w", err)
-		}
-		
-		if err := tx.Unscoped().Where("node_id = ?", nodeID).Delete(&models.SyncTask{}).Error; err != nil {
-			return fmt.Errorf("删除同步任务失败: %w", err)
-		}
-		
-		if err := tx.Unscoped().Delete(&models.Node{}, id).Error; err != nil {
-			return fmt.Errorf("删除节点失败: %w", err)
-		}
-		
-		return nil
+		return deleteNodeCascade(tx, uint(nodeID))
 	})
 	
 	if err != nil {
@@ -439,6 +530,8 @@ func DeleteNode(c *gin.Context) {
 		return
 	}
 	
+	services.ReleaseNodeClient(uint(nodeID))
+	
 	logger.Global.Info(ctx, "节点删除成功",
 		zap.Uint64("node_id", nodeID),
 		zap.String("action", "delete_node"))
@@ -448,6 +541,51 @@ func DeleteNode(c *gin.Context) {
 		"msg":  "删除成功",
 	})
 }
+
+// deleteNodeCascade 删除节点及其容器、缓存、监控、流量、配额、授权和同步任务等关联数据
+func deleteNodeCascade(tx *gorm.DB, nodeID uint) error {
+	if err := tx.Unscoped().Where("node_id = ?", nodeID).Delete(&models.Container{}).Error; err != nil {
+		return fmt.Errorf("删除容器数据失败: %w", err)
+	}
+	if err := tx.Unscoped().Where("node_id = ?", nodeID).Delete(&models.ContainerCache{}).Error; err != nil {
+		return fmt.Errorf("删除容器缓存失败: %w", err)
+	}
+	if err := tx.Where("node_id = ?", nodeID).Delete(&models.NATRuleCache{}).Error; err != nil {
+		return fmt.Errorf("删除端口转发缓存失败: %w", err)
+	}
+	if err := tx.Where("node_id = ?", nodeID).Delete(&models.IPv6BindingCache{}).Error; err != nil {
+		return fmt.Errorf("删除IPv6绑定缓存失败: %w", err)
+	}
+	if err := tx.Unscoped().Where("node_id = ?", nodeID).Delete(&models.NodeInfoCache{}).Error; err != nil {
+		return fmt.Errorf("删除节点缓存失败: %w", err)
+	}
+	if err := tx.Where("node_id = ?", nodeID).Delete(&models.NodeHealthCheck{}).Error; err != nil {
+		return fmt.Errorf("删除健康记录失败: %w", err)
+	}
+	if err := tx.Where("node_id = ?", nodeID).Delete(&models.ContainerMetric{}).Error; err != nil {
+		return fmt.Errorf("删除监控历史失败: %w", err)
+	}
+	if err := tx.Where("node_id = ?", nodeID).Delete(&models.TrafficLedger{}).Error; err != nil {
+		return fmt.Errorf("删除流量账本失败: %w", err)
+	}
+	if err := tx.Where("node_id = ?", nodeID).Delete(&models.AdminNode{}).Error; err != nil {
+		return fmt.Errorf("删除节点授权失败: %w", err)
+	}
+	if err := tx.Where("node_id = ?", nodeID).Delete(&models.TrafficQuota{}).Error; err != nil {
+		return fmt.Errorf("删除流量配额失败: %w", err)
+	}
+	if err := tx.Where("node_id = ?", nodeID).Delete(&models.TrafficQuotaEvent{}).Error; err != nil {
+		return fmt.Errorf("删除配额事件失败: %w", err)
+	}
+	if err := tx.Unscoped().Where("node_id = ?", nodeID).Delete(&models.SyncTask{}).Error; err != nil {
+		return fmt.Errorf("删除同步任务失败: %w", err)
+	}
+	if err := tx.Unscoped().Delete(&models.Node{}, nodeID).Error; err != nil {
+		return fmt.Errorf("删除节点失败: %w", err)
+	}
+	return nil
+}
+
 // TestNode 测试节点连接
 // @Summary 测试节点连接
 // @Description 测试与LXD节点的连接状态
@@ -459,58 +597,54 @@ func DeleteNode(c *gin.Context) {
 // @Failure 500 {object} map[string]interface{} "连接失败"
 // @Router /api/nodes/{id}/test [post]
 func TestNode(c *gin.Context) {
-	id := c.Param("id")
-	idInt, _ := strconv.ParseUint(id, 10, 32)
 	var node models.Node
-	if err := database.DB.First(&node, id).Error; err != nil {
+	if err := database.DB.First(&node, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"code": 404,
 			"msg":  "节点不存在",
 		})
 		return
 	}
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	}
-	req, err := http.NewRequest("GET", node.Address+"/api/check", nil)
-	if err != nil {
-		updateNodeStatus(uint(idInt), "error")
-		c.JSON(http.StatusOK, gin.H{
-			"code": 500,
-			"msg":  "连接失败: " + err.Error(),
-		})
-		return
-	}
-	if node.APIKey != "" {
-		req.Header.Set("apikey", node.APIKey)
-	}
-	resp, err := client.Do(req)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	trust, pinned, err := services.TestNodeConnection(ctx, node)
 	if err != nil {
-		updateNodeStatus(uint(idInt), "error")
+		msg := "连接失败: " + err.Error()
+		if mismatch, ok := lxdapi.AsFingerprintMismatch(err); ok {
+			logger.Global.Warn(ctx, "节点证书指纹已变更，拒绝连接",
+				zap.Uint("node_id", node.ID),
+				zap.String("expected", mismatch.Expected),
+				zap.String("actual", mismatch.Actual),
+				zap.String("action", "test_node"))
+			msg = fmt.Sprintf("节点证书已变更，拒绝连接。当前证书指纹: %s，确认无误后请在节点设置中重置指纹", mismatch.Actual)
+		} else if apiErr, ok := lxdapi.AsAPIError(err); ok {
+			msg = "连接失败: " + apiErr.Msg
+		}
 		c.JSON(http.StatusOK, gin.H{
 			"code": 500,
-			"msg":  "连接失败: " + err.Error(),
+			"msg":  msg,
 		})
 		return
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == 200 {
-		updateNodeStatus(uint(idInt), "active")
-		go services.RefreshNodeCache(uint(idInt))
-		c.JSON(http.StatusOK, gin.H{
-			"code": 200,
-			"msg":  "连接成功",
-		})
-	} else {
-		updateNodeStatus(uint(idInt), "error")
-		c.JSON(http.StatusOK, gin.H{
-			"code": 500,
-			"msg":  fmt.Sprintf("连接失败: HTTP %d", resp.StatusCode),
-		})
+
+	if pinned {
+		logger.Global.Info(ctx, "已固定节点证书指纹",
+			zap.Uint("node_id", node.ID),
+			zap.String("fingerprint", trust.Fingerprint),
+			zap.String("action", "test_node"))
 	}
+
+	go services.RefreshNodeCache(node.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "连接成功",
+		"data": gin.H{
+			"tls_mode":           trust.Mode,
+			"tls_fingerprint":    trust.Fingerprint,
+			"fingerprint_pinned": pinned,
+		},
+	})
 }
 // RefreshNodeCache 刷新节点缓存
 // @Summary 刷新节点缓存
@@ -543,17 +677,45 @@ func RefreshNodeCache(c *gin.Context) {
 	})
 }
 
-func updateNodeStatus(nodeID uint, status string) {
-	now := time.Now()
-	database.DB.Model(&models.Node{}).Where("id = ?", nodeID).Updates(map[string]interface{}{
-		"status":     status,
-		"last_check": now,
-	})
+// nodeCircuitData 优先返回内存中的熔断器状态，服务重启后尚未请求过的节点使用缓存表中的记录
+func nodeCircuitData(nodeID uint, cache models.NodeInfoCache, hasCache bool) lxdapi.BreakerSnapshot {
+	if snap, ok := services.NodeCircuit(nodeID); ok {
+		return snap
+	}
+	snap := lxdapi.BreakerSnapshot{State: lxdapi.BreakerClosed}
+	if hasCache && cache.CircuitState != "" {
+		snap.State = lxdapi.BreakerState(cache.CircuitState)
+		snap.Failures = cache.CircuitFailures
+		snap.OpenedAt = cache.CircuitOpenedAt
+		snap.LastError = cache.CircuitLastError
+	}
+	return snap
 }
 
+
+// ExportNodes 导出节点
+// @Summary 导出节点
+// @Description 生成带版本号和校验和的节点导出文件。设置口令时导出文件加密、以口令派生的密钥签名（HMAC-SHA256）并包含 API 密钥，否则不含 API 密钥且不签名
+// @Tags 节点管理
+// @Accept json
+// @Produce json
+// @Param request body models.ExportNodesRequest false "导出参数"
+// @Success 200 {object} map[string]interface{} "导出文件"
+// @Router /api/nodes/export/all [post]
 func ExportNodes(c *gin.Context) {
+	var req models.ExportNodesRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "参数错误: " + err.Error(),
+			})
+			return
+		}
+	}
+
 	var nodes []models.Node
-	if err := database.DB.Find(&nodes).Error; err != nil {
+	if err := middleware.ScopeNodes(c, database.DB, "id").Order("id").Find(&nodes).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": 500,
 			"msg":  "查询失败",
@@ -561,32 +723,37 @@ func ExportNodes(c *gin.Context) {
 		return
 	}
 
-	exportData := make([]map[string]interface{}, 0, len(nodes))
-	for _, node := range nodes {
-		exportData = append(exportData, map[string]interface{}{
-			"name":           node.Name,
-			"address":        node.Address,
-			"api_key":        node.APIKey,
-			"description":    node.Description,
-			"auto_sync":      node.AutoSync,
-			"sync_interval":  node.SyncInterval,
-			"batch_size":     node.BatchSize,
-			"batch_interval": node.BatchInterval,
+	bundle, err := services.ExportNodeBundle(nodes, req.Passphrase)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "导出失败: " + err.Error(),
 		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"msg":  "success",
-		"data": exportData,
+		"data": bundle,
 	})
 }
 
+// ImportNodes 导入节点
+// @Summary 导入节点
+// @Description 校验导出文件的版本、校验和与签名，按冲突策略（skip 跳过、overwrite 覆盖、rename 重命名）导入，导入前请求节点 /api/check 验证连接，验证失败的节点不导入；未提供 API 密钥或 skip_check 时节点未经验证，需要 allow_unchecked 才会导入。dry_run 时只返回导入计划
+// @Tags 节点管理
+// @Accept json
+// @Produce json
+// @Param request body models.ImportNodesRequest true "导入参数"
+// @Success 200 {object} map[string]interface{} "导入结果"
+// @Failure 400 {object} map[string]interface{} "参数错误或导出文件无效"
+// @Router /api/nodes/import/batch [post]
 func ImportNodes(c *gin.Context) {
 	ctx := c.Request.Context()
-	
-	var importData []map[string]interface{}
-	if err := c.ShouldBindJSON(&importData); err != nil {
+
+	var req models.ImportNodesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Global.Error(ctx, "导入节点参数解析失败",
 			zap.Error(err),
 			zap.String("action", "import_nodes"))
@@ -597,82 +764,72 @@ func ImportNodes(c *gin.Context) {
 		return
 	}
 
-	logger.Global.Info(ctx, "开始批量导入节点",
-		zap.Int("total", len(importData)),
-		zap.String("action", "import_nodes"))
-	
-	successCount := 0
-	failedCount := 0
-	var errors []string
-
-	for _, nodeData := range importData {
-		name, _ := nodeData["name"].(string)
-		address, _ := nodeData["address"].(string)
-		
-		if name == "" || address == "" {
-			failedCount++
-			errors = append(errors, fmt.Sprintf("节点数据缺少必填字段"))
-			continue
-		}
-
-		var existingNode models.Node
-		if err := database.DB.Unscoped().Where("name = ?", name).First(&existingNode).Error; err == nil {
-			failedCount++
-			errors = append(errors, fmt.Sprintf("节点 %s 已存在", name))
-			continue
-		}
+	if !services.ValidImportStrategy(req.Strategy) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "冲突策略无效，可选 skip、overwrite、rename",
+		})
+		return
+	}
 
-		autoSync, _ := nodeData["auto_sync"].(bool)
-		syncInterval, _ := nodeData["sync_interval"].(float64)
-		if syncInterval == 0 {
-			syncInterval = 300
-		}
+	parsed, err := services.ParseNodeBundle(req.Bundle, req.Passphrase)
+	if err != nil {
+		logger.Global.Warn(ctx, "导出文件校验失败",
+			zap.Error(err),
+			zap.String("action", "import_nodes"))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  err.Error(),
+		})
+		return
+	}
 
-		batchSize, _ := nodeData["batch_size"].(float64)
-		if batchSize == 0 {
-			batchSize = 5
-		}
-		
-		batchInterval, _ := nodeData["batch_interval"].(float64)
-		if batchInterval == 0 {
-			batchInterval = 5
-		}
+	logger.Global.Info(ctx, "开始导入节点",
+		zap.Int("total", len(parsed.Nodes)),
+		zap.Int("version", parsed.Version),
+		zap.Bool("signed", parsed.Signed),
+		zap.String("strategy", req.Strategy),
+		zap.Bool("dry_run", req.DryRun),
+		zap.String("action", "import_nodes"))
 
-		apiKey, _ := nodeData["api_key"].(string)
-		description, _ := nodeData["description"].(string)
+	result, err := services.ImportNodeBundle(ctx, parsed.Nodes, req.Strategy, req.DryRun, req.SkipCheck, req.AllowUnchecked)
+	if err != nil {
+		logger.Global.Error(ctx, "导入节点失败",
+			zap.Error(err),
+			zap.String("action", "import_nodes"))
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "导入失败: " + err.Error(),
+		})
+		return
+	}
+	result.Version = parsed.Version
+	result.Signed = parsed.Signed
 
-		node := models.Node{
-			Name:          name,
-			Address:       address,
-			APIKey:        apiKey,
-			Description:   description,
-			Status:        "inactive",
-			AutoSync:      autoSync,
-			SyncInterval:  int(syncInterval),
-			BatchSize:     int(batchSize),
-			BatchInterval: int(batchInterval),
-		}
+	logger.Global.Info(ctx, "导入节点完成",
+		zap.Int("success", result.SuccessCount),
+		zap.Int("skipped", result.SkippedCount),
+		zap.Int("failed", result.FailedCount),
+		zap.Int("unchecked", result.UncheckedCount),
+		zap.Bool("dry_run", req.DryRun),
+		zap.String("action", "import_nodes"))
 
-		if err := database.DB.Create(&node).Error; err != nil {
-			failedCount++
-			errors = append(errors, fmt.Sprintf("节点 %s 创建失败: %v", name, err))
-			continue
+	prefix := "导入完成"
+	if req.DryRun {
+		prefix = "预览完成"
+	}
+	msg := fmt.Sprintf("%s：成功 %d 个，跳过 %d 个，失败 %d 个", prefix, result.SuccessCount, result.SkippedCount, result.FailedCount)
+	if result.UncheckedCount > 0 {
+		if req.AllowUnchecked {
+			msg += fmt.Sprintf("；其中 %d 个节点未验证连接", result.UncheckedCount)
+		} else {
+			msg += fmt.Sprintf("；%d 个节点未验证连接，未允许导入", result.UncheckedCount)
 		}
-
-		successCount++
 	}
-
-	logger.Global.Info(ctx, "批量导入节点完成",
-		zap.Int("success", successCount),
-		zap.Int("failed", failedCount),
-		zap.String("action", "import_nodes"))
-	
 	c.JSON(http.StatusOK, gin.H{
-		"code":          200,
-		"msg":           fmt.Sprintf("导入完成：成功 %d 个，失败 %d 个", successCount, failedCount),
-		"success_count": successCount,
-		"failed_count":  failedCount,
-		"errors":        errors,
+		"code": 200,
+		"msg":  msg,
+		"data": result,
 	})
 }
 
@@ -703,27 +860,7 @@ func BatchDeleteNodes(c *gin.Context) {
 
 	for _, nodeID := range req.IDs {
 		err := database.DB.Transaction(func(tx *gorm.DB) error {
-			if err := tx.Unscoped().Where("node_id = ?", nodeID).Delete(&models.Container{}).Error; err != nil {
-				return fmt.Errorf("删除容器数据失败: %w", err)
-			}
-			
-			if err := tx.Unscoped().Where("node_id = ?", nodeID).Delete(&models.ContainerCache{}).Error; err != nil {
-				return fmt.Errorf("删除容器缓存失败: %w", err)
-			}
-			
-			if err := tx.Unscoped().Where("node_id = ?", nodeID).Delete(&models.NodeInfoCache{}).Error; err != nil {
-				return fmt.Errorf("删除节点缓存失败: %w", err)
-			}
-			
-			if err := tx.Unscoped().Where("node_id = ?", nodeID).Delete(&models.SyncTask{}).Error; err != nil {
-				return fmt.Errorf("删除同步任务失败: %w", err)
-			}
-			
-			if err := tx.Unscoped().Delete(&models.Node{}, nodeID).Error; err != nil {
-				return fmt.Errorf("删除节点失败: %w", err)
-			}
-			
-			return nil
+			return deleteNodeCascade(tx, nodeID)
 		})
 		
 		if err != nil {
@@ -735,6 +872,7 @@ func BatchDeleteNodes(c *gin.Context) {
 				zap.String("action", "batch_delete_nodes"))
 		} else {
 			successCount++
+			services.ReleaseNodeClient(nodeID)
 			logger.Global.Info(ctx, "批量删除单个节点成功",
 				zap.Uint("node_id", nodeID),
 				zap.String("action", "batch_delete_nodes"))
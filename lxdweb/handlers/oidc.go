@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"lxdweb/services"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// oidcLoginTTL 跳转身份提供方后完成登录的有效期
+const oidcLoginTTL = 10 * time.Minute
+
+// OIDCLogin 单点登录
+// @Summary 单点登录
+// @Description 生成 state、nonce 和 PKCE 参数后跳转到 OIDC 身份提供方，未启用单点登录时返回 404
+// @Tags 认证管理
+// @Success 302 {string} string "跳转到身份提供方"
+// @Failure 404 {string} string "未启用单点登录"
+// @Router /login/oidc [get]
+func OIDCLogin(c *gin.Context) {
+	if !services.OIDCEnabled() {
+		c.String(http.StatusNotFound, "未启用单点登录")
+		return
+	}
+	if lockout, blocked := services.LoginBlocked(c.ClientIP(), ""); blocked {
+		renderLogin(c, http.StatusTooManyRequests, "登录失败次数过多，请于 "+lockout.LockedUntil.Format("15:04:05")+" 后重试")
+		return
+	}
+
+	state, nonce, verifier, err := services.NewOIDCChallenge()
+	if err != nil {
+		renderLogin(c, http.StatusInternalServerError, "单点登录失败，请重试")
+		return
+	}
+	authURL, err := services.OIDCAuthURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("[OIDC] 生成授权地址失败: %v", err)
+		renderLogin(c, http.StatusBadGateway, "无法连接身份提供方，请稍后重试或使用账号密码登录")
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set("oidc_state", state)
+	session.Set("oidc_nonce", nonce)
+	session.Set("oidc_verifier", verifier)
+	session.Set("oidc_expires", time.Now().Add(oidcLoginTTL).Unix())
+	if err := session.Save(); err != nil {
+		renderLogin(c, http.StatusInternalServerError, "单点登录失败，请重试")
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback 单点登录回调
+// @Summary 单点登录回调
+// @Description 校验 state 后用授权码换取并校验 ID Token，按 sub 匹配账号，首次登录时自动创建，并按用户组声明更新角色
+// @Tags 认证管理
+// @Param code query string true "授权码"
+// @Param state query string true "授权请求的 state"
+// @Success 302 {string} string "登录成功，跳转到仪表盘"
+// @Failure 400 {string} string "登录请求已失效"
+// @Failure 401 {string} string "身份校验失败"
+// @Failure 403 {string} string "用户组未授权或账号不存在"
+// @Router /login/oidc/callback [get]
+func OIDCCallback(c *gin.Context) {
+	if !services.OIDCEnabled() {
+		c.String(http.StatusNotFound, "未启用单点登录")
+		return
+	}
+	ip := c.ClientIP()
+	session := sessions.Default(c)
+	state, _ := session.Get("oidc_state").(string)
+	nonce, _ := session.Get("oidc_nonce").(string)
+	verifier, _ := session.Get("oidc_verifier").(string)
+	expires, _ := session.Get("oidc_expires").(int64)
+	// 授权请求只能使用一次
+	clearOIDCLogin(session)
+
+	if lockout, blocked := services.LoginBlocked(ip, ""); blocked {
+		services.RecordLoginFailure(ip, "", services.LoginFailLocked)
+		renderLogin(c, http.StatusTooManyRequests, "登录失败次数过多，请于 "+lockout.LockedUntil.Format("15:04:05")+" 后重试")
+		return
+	}
+	if errCode := c.Query("error"); errCode != "" {
+		services.RecordLoginFailure(ip, "", services.LoginFailOIDC)
+		msg := "身份提供方拒绝了登录请求: " + errCode
+		if desc := c.Query("error_description"); desc != "" {
+			msg += " (" + desc + ")"
+		}
+		renderLogin(c, http.StatusUnauthorized, msg)
+		return
+	}
+	if state == "" || time.Now().Unix() > expires ||
+		subtle.ConstantTimeCompare([]byte(c.Query("state")), []byte(state)) != 1 || c.Query("code") == "" {
+		services.RecordLoginFailure(ip, "", services.LoginFailOIDC)
+		renderLogin(c, http.StatusBadRequest, "登录请求已失效，请重新登录")
+		return
+	}
+
+	identity, err := services.OIDCExchange(c.Request.Context(), c.Query("code"), verifier, nonce)
+	if err != nil {
+		log.Printf("[OIDC] 登录校验失败: %v", err)
+		services.RecordLoginFailure(ip, "", services.LoginFailOIDC)
+		renderLogin(c, http.StatusUnauthorized, "单点登录校验失败，请重试或使用账号密码登录")
+		return
+	}
+	if lockout, blocked := services.LoginBlocked(ip, identity.Username); blocked {
+		services.RecordLoginFailure(ip, identity.Username, services.LoginFailLocked)
+		renderLogin(c, http.StatusTooManyRequests, "登录失败次数过多，请于 "+lockout.LockedUntil.Format("15:04:05")+" 后重试")
+		return
+	}
+
+	admin, err := services.ProvisionOIDCAdmin(identity)
+	if err != nil {
+		services.RecordLoginFailure(ip, identity.Username, services.LoginFailOIDC)
+		if errors.Is(err, services.ErrOIDCNoRole) || errors.Is(err, services.ErrOIDCNotProvisioned) ||
+			errors.Is(err, services.ErrOIDCUsernameTaken) {
+			log.Printf("[OIDC] 拒绝 %s 登录: %v (用户组 %v)", identity.Username, err, identity.Groups)
+			renderLogin(c, http.StatusForbidden, err.Error())
+			return
+		}
+		log.Printf("[OIDC] 创建或更新账号 %s 失败: %v", identity.Username, err)
+		renderLogin(c, http.StatusInternalServerError, "登录失败，请重试")
+		return
+	}
+
+	session.Set("admin_id", admin.ID)
+	session.Set("username", admin.Username)
+	if err := session.Save(); err != nil {
+		renderLogin(c, http.StatusInternalServerError, "登录失败，请重试")
+		return
+	}
+	services.RecordLoginSuccess(ip, admin.Username)
+	c.Redirect(http.StatusFound, "/dashboard")
+}
+
+func clearOIDCLogin(session sessions.Session) {
+	session.Delete("oidc_state")
+	session.Delete("oidc_nonce")
+	session.Delete("oidc_verifier")
+	session.Delete("oidc_expires")
+	session.Save()
+}
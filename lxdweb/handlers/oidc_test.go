@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"lxdweb/config"
+	"lxdweb/database"
+	"lxdweb/models"
+	"lxdweb/services"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	testClientID     = "lxdweb"
+	testClientSecret = "client-secret"
+	testRedirectURL  = "http://lxdweb.test/login/oidc/callback"
+)
+
+// newTestDB 为每个测试创建独立的内存数据库并替换 database.DB
+func newTestDB(t *testing.T) {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	sqlDB, err := sql.Open("sqlite", fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	db, err := gorm.Open(sqlite.Dialector{Conn: sqlDB}, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(
+		&models.Admin{},
+		&models.AdminNode{},
+		&models.AdminSession{},
+		&models.LoginAttempt{},
+		&models.LoginLockout{},
+	); err != nil {
+		t.Fatal(err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = prev
+		sqlDB.Close()
+	})
+}
+
+// testIdentity 模拟身份提供方中的一个用户
+type testIdentity struct {
+	Subject  string
+	Username string
+	Groups   []string
+}
+
+type testGrant struct {
+	identity  testIdentity
+	nonce     string
+	challenge string
+}
+
+// testIssuer 在本地模拟 OIDC 身份提供方，提供发现文档、JWKS、令牌和 userinfo 端点。
+// 用户组只通过 userinfo 返回，以覆盖从 userinfo 补充声明的流程
+type testIssuer struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]testGrant
+	tokens map[string]testIdentity
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &testIssuer{key: key, codes: map[string]testGrant{}, tokens: map[string]testIdentity{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 iss.srv.URL,
+			"authorization_endpoint": iss.srv.URL + "/authorize",
+			"token_endpoint":         iss.srv.URL + "/token",
+			"userinfo_endpoint":      iss.srv.URL + "/userinfo",
+			"jwks_uri":               iss.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", iss.token)
+	mux.HandleFunc("/userinfo", iss.userinfo)
+	iss.srv = httptest.NewServer(mux)
+	t.Cleanup(iss.srv.Close)
+	return iss
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomTestToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// authorize 校验授权地址并为 identity 签发授权码，相当于用户在身份提供方完成登录
+func (iss *testIssuer) authorize(t *testing.T, location string, identity testIdentity) string {
+	t.Helper()
+	u, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != iss.srv.URL+"/authorize" {
+		t.Fatalf("authorization endpoint = %s", got)
+	}
+	q := u.Query()
+	for k, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"code_challenge_method": "S256",
+	} {
+		if q.Get(k) != want {
+			t.Fatalf("%s = %q, want %q", k, q.Get(k), want)
+		}
+	}
+	if q.Get("state") == "" || q.Get("nonce") == "" || q.Get("code_challenge") == "" {
+		t.Fatalf("authorization url missing state, nonce or code_challenge: %s", location)
+	}
+
+	code := randomTestToken()
+	iss.mu.Lock()
+	iss.codes[code] = testGrant{identity: identity, nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+	iss.mu.Unlock()
+	return code
+}
+
+func (iss *testIssuer) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != testClientID || secret != testClientSecret {
+		writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != testRedirectURL {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	iss.mu.Lock()
+	grant, ok := iss.codes[r.PostFormValue("code")]
+	// 授权码只能使用一次
+	delete(iss.codes, r.PostFormValue("code"))
+	iss.mu.Unlock()
+	digest := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(digest[:]) != grant.challenge {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE 校验失败"})
+		return
+	}
+
+	now := time.Now()
+	idToken, err := iss.sign(map[string]interface{}{
+		"iss":                iss.srv.URL,
+		"aud":                testClientID,
+		"sub":                grant.identity.Subject,
+		"nonce":              grant.nonce,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"preferred_username": grant.identity.Username,
+	})
+	if err != nil {
+		writeTestJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	accessToken := randomTestToken()
+	iss.mu.Lock()
+	iss.tokens[accessToken] = grant.identity
+	iss.mu.Unlock()
+	writeTestJSON(w, http.StatusOK, map[string]string{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (iss *testIssuer) userinfo(w http.ResponseWriter, r *http.Request) {
+	iss.mu.Lock()
+	identity, ok := iss.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	iss.mu.Unlock()
+	if !ok {
+		writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	writeTestJSON(w, http.StatusOK, map[string]interface{}{
+		"sub":    identity.Subject,
+		"email":  identity.Username + "@example.com",
+		"groups": identity.Groups,
+	})
+}
+
+func (iss *testIssuer) sign(claims map[string]interface{}) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test-key", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, iss.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func newOIDCTestRouter(t *testing.T, issuer string) *gin.Engine {
+	t.Helper()
+	prev := config.AppConfig
+	config.AppConfig = &config.Config{OIDC: config.OIDCConfig{
+		Enabled:        true,
+		Issuer:         issuer,
+		ClientID:       testClientID,
+		ClientSecret:   testClientSecret,
+		RedirectURL:    testRedirectURL,
+		Scopes:         []string{"openid", "profile", "email", "groups"},
+		UsernameClaim:  "preferred_username",
+		GroupsClaim:    "groups",
+		OwnerGroups:    []string{"lxd-owners"},
+		OperatorGroups: []string{"lxd-operators"},
+		ReadOnlyGroups: []string{"lxd-viewers"},
+	}}
+	t.Cleanup(func() { config.AppConfig = prev })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.SetHTMLTemplate(template.Must(template.New("login.html").Parse("{{.error}}")))
+	r.Use(sessions.Sessions("lxdweb_session", services.NewSessionStore([]byte("0123456789abcdef0123456789abcdef"))))
+	r.GET("/login/oidc", OIDCLogin)
+	r.GET("/login/oidc/callback", OIDCCallback)
+	return r
+}
+
+// oidcLogin 依次请求登录入口、身份提供方和回调，返回回调的响应
+func oidcLogin(t *testing.T, r *gin.Engine, iss *testIssuer, identity testIdentity, tamperVerifier bool) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login/oidc", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login status = %d, body = %s", w.Code, w.Body.String())
+	}
+	location := w.Header().Get("Location")
+	cookies := w.Result().Cookies()
+
+	code := iss.authorize(t, location, identity)
+	if tamperVerifier {
+		// 模拟授权码被其他登录请求截获后注入，challenge 与本次会话的 verifier 不一致
+		iss.mu.Lock()
+		grant := iss.codes[code]
+		grant.challenge = base64.RawURLEncoding.EncodeToString([]byte("attacker-challenge"))
+		iss.codes[code] = grant
+		iss.mu.Unlock()
+	}
+
+	u, _ := url.Parse(location)
+	req := httptest.NewRequest(http.MethodGet, "/login/oidc/callback?"+url.Values{
+		"code":  {code},
+		"state": {u.Query().Get("state")},
+	}.Encode(), nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestOIDCCallback(t *testing.T) {
+	newTestDB(t)
+	iss := newTestIssuer(t)
+	r := newOIDCTestRouter(t, iss.srv.URL)
+
+	// 各步骤共享数据库，按顺序执行
+	steps := []struct {
+		name           string
+		identity       testIdentity
+		tamperVerifier bool
+		wantStatus     int
+		wantRole       string
+	}{
+		{"首次登录按 operator 组创建账号", testIdentity{"sub-alice", "alice", []string{"staff", "lxd-operators"}}, false, http.StatusFound, models.RoleOperator},
+		{"再次登录时按用户组提升角色", testIdentity{"sub-alice", "alice", []string{"lxd-operators", "lxd-owners"}}, false, http.StatusFound, models.RoleOwner},
+		{"readonly 组", testIdentity{"sub-bob", "bob", []string{"lxd-viewers"}}, false, http.StatusFound, models.RoleReadOnly},
+		{"用户组未授权", testIdentity{"sub-carol", "carol", []string{"staff"}}, false, http.StatusForbidden, ""},
+		{"PKCE 校验失败", testIdentity{"sub-dave", "dave", []string{"lxd-owners"}}, true, http.StatusUnauthorized, ""},
+		{"同名本地账号不被接管", testIdentity{"sub-root", "root", []string{"lxd-owners"}}, false, http.StatusForbidden, ""},
+	}
+
+	local := models.Admin{Username: "root", Password: "local-password", Role: models.RoleOwner, AuthSource: models.AuthSourceLocal}
+	if err := local.HashPassword(); err != nil {
+		t.Fatal(err)
+	}
+	database.DB.Create(&local)
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			w := oidcLogin(t, r, iss, step.identity, step.tamperVerifier)
+			if w.Code != step.wantStatus {
+				t.Fatalf("callback status = %d, want %d, body = %s", w.Code, step.wantStatus, w.Body.String())
+			}
+
+			var admins []models.Admin
+			database.DB.Where("auth_source = ? AND oidc_subject = ?", models.AuthSourceOIDC, step.identity.Subject).Find(&admins)
+			if step.wantRole == "" {
+				if len(admins) != 0 {
+					t.Fatalf("unexpected admin created: %+v", admins[0])
+				}
+				return
+			}
+			if len(admins) != 1 {
+				t.Fatalf("admins for %s = %d, want 1", step.identity.Subject, len(admins))
+			}
+			admin := admins[0]
+			if admin.Username != step.identity.Username || admin.Role != step.wantRole ||
+				admin.Email != step.identity.Username+"@example.com" {
+				t.Fatalf("admin = %s role %s email %s, want %s role %s", admin.Username, admin.Role, admin.Email,
+					step.identity.Username, step.wantRole)
+			}
+			if loc := w.Header().Get("Location"); loc != "/dashboard" {
+				t.Fatalf("redirect = %q", loc)
+			}
+			var sessionCount int64
+			database.DB.Model(&models.AdminSession{}).Where("admin_id = ?", admin.ID).Count(&sessionCount)
+			if sessionCount == 0 {
+				t.Fatal("login did not create a session")
+			}
+		})
+	}
+
+	var localAfter models.Admin
+	database.DB.First(&localAfter, local.ID)
+	if localAfter.AuthSource != models.AuthSourceLocal || localAfter.OIDCSubject != "" {
+		t.Fatalf("local admin modified: source %s subject %s", localAfter.AuthSource, localAfter.OIDCSubject)
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"net/http"
+
+	"lxdweb/database"
+	"lxdweb/middleware"
+	"lxdweb/models"
+	"lxdweb/services"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// GetAccountSessions 获取自己的登录会话
+// @Summary 获取自己的登录会话
+// @Description 返回当前管理员所有未过期的登录会话，current 标记当前会话
+// @Tags 管理员
+// @Produce json
+// @Success 200 {object} map[string]interface{} "成功返回会话列表"
+// @Router /api/account/sessions [get]
+func GetAccountSessions(c *gin.Context) {
+	admin := middleware.CurrentAdmin(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": sessionList(admin.ID, sessions.Default(c).ID()),
+	})
+}
+
+// RevokeAccountSession 吊销自己的登录会话
+// @Summary 吊销自己的登录会话
+// @Description 吊销指定会话，该设备需重新登录；不能吊销当前会话（请使用退出登录）
+// @Tags 管理员
+// @Produce json
+// @Param id path string true "会话ID"
+// @Success 200 {object} map[string]interface{} "吊销成功"
+// @Failure 400 {object} map[string]interface{} "不能吊销当前会话"
+// @Failure 404 {object} map[string]interface{} "会话不存在"
+// @Router /api/account/sessions/{id} [delete]
+func RevokeAccountSession(c *gin.Context) {
+	admin := middleware.CurrentAdmin(c)
+
+	var row models.AdminSession
+	if err := database.DB.Where("id = ? AND admin_id = ?", c.Param("id"), admin.ID).First(&row).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "会话不存在",
+		})
+		return
+	}
+	if row.SessionKey == sessions.Default(c).ID() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "不能吊销当前会话，请使用退出登录",
+		})
+		return
+	}
+
+	database.DB.Delete(&row)
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "会话已吊销",
+	})
+}
+
+// RevokeOtherSessions 吊销其他登录会话
+// @Summary 吊销其他登录会话
+// @Description 吊销当前管理员除本会话外的全部登录会话
+// @Tags 管理员
+// @Produce json
+// @Success 200 {object} map[string]interface{} "吊销成功"
+// @Router /api/account/sessions [delete]
+func RevokeOtherSessions(c *gin.Context) {
+	admin := middleware.CurrentAdmin(c)
+	count := services.RevokeAdminSessions(admin.ID, sessions.Default(c).ID())
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "已吊销其他会话",
+		"data": gin.H{
+			"revoked": count,
+		},
+	})
+}
+
+// GetAdminSessions 获取管理员的登录会话
+// @Summary 获取管理员的登录会话
+// @Description 返回指定管理员所有未过期的登录会话，仅 owner 可用
+// @Tags 管理员
+// @Produce json
+// @Param id path string true "管理员ID"
+// @Success 200 {object} map[string]interface{} "成功返回会话列表"
+// @Failure 404 {object} map[string]interface{} "管理员不存在"
+// @Router /api/admins/{id}/sessions [get]
+func GetAdminSessions(c *gin.Context) {
+	var admin models.Admin
+	if err := database.DB.First(&admin, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "管理员不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": sessionList(admin.ID, sessions.Default(c).ID()),
+	})
+}
+
+// RevokeAdminSessions 吊销管理员的全部会话
+// @Summary 吊销管理员的全部会话
+// @Description 强制指定管理员在所有设备上重新登录，仅 owner 可用；对自己操作时保留当前会话
+// @Tags 管理员
+// @Produce json
+// @Param id path string true "管理员ID"
+// @Success 200 {object} map[string]interface{} "吊销成功"
+// @Failure 404 {object} map[string]interface{} "管理员不存在"
+// @Router /api/admins/{id}/sessions [delete]
+func RevokeAdminSessions(c *gin.Context) {
+	var admin models.Admin
+	if err := database.DB.First(&admin, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "管理员不存在",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "会话已吊销",
+		"data": gin.H{
+			"revoked": services.RevokeAdminSessions(admin.ID, currentSessionKey(c, admin.ID)),
+		},
+	})
+}
+
+func sessionList(adminID uint, currentKey string) []gin.H {
+	rows := services.ListAdminSessions(adminID)
+	result := make([]gin.H, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, gin.H{
+			"session": row,
+			"current": row.SessionKey == currentKey,
+		})
+	}
+	return result
+}
+
+// currentSessionKey 目标是当前登录的管理员时返回本会话ID，用于吊销时保留当前会话
+func currentSessionKey(c *gin.Context, adminID uint) string {
+	if current := middleware.CurrentAdmin(c); current != nil && current.ID == adminID && middleware.CurrentToken(c) == nil {
+		return sessions.Default(c).ID()
+	}
+	return ""
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"lxdweb/config"
+	"lxdweb/database"
+	"lxdweb/middleware"
+	"lxdweb/models"
+	"lxdweb/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trafficSum 多条账本的合计
+type trafficSum struct {
+	RxBytes    uint64 `json:"rx_bytes"`
+	TxBytes    uint64 `json:"tx_bytes"`
+	TotalBytes uint64 `json:"total_bytes"`
+}
+
+func sumLedgers(entries []models.TrafficLedger) trafficSum {
+	var sum trafficSum
+	for _, e := range entries {
+		sum.RxBytes += e.RxBytes
+		sum.TxBytes += e.TxBytes
+		sum.TotalBytes += e.TotalBytes
+	}
+	return sum
+}
+
+// GetContainerTraffic 获取容器流量账本
+// @Summary 获取容器流量账本
+// @Description 返回容器当前的进出流量计数及最近若干个月的月度累计
+// @Tags 容器管理
+// @Produce json
+// @Param name path string true "容器名称"
+// @Param node_id query string true "节点ID"
+// @Param months query int false "返回最近几个月，默认12，最大36"
+// @Success 200 {object} map[string]interface{} "成功返回流量账本"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/containers/{name}/traffic [get]
+func GetContainerTraffic(c *gin.Context) {
+	name := c.Param("name")
+
+	node, ok := requestNode(c)
+	if !ok {
+		return
+	}
+
+	months, _ := strconv.Atoi(c.DefaultQuery("months", "12"))
+	if months <= 0 {
+		months = 12
+	}
+	if months > 36 {
+		months = 36
+	}
+	now := time.Now()
+	since := services.TrafficPeriod(time.Date(now.Year(), now.Month()-time.Month(months-1), 1, 0, 0, 0, 0, now.Location()))
+
+	var entries []models.TrafficLedger
+	database.DB.Where("node_id = ? AND hostname = ? AND period >= ?", node.ID, name, since).
+		Order("period desc").Find(&entries)
+
+	current := gin.H{}
+	var cache models.ContainerCache
+	if err := database.DB.Where("node_id = ? AND hostname = ?", node.ID, name).First(&cache).Error; err == nil {
+		current = gin.H{
+			"traffic_total": cache.TrafficTotal,
+			"traffic_in":    cache.TrafficIn,
+			"traffic_out":   cache.TrafficOut,
+			"traffic_limit": cache.TrafficLimit,
+			"last_sync":     cache.LastSync,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": gin.H{
+			"node_id":  node.ID,
+			"hostname": name,
+			"current":  current,
+			"periods":  entries,
+			"total":    sumLedgers(entries),
+		},
+	})
+}
+
+// GetTrafficLedger 获取账期流量汇总
+// @Summary 获取账期流量汇总
+// @Description 返回指定月份各容器的流量累计及合计，可按节点筛选
+// @Tags 容器管理
+// @Produce json
+// @Param period query string false "账期（YYYY-MM），默认当月"
+// @Param node_id query string false "节点ID"
+// @Success 200 {object} map[string]interface{} "成功返回流量汇总"
+// @Failure 400 {object} map[string]interface{} "参数错误"
+// @Router /api/traffic/ledger [get]
+func GetTrafficLedger(c *gin.Context) {
+	period := c.DefaultQuery("period", services.TrafficPeriod(time.Now()))
+	if _, err := time.Parse("2006-01", period); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "账期格式错误，应为 YYYY-MM",
+		})
+		return
+	}
+
+	query := middleware.ScopeNodes(c, database.DB, "node_id").Where("period = ?", period)
+	if nodeID := c.Query("node_id"); nodeID != "" {
+		query = query.Where("node_id = ?", nodeID)
+	}
+
+	var entries []models.TrafficLedger
+	query.Order("total_bytes desc").Find(&entries)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": gin.H{
+			"period":     period,
+			"containers": entries,
+			"total":      sumLedgers(entries),
+		},
+	})
+}
+
+// GetContainerQuota 获取容器流量配额
+// @Summary 获取容器流量配额
+// @Description 返回容器的账单日、超额处理方式、当前周期用量及最近的配额事件
+// @Tags 容器管理
+// @Produce json
+// @Param name path string true "容器名称"
+// @Param node_id query string true "节点ID"
+// @Success 200 {object} map[string]interface{} "成功返回配额信息"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/containers/{name}/traffic/quota [get]
+func GetContainerQuota(c *gin.Context) {
+	name := c.Param("name")
+
+	node, ok := requestNode(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": containerQuotaData(node.ID, name),
+	})
+}
+
+// UpdateContainerQuota 设置容器流量配额
+// @Summary 设置容器流量配额
+// @Description 设置容器的账单日（1-31，0 表示使用全局配置）和超额处理方式（none、suspend，留空使用全局配置），修改账单日后按当前时间重新计算周期
+// @Tags 容器管理
+// @Accept json
+// @Produce json
+// @Param name path string true "容器名称"
+// @Param node_id query string true "节点ID"
+// @Param body body object true "配额参数"
+// @Success 200 {object} map[string]interface{} "设置成功"
+// @Failure 400 {object} map[string]interface{} "参数错误"
+// @Failure 404 {object} map[string]interface{} "节点不存在"
+// @Router /api/containers/{name}/traffic/quota [put]
+func UpdateContainerQuota(c *gin.Context) {
+	name := c.Param("name")
+
+	node, ok := requestNode(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		AnchorDay int    `json:"anchor_day"`
+		Action    string `json:"action"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误: " + err.Error(),
+		})
+		return
+	}
+	if req.AnchorDay < 0 || req.AnchorDay > 31 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "账单日必须在 1-31 之间，0 表示使用全局配置",
+		})
+		return
+	}
+	if req.Action != "" && req.Action != "none" && req.Action != "suspend" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "超额处理方式只能是 none 或 suspend",
+		})
+		return
+	}
+
+	quota := services.LoadTrafficQuota(node.ID, name)
+	quota.AnchorDay = req.AnchorDay
+	quota.Action = req.Action
+	anchorDay, _ := services.EffectiveQuota(&quota)
+	quota.CycleStart, quota.NextReset = services.QuotaCycle(anchorDay, time.Now())
+	if err := database.DB.Save(&quota).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "保存失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "配额设置已保存",
+		"data": containerQuotaData(node.ID, name),
+	})
+}
+
+// GetQuotaEvents 获取流量配额事件
+// @Summary 获取流量配额事件
+// @Description 返回配额调度执行的重置、告警、暂停、恢复记录，可按节点和容器筛选
+// @Tags 容器管理
+// @Produce json
+// @Param node_id query string false "节点ID"
+// @Param hostname query string false "容器名称"
+// @Param action query string false "动作：reset、warn、suspend、unsuspend"
+// @Param limit query int false "返回条数，默认100，最大1000"
+// @Success 200 {object} map[string]interface{} "成功返回事件列表"
+// @Router /api/traffic/quota/events [get]
+func GetQuotaEvents(c *gin.Context) {
+	query := middleware.ScopeNodes(c, database.DB.Model(&models.TrafficQuotaEvent{}), "node_id")
+	if nodeID := c.Query("node_id"); nodeID != "" {
+		query = query.Where("node_id = ?", nodeID)
+	}
+	if hostname := c.Query("hostname"); hostname != "" {
+		query = query.Where("hostname = ?", hostname)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	var events []models.TrafficQuotaEvent
+	query.Order("created_at desc").Limit(limit).Find(&events)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": events,
+	})
+}
+
+func containerQuotaData(nodeID uint, hostname string) gin.H {
+	quota := services.LoadTrafficQuota(nodeID, hostname)
+	anchorDay, action := services.EffectiveQuota(&quota)
+
+	var cache models.ContainerCache
+	database.DB.Where("node_id = ? AND hostname = ?", nodeID, hostname).First(&cache)
+
+	var events []models.TrafficQuotaEvent
+	database.DB.Where("node_id = ? AND hostname = ?", nodeID, hostname).
+		Order("created_at desc").Limit(20).Find(&events)
+
+	percent := 0.0
+	if cache.TrafficLimit > 0 {
+		percent = float64(cache.TrafficTotal) * 100 / (float64(cache.TrafficLimit) * 1024 * 1024 * 1024)
+	}
+
+	return gin.H{
+		"quota":            quota,
+		"anchor_day":       anchorDay,
+		"action":           action,
+		"usage_bytes":      cache.TrafficTotal,
+		"limit_gb":         cache.TrafficLimit,
+		"usage_percent":    percent,
+		"scheduler_active": config.AppConfig != nil && config.AppConfig.Quota.Enabled,
+		"events":           events,
+	}
+}
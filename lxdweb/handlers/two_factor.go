@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"lxdweb/database"
+	"lxdweb/middleware"
+	"lxdweb/models"
+	"lxdweb/services"
+	"lxdweb/utils"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// totpIssuer 验证器 App 中显示的发行方名称
+const totpIssuer = "LXDWeb"
+
+// 登录第二步的有效期和最大尝试次数
+const (
+	twoFactorLoginTTL         = 5 * time.Minute
+	twoFactorLoginMaxAttempts = 5
+)
+
+// LoginTwoFactor 登录第二步
+// @Summary 登录第二步
+// @Description 密码校验通过且账号已启用两步验证时，提交 TOTP 验证码或恢复码完成登录
+// @Tags 认证管理
+// @Accept json
+// @Produce json
+// @Param body body object true "验证码(otp)"
+// @Success 200 {object} map[string]interface{} "登录成功"
+// @Failure 400 {object} map[string]interface{} "参数错误或登录已过期"
+// @Failure 401 {object} map[string]interface{} "验证码错误"
+// @Failure 429 {object} map[string]interface{} "失败次数过多，账号或IP已锁定"
+// @Router /login/2fa [post]
+func LoginTwoFactor(c *gin.Context) {
+	var req struct {
+		OTP string `json:"otp" form:"otp" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "请输入验证码",
+		})
+		return
+	}
+
+	session := sessions.Default(c)
+	adminID, _ := session.Get("2fa_admin_id").(uint)
+	expires, _ := session.Get("2fa_expires").(int64)
+	if adminID == 0 || time.Now().Unix() > expires {
+		clearTwoFactorLogin(session)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "登录已过期，请重新输入密码",
+		})
+		return
+	}
+
+	var admin models.Admin
+	if err := database.DB.First(&admin, adminID).Error; err != nil {
+		clearTwoFactorLogin(session)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "登录已过期，请重新输入密码",
+		})
+		return
+	}
+	ip := c.ClientIP()
+	if lockout, blocked := services.LoginBlocked(ip, admin.Username); blocked {
+		services.RecordLoginFailure(ip, admin.Username, services.LoginFailLocked)
+		clearTwoFactorLogin(session)
+		respondLoginLocked(c, lockout)
+		return
+	}
+	if !services.VerifySecondFactor(&admin, req.OTP) {
+		services.RecordLoginFailure(ip, admin.Username, services.LoginFail2FA)
+		attempts, _ := session.Get("2fa_attempts").(int)
+		attempts++
+		if attempts >= twoFactorLoginMaxAttempts {
+			clearTwoFactorLogin(session)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  "验证码错误次数过多，请重新登录",
+			})
+			return
+		}
+		session.Set("2fa_attempts", attempts)
+		session.Save()
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code": 401,
+			"msg":  "验证码错误",
+		})
+		return
+	}
+
+	clearTwoFactorLogin(session)
+	session.Set("admin_id", admin.ID)
+	session.Set("username", admin.Username)
+	if err := session.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": 500,
+			"msg":  "登录失败",
+		})
+		return
+	}
+	services.RecordLoginSuccess(ip, admin.Username)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "登录成功",
+		"data": gin.H{
+			"redirect": "/dashboard",
+		},
+	})
+}
+
+// GetTwoFactorStatus 获取两步验证状态
+// @Summary 获取两步验证状态
+// @Description 返回当前账号是否已启用两步验证、剩余恢复码数量以及系统是否强制启用
+// @Tags 管理员
+// @Produce json
+// @Success 200 {object} map[string]interface{} "成功返回状态"
+// @Router /api/account/2fa [get]
+func GetTwoFactorStatus(c *gin.Context) {
+	admin := middleware.CurrentAdmin(c)
+	data := gin.H{
+		"enabled":  admin.TOTPEnabled,
+		"enforced": services.TwoFactorEnforced() && admin.AuthSource != models.AuthSourceOIDC,
+	}
+	if admin.TOTPEnabled {
+		data["recovery_codes_remaining"] = services.RemainingRecoveryCodes(admin.ID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": data,
+	})
+}
+
+// SetupTwoFactor 开始绑定两步验证
+// @Summary 开始绑定两步验证
+// @Description 生成新的 TOTP 密钥并返回 otpauth 地址用于扫码，需调用启用接口提交验证码后才会生效
+// @Tags 管理员
+// @Produce json
+// @Success 200 {object} map[string]interface{} "返回密钥和 otpauth 地址"
+// @Failure 400 {object} map[string]interface{} "已启用两步验证"
+// @Router /api/account/2fa/setup [post]
+func SetupTwoFactor(c *gin.Context) {
+	admin := middleware.CurrentAdmin(c)
+	if admin.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "已启用两步验证，如需更换请先关闭",
+		})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "生成密钥失败",
+		})
+		return
+	}
+	session := sessions.Default(c)
+	session.Set("totp_pending", secret)
+	session.Save()
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": gin.H{
+			"secret": secret,
+			"uri":    utils.TOTPProvisioningURI(totpIssuer, admin.Username, secret),
+		},
+	})
+}
+
+// EnableTwoFactor 启用两步验证
+// @Summary 启用两步验证
+// @Description 提交验证器 App 生成的验证码确认绑定，成功后返回一次性恢复码（只显示这一次）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Param body body object true "验证码(otp)"
+// @Success 200 {object} map[string]interface{} "启用成功，返回恢复码"
+// @Failure 400 {object} map[string]interface{} "验证码错误或未开始绑定"
+// @Router /api/account/2fa/enable [post]
+func EnableTwoFactor(c *gin.Context) {
+	var req struct {
+		OTP string `json:"otp" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "请输入验证码",
+		})
+		return
+	}
+
+	admin := middleware.CurrentAdmin(c)
+	session := sessions.Default(c)
+	secret, _ := session.Get("totp_pending").(string)
+	if admin.TOTPEnabled || secret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "请先生成两步验证密钥",
+		})
+		return
+	}
+
+	step, ok := utils.VerifyTOTP(secret, req.OTP, time.Now(), 0)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "验证码错误，请确认手机时间准确",
+		})
+		return
+	}
+
+	codes, err := services.EnableTwoFactor(admin, secret, step)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "启用失败: " + err.Error(),
+		})
+		return
+	}
+	session.Delete("totp_pending")
+	session.Save()
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "两步验证已启用",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// DisableTwoFactor 关闭两步验证
+// @Summary 关闭两步验证
+// @Description 校验密码和验证码（或恢复码）后关闭两步验证，系统强制启用时不可关闭
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Param body body object true "密码和验证码(password, otp)"
+// @Success 200 {object} map[string]interface{} "关闭成功"
+// @Failure 400 {object} map[string]interface{} "校验失败或系统强制启用"
+// @Router /api/account/2fa/disable [post]
+func DisableTwoFactor(c *gin.Context) {
+	var req struct {
+		Password string `json:"password" binding:"required"`
+		OTP      string `json:"otp" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "请填写密码和验证码",
+		})
+		return
+	}
+
+	admin := middleware.CurrentAdmin(c)
+	if !admin.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "未启用两步验证",
+		})
+		return
+	}
+	if services.TwoFactorEnforced() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "系统已强制启用两步验证，不能关闭",
+		})
+		return
+	}
+	if !admin.CheckPassword(req.Password) || !services.VerifySecondFactor(admin, req.OTP) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "密码或验证码错误",
+		})
+		return
+	}
+
+	if err := services.DisableTwoFactor(admin.ID); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "关闭失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "两步验证已关闭",
+	})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+// @Summary 重新生成恢复码
+// @Description 校验验证码后作废全部旧恢复码并生成新的一组
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Param body body object true "验证码(otp)"
+// @Success 200 {object} map[string]interface{} "返回新的恢复码"
+// @Failure 400 {object} map[string]interface{} "验证码错误"
+// @Router /api/account/2fa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req struct {
+		OTP string `json:"otp" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "请输入验证码",
+		})
+		return
+	}
+
+	admin := middleware.CurrentAdmin(c)
+	if !services.VerifySecondFactor(admin, req.OTP) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "验证码错误",
+		})
+		return
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(admin.ID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "生成失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "恢复码已重新生成",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// ResetAdminTwoFactor 重置管理员两步验证
+// @Summary 重置管理员两步验证
+// @Description 管理员丢失验证设备且恢复码用尽时，由 owner 清除其两步验证绑定
+// @Tags 管理员
+// @Produce json
+// @Param id path string true "管理员ID"
+// @Success 200 {object} map[string]interface{} "重置成功"
+// @Failure 403 {object} map[string]interface{} "权限不足"
+// @Failure 404 {object} map[string]interface{} "管理员不存在"
+// @Router /api/admins/{id}/2fa/reset [post]
+func ResetAdminTwoFactor(c *gin.Context) {
+	var admin models.Admin
+	if err := database.DB.First(&admin, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": 404,
+			"msg":  "管理员不存在",
+		})
+		return
+	}
+
+	if err := services.DisableTwoFactor(admin.ID); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": 500,
+			"msg":  "重置失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "两步验证已重置",
+	})
+}
+
+// GetSecuritySettings 获取安全设置
+// @Summary 获取安全设置
+// @Description 返回是否强制所有管理员启用两步验证，以及尚未启用的管理员数量
+// @Tags 系统管理
+// @Produce json
+// @Success 200 {object} map[string]interface{} "成功返回安全设置"
+// @Router /api/settings/security [get]
+func GetSecuritySettings(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "success",
+		"data": securitySettingsData(),
+	})
+}
+
+// UpdateSecuritySettings 修改安全设置
+// @Summary 修改安全设置
+// @Description 开启后未启用两步验证的管理员登录后只能访问账号接口，完成绑定后恢复正常
+// @Tags 系统管理
+// @Accept json
+// @Produce json
+// @Param body body object true "安全设置(enforce_2fa)"
+// @Success 200 {object} map[string]interface{} "保存成功"
+// @Failure 400 {object} map[string]interface{} "参数错误"
+// @Router /api/settings/security [put]
+func UpdateSecuritySettings(c *gin.Context) {
+	var req struct {
+		Enforce2FA *bool `json:"enforce_2fa"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if req.Enforce2FA != nil {
+		if err := services.SetSetting(services.SettingEnforce2FA, strconv.FormatBool(*req.Enforce2FA)); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"code": 500,
+				"msg":  "保存失败: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"msg":  "安全设置已保存",
+		"data": securitySettingsData(),
+	})
+}
+
+func securitySettingsData() gin.H {
+	var pending int64
+	database.DB.Model(&models.Admin{}).Where("totp_enabled = ? AND auth_source <> ?", false, models.AuthSourceOIDC).Count(&pending)
+	return gin.H{
+		"enforce_2fa":        services.TwoFactorEnforced(),
+		"admins_without_2fa": pending,
+	}
+}
+
+func clearTwoFactorLogin(session sessions.Session) {
+	session.Delete("2fa_admin_id")
+	session.Delete("2fa_expires")
+	session.Delete("2fa_attempts")
+	session.Save()
+}
@@ -18,69 +18,73 @@
 // @name Authorization
 // @description 基于 Session 的认证
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description API 令牌认证，格式为 Bearer lxdw_xxx，通过 /api/account/tokens 创建
+
 package main
 import (
 	"log"
+	"os"
 	"lxdweb/config"
 	"lxdweb/database"
 	_ "lxdweb/docs"
 	"lxdweb/handlers"
 	"lxdweb/middleware"
+	"lxdweb/models"
 	"lxdweb/pkg/logger"
 	"lxdweb/services"
 	"lxdweb/utils"
 	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 func main() {
+	if len(os.Args) > 1 && os.Args[1] != "serve" {
+		os.Exit(runCLI(os.Args[1:]))
+	}
 	startWebServer()
 }
 func startWebServer() {
-	if err := config.LoadConfig(); err != nil {
-		log.Fatalf("[ERROR] 配置加载失败: %v", err)
-	}
-	
-	zapLogger, err := utils.InitLogger(
-		config.AppConfig.Logging.File,
-		config.AppConfig.Logging.MaxSize,
-		config.AppConfig.Logging.MaxBackups,
-		config.AppConfig.Logging.MaxAge,
-		config.AppConfig.Logging.Compress,
-		config.AppConfig.Logging.Level,
-		config.AppConfig.Logging.DevMode,
-	)
-	if err != nil {
-		log.Fatalf("[ERROR] 日志系统初始化失败: %v", err)
-	}
-	
-	logger.Init(zapLogger)
-	log.Printf("[LOGGER] 日志系统初始化完成: 级别=%s, 文件=%s", config.AppConfig.Logging.Level, config.AppConfig.Logging.File)
-	
+	initRuntime()
 	database.InitDB()
+	initSecrets()
 	database.CheckAdminExists()
 
 	go services.StartContainerSyncService()
 	go services.StartAutoSyncService()
 	go services.StartNodeCacheService()
+	go services.StartNodeHealthService()
+	go services.StartContainerMetricsService()
+	go services.StartTrafficQuotaService()
+	go services.StartLoginGuardService()
+	go services.StartSessionService()
 	
 	gin.SetMode(config.AppConfig.Server.Mode)
 	r := gin.Default()
 	r.LoadHTMLGlob("templates/*")
-	store := cookie.NewStore([]byte(config.AppConfig.Server.SessionSecret))
+	store := services.NewSessionStore([]byte(config.AppConfig.Server.SessionSecret))
 	r.Use(sessions.Sessions("lxdweb_session", store))
+	if config.AppConfig.Prometheus.Enabled {
+		r.Use(middleware.Metrics())
+		r.GET("/metrics", handlers.PrometheusMetrics)
+	}
 	
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	
-	r.GET("/", handlers.LoginPage)
-	r.GET("/login", handlers.LoginPage)
-	r.POST("/login", handlers.Login)
-	r.GET("/logout", handlers.Logout)
+	r.GET("/", middleware.CSRF(), handlers.LoginPage)
+	r.GET("/login", middleware.CSRF(), handlers.LoginPage)
+	r.POST("/login", middleware.Audit(), middleware.CSRF(), handlers.Login)
+	r.POST("/login/2fa", middleware.Audit(), middleware.CSRF(), handlers.LoginTwoFactor)
+	r.GET("/login/oidc", middleware.CSRF(), handlers.OIDCLogin)
+	r.GET("/login/oidc/callback", middleware.Audit(), middleware.CSRF(), handlers.OIDCCallback)
+	r.GET("/logout", middleware.Audit(), handlers.Logout)
 	r.GET("/api/captcha", handlers.GetCaptcha)
 	auth := r.Group("/")
-	auth.Use(middleware.AuthRequired())
+	auth.Use(middleware.AuthRequired(), middleware.Audit(), middleware.CSRF(), middleware.NodeScope())
+	// readonly：页面与查询接口
 	{
 		auth.GET("/dashboard", handlers.DashboardPage)
 		auth.GET("/nodes", handlers.NodesPage)
@@ -89,41 +93,111 @@ func startWebServer() {
 		auth.GET("/nodes/:id/containers/:name", handlers.ContainerDetailPage)
 		auth.GET("/api/nodes", handlers.GetNodes)
 		auth.GET("/api/nodes/:id", handlers.GetNode)
-		auth.POST("/api/nodes", handlers.CreateNode)
-		auth.PUT("/api/nodes/:id", handlers.UpdateNode)
-		auth.DELETE("/api/nodes/:id", handlers.DeleteNode)
-		auth.POST("/api/nodes/:id/test", handlers.TestNode)
-		auth.POST("/api/nodes/:id/refresh", handlers.RefreshNodeCache)
-		auth.GET("/api/nodes/export/all", handlers.ExportNodes)
-		auth.POST("/api/nodes/import/batch", handlers.ImportNodes)
-		auth.POST("/api/nodes/delete/batch", handlers.BatchDeleteNodes)
+		auth.GET("/api/nodes/:id/health", handlers.GetNodeHealth)
+		auth.GET("/api/nodes/:id/ipv6/pool", handlers.GetNodeIPv6Pool)
 		
 		// 容器API（保留API接口，但去掉全局页面入口）
 		auth.GET("/api/containers", handlers.GetContainers)
 		auth.GET("/api/containers/cache", handlers.GetContainersFromCache)
 		auth.GET("/api/containers/:name", handlers.GetContainerDetail)
-		auth.POST("/api/containers/:name/start", handlers.StartContainer)
-		auth.POST("/api/containers/:name/stop", handlers.StopContainer)
-		auth.POST("/api/containers/:name/restart", handlers.RestartContainer)
-		auth.POST("/api/containers/:name/delete", handlers.DeleteContainer)
-		auth.POST("/api/containers/:name/refresh", handlers.RefreshSingleContainer)
-		auth.POST("/api/containers/:name/reinstall", handlers.ReinstallContainer)
-		auth.POST("/api/containers/:name/password", handlers.ResetContainerPassword)
-		auth.POST("/api/containers/:name/suspend", handlers.SuspendContainer)
-		auth.POST("/api/containers/:name/unsuspend", handlers.UnsuspendContainer)
-		auth.POST("/api/containers/:name/traffic/reset", handlers.ResetContainerTraffic)
-		auth.POST("/api/containers/create", handlers.CreateContainer)
-		
-		auth.POST("/api/console/create-token", handlers.CreateConsoleToken)
+		auth.GET("/api/containers/:name/metrics", handlers.GetContainerMetrics)
+		auth.GET("/api/containers/:name/traffic", handlers.GetContainerTraffic)
+		auth.GET("/api/containers/:name/traffic/quota", handlers.GetContainerQuota)
+		auth.GET("/api/containers/:name/nat", handlers.GetContainerNAT)
+		auth.GET("/api/containers/:name/nat/check", handlers.CheckContainerNATPort)
+		auth.GET("/api/containers/:name/ipv6", handlers.GetContainerIPv6)
+		auth.GET("/api/nat/ports", handlers.GetNATPorts)
+		auth.GET("/api/nat/ports/allocate", handlers.AllocateNATPorts)
+		auth.GET("/api/traffic/ledger", handlers.GetTrafficLedger)
+		auth.GET("/api/traffic/quota/events", handlers.GetQuotaEvents)
 
-		auth.POST("/api/sync/all", handlers.SyncAllNodes)
-		auth.POST("/api/sync/node/:id", handlers.SyncNode)
 		auth.GET("/api/sync/tasks", handlers.GetSyncTasks)
 		auth.GET("/api/sync/status", handlers.GetSyncStatus)
-
 		auth.GET("/api/auto-sync/status", handlers.GetAutoSyncStatus)
-		auth.POST("/api/auto-sync/enable", handlers.EnableAutoSync)
-		auth.POST("/api/auto-sync/disable", handlers.DisableAutoSync)
+
+		auth.GET("/api/account", handlers.GetAccount)
+		auth.GET("/api/account/2fa", handlers.GetTwoFactorStatus)
+	}
+
+	// 账号安全相关接口只允许登录会话调用
+	account := auth.Group("/api/account")
+	account.Use(middleware.RequireSession())
+	{
+		account.POST("/password", handlers.ChangePassword)
+		account.POST("/2fa/setup", handlers.SetupTwoFactor)
+		account.POST("/2fa/enable", handlers.EnableTwoFactor)
+		account.POST("/2fa/disable", handlers.DisableTwoFactor)
+		account.POST("/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
+		account.GET("/tokens", handlers.GetAccountTokens)
+		account.POST("/tokens", handlers.CreateAccountToken)
+		account.DELETE("/tokens/:id", handlers.DeleteAccountToken)
+		account.GET("/sessions", handlers.GetAccountSessions)
+		account.DELETE("/sessions", handlers.RevokeOtherSessions)
+		account.DELETE("/sessions/:id", handlers.RevokeAccountSession)
+	}
+
+	// operator：容器操作、连接测试与同步
+	operator := auth.Group("/")
+	operator.Use(middleware.RequireRole(models.RoleOperator))
+	{
+		operator.POST("/api/nodes/:id/test", handlers.TestNode)
+		operator.POST("/api/nodes/:id/refresh", handlers.RefreshNodeCache)
+
+		operator.POST("/api/containers/:name/start", handlers.StartContainer)
+		operator.POST("/api/containers/:name/stop", handlers.StopContainer)
+		operator.POST("/api/containers/:name/restart", handlers.RestartContainer)
+		operator.POST("/api/containers/:name/delete", handlers.DeleteContainer)
+		operator.POST("/api/containers/:name/refresh", handlers.RefreshSingleContainer)
+		operator.POST("/api/containers/:name/reinstall", handlers.ReinstallContainer)
+		operator.POST("/api/containers/:name/password", handlers.ResetContainerPassword)
+		operator.POST("/api/containers/:name/suspend", handlers.SuspendContainer)
+		operator.POST("/api/containers/:name/unsuspend", handlers.UnsuspendContainer)
+		operator.POST("/api/containers/:name/traffic/reset", handlers.ResetContainerTraffic)
+		operator.POST("/api/containers/:name/nat", handlers.AddContainerNAT)
+		operator.POST("/api/containers/:name/nat/delete", handlers.DeleteContainerNAT)
+		operator.POST("/api/containers/:name/ipv6", handlers.BindContainerIPv6)
+		operator.POST("/api/containers/:name/ipv6/delete", handlers.UnbindContainerIPv6)
+		operator.POST("/api/containers/create", handlers.CreateContainer)
+		
+		operator.POST("/api/console/create-token", handlers.CreateConsoleToken)
+
+		operator.POST("/api/sync/all", handlers.SyncAllNodes)
+		operator.POST("/api/sync/node/:id", handlers.SyncNode)
+	}
+	// owner：节点管理、配额、系统设置、审计与管理员
+	owner := auth.Group("/")
+	owner.Use(middleware.RequireRole(models.RoleOwner))
+	{
+		owner.POST("/api/nodes", handlers.CreateNode)
+		owner.PUT("/api/nodes/:id", handlers.UpdateNode)
+		owner.DELETE("/api/nodes/:id", handlers.DeleteNode)
+		owner.POST("/api/nodes/export/all", handlers.ExportNodes)
+		owner.POST("/api/nodes/import/batch", handlers.ImportNodes)
+		owner.POST("/api/nodes/delete/batch", handlers.BatchDeleteNodes)
+
+		owner.PUT("/api/containers/:name/traffic/quota", handlers.UpdateContainerQuota)
+
+		owner.POST("/api/auto-sync/enable", handlers.EnableAutoSync)
+		owner.POST("/api/auto-sync/disable", handlers.DisableAutoSync)
+
+		owner.GET("/api/audit", handlers.GetAuditLogs)
+
+		owner.GET("/api/admins", handlers.GetAdmins)
+		owner.POST("/api/admins", handlers.CreateAdmin)
+		owner.PUT("/api/admins/:id", handlers.UpdateAdmin)
+		owner.DELETE("/api/admins/:id", handlers.DeleteAdmin)
+		owner.POST("/api/admins/:id/2fa/reset", handlers.ResetAdminTwoFactor)
+		owner.GET("/api/admins/:id/sessions", handlers.GetAdminSessions)
+		owner.DELETE("/api/admins/:id/sessions", handlers.RevokeAdminSessions)
+		owner.GET("/api/tokens", handlers.GetAPITokens)
+		owner.DELETE("/api/tokens/:id", handlers.RevokeAPIToken)
+
+		owner.GET("/api/settings/security", handlers.GetSecuritySettings)
+		owner.PUT("/api/settings/security", handlers.UpdateSecuritySettings)
+
+		owner.GET("/api/security/lockouts", handlers.GetLoginLockouts)
+		owner.DELETE("/api/security/lockouts/:id", handlers.UnlockLoginLockout)
+		owner.GET("/api/security/login-attempts", handlers.GetLoginAttempts)
 	}
 	r.NoRoute(func(c *gin.Context) {
 		path := c.Request.URL.Path
@@ -154,3 +228,36 @@ func startWebServer() {
 		}
 	}
 }
+
+// initRuntime 加载配置并初始化日志，Web 服务与命令行共用
+func initRuntime() {
+	if err := config.LoadConfig(); err != nil {
+		log.Fatalf("[ERROR] 配置加载失败: %v", err)
+	}
+	
+	zapLogger, err := utils.InitLogger(
+		config.AppConfig.Logging.File,
+		config.AppConfig.Logging.MaxSize,
+		config.AppConfig.Logging.MaxBackups,
+		config.AppConfig.Logging.MaxAge,
+		config.AppConfig.Logging.Compress,
+		config.AppConfig.Logging.Level,
+		config.AppConfig.Logging.DevMode,
+	)
+	if err != nil {
+		log.Fatalf("[ERROR] 日志系统初始化失败: %v", err)
+	}
+	
+	logger.Init(zapLogger)
+	log.Printf("[LOGGER] 日志系统初始化完成: 级别=%s, 文件=%s", config.AppConfig.Logging.Level, config.AppConfig.Logging.File)
+}
+
+// initSecrets 加载主密钥，并加密数据库中遗留的明文节点 API 密钥
+func initSecrets() {
+	if err := services.InitSecrets(); err != nil {
+		log.Fatalf("[ERROR] 主密钥加载失败: %v", err)
+	}
+	if _, err := services.EncryptNodeAPIKeys(); err != nil {
+		log.Fatalf("[ERROR] 加密节点 API 密钥失败: %v", err)
+	}
+}
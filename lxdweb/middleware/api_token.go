@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"lxdweb/database"
+	apperrors "lxdweb/errors"
+	"lxdweb/models"
+	"lxdweb/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenAuth 使用 API 令牌认证，令牌以所属管理员的身份和角色访问，只读令牌只允许 GET 请求
+func tokenAuth(c *gin.Context, raw string) {
+	token, err := services.LookupAPIToken(raw)
+	if err != nil {
+		code := apperrors.ERR_AUTH_INVALID_TOKEN
+		if errors.Is(err, services.ErrTokenExpired) {
+			code = apperrors.ERR_AUTH_EXPIRED
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"code": code,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	var admin models.Admin
+	if err := database.DB.First(&admin, token.AdminID).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"code": apperrors.ERR_AUTH_INVALID_TOKEN,
+			"msg":  services.ErrTokenInvalid.Error(),
+		})
+		return
+	}
+
+	if admin.MustChangePassword {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"code": apperrors.ERR_AUTH_PASSWORD_CHANGE,
+			"msg":  "账号需要先登录修改密码",
+		})
+		return
+	}
+
+	if token.Scope != models.TokenScopeWrite && !readOnlyMethod(c.Request.Method) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"code": apperrors.ERR_AUTH_NO_PERMISSION,
+			"msg":  "只读令牌不能执行写操作",
+		})
+		return
+	}
+
+	services.TouchAPIToken(token, c.ClientIP())
+	c.Set("admin_id", admin.ID)
+	c.Set("admin", &admin)
+	c.Set("api_token", token)
+	c.Next()
+}
+
+// CurrentToken 返回当前请求使用的 API 令牌，会话登录时为 nil
+func CurrentToken(c *gin.Context) *models.APIToken {
+	if v, ok := c.Get("api_token"); ok {
+		if token, ok := v.(*models.APIToken); ok {
+			return token
+		}
+	}
+	return nil
+}
+
+func readOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
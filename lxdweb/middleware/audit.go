@@ -0,0 +1,254 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"lxdweb/models"
+	"lxdweb/services"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// maxAuditResponse 为判断操作结果缓存的响应体长度
+const maxAuditResponse = 4096
+
+// auditRoute 路由对应的审计动作与目标类型
+type auditRoute struct {
+	Action     string
+	TargetType string
+}
+
+// auditRoutes 按 "方法 路由模板" 登记动作名称，未登记的写操作以方法和路径记录
+var auditRoutes = map[string]auditRoute{
+	"POST /login":                              {"auth.login", "admin"},
+	"POST /login/2fa":                          {"auth.login_2fa", "admin"},
+	"GET /login/oidc/callback":                 {"auth.oidc_login", "admin"},
+	"GET /logout":                              {"auth.logout", "admin"},
+	"POST /api/nodes":                          {"node.create", "node"},
+	"PUT /api/nodes/:id":                       {"node.update", "node"},
+	"DELETE /api/nodes/:id":                    {"node.delete", "node"},
+	"POST /api/nodes/:id/test":                 {"node.test", "node"},
+	"POST /api/nodes/:id/refresh":              {"node.refresh", "node"},
+	"POST /api/nodes/export/all":               {"node.export", "node"},
+	"POST /api/nodes/import/batch":             {"node.import", "node"},
+	"POST /api/nodes/delete/batch":             {"node.batch_delete", "node"},
+	"POST /api/containers/create":              {"container.create", "container"},
+	"POST /api/containers/:name/start":         {"container.start", "container"},
+	"POST /api/containers/:name/stop":          {"container.stop", "container"},
+	"POST /api/containers/:name/restart":       {"container.restart", "container"},
+	"POST /api/containers/:name/delete":        {"container.delete", "container"},
+	"POST /api/containers/:name/refresh":       {"container.refresh", "container"},
+	"POST /api/containers/:name/reinstall":     {"container.reinstall", "container"},
+	"POST /api/containers/:name/password":      {"container.password", "container"},
+	"POST /api/containers/:name/suspend":       {"container.suspend", "container"},
+	"POST /api/containers/:name/unsuspend":     {"container.unsuspend", "container"},
+	"POST /api/containers/:name/traffic/reset": {"container.traffic_reset", "container"},
+	"POST /api/containers/:name/nat":           {"container.nat_add", "container"},
+	"POST /api/containers/:name/nat/delete":    {"container.nat_delete", "container"},
+	"POST /api/containers/:name/ipv6":          {"container.ipv6_bind", "container"},
+	"POST /api/containers/:name/ipv6/delete":   {"container.ipv6_unbind", "container"},
+	"PUT /api/containers/:name/traffic/quota":  {"container.traffic_quota", "container"},
+	"POST /api/console/create-token":           {"container.console", "container"},
+	"POST /api/sync/all":                       {"sync.all", "node"},
+	"POST /api/sync/node/:id":                  {"sync.node", "node"},
+	"POST /api/auto-sync/enable":               {"sync.auto_enable", "system"},
+	"POST /api/auto-sync/disable":              {"sync.auto_disable", "system"},
+	"POST /api/admins":                         {"admin.create", "admin"},
+	"PUT /api/admins/:id":                      {"admin.update", "admin"},
+	"DELETE /api/admins/:id":                   {"admin.delete", "admin"},
+	"POST /api/admins/:id/2fa/reset":           {"admin.2fa_reset", "admin"},
+	"POST /api/account/password":               {"account.password", "admin"},
+	"POST /api/account/2fa/setup":              {"account.2fa_setup", "admin"},
+	"POST /api/account/2fa/enable":             {"account.2fa_enable", "admin"},
+	"POST /api/account/2fa/disable":            {"account.2fa_disable", "admin"},
+	"POST /api/account/2fa/recovery-codes":     {"account.recovery_codes", "admin"},
+	"POST /api/account/tokens":                 {"token.create", "token"},
+	"DELETE /api/account/tokens/:id":           {"token.delete", "token"},
+	"DELETE /api/tokens/:id":                   {"token.revoke", "token"},
+	"DELETE /api/account/sessions":             {"session.revoke_others", "admin"},
+	"DELETE /api/account/sessions/:id":         {"session.revoke", "admin"},
+	"DELETE /api/admins/:id/sessions":          {"session.revoke_admin", "admin"},
+	"PUT /api/settings/security":               {"settings.security", "system"},
+	"DELETE /api/security/lockouts/:id":        {"security.unlock", "system"},
+}
+
+// auditWriter 在写出响应的同时保留开头部分，用于解析 {code, msg}
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *auditWriter) capture(b []byte) {
+	if remain := maxAuditResponse - w.body.Len(); remain > 0 {
+		if len(b) > remain {
+			b = b[:remain]
+		}
+		w.body.Write(b)
+	}
+}
+
+// Audit 记录写操作及已登记的敏感读操作：操作人、动作、目标、脱敏后的请求参数、来源 IP 和结果
+func Audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, registered := auditRoutes[c.Request.Method+" "+c.FullPath()]
+		if !registered && (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions) {
+			c.Next()
+			return
+		}
+		if !registered {
+			route = auditRoute{Action: c.Request.Method + " " + c.FullPath()}
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		// 登出会清空会话，需在处理前读取操作人
+		session := sessions.Default(c)
+		adminID, username := sessionIdentity(session)
+
+		writer := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if id, name := sessionIdentity(session); id != 0 {
+			adminID, username = id, name
+		} else if admin := CurrentAdmin(c); admin != nil {
+			adminID, username = admin.ID, admin.Username
+		}
+		params := requestParams(c, body)
+		if username == "" {
+			if name, ok := params["username"].(string); ok {
+				username = name
+			}
+		}
+
+		entry := &models.OperationLog{
+			AdminID:       adminID,
+			Username:      username,
+			OperationType: route.Action,
+			TargetType:    route.TargetType,
+			IPAddress:     c.ClientIP(),
+			Status:        "success",
+		}
+		fillAuditTarget(entry, c, params)
+
+		detail := gin.H{
+			"method": c.Request.Method,
+			"path":   c.Request.URL.Path,
+			"query":  services.RedactValues(c.Request.URL.Query()),
+			"body":   params,
+		}
+		if token := CurrentToken(c); token != nil {
+			detail["api_token"] = token.Prefix
+		}
+		details, _ := json.Marshal(detail)
+		entry.Details = string(details)
+
+		if msg, failed := auditOutcome(c.Writer.Status(), writer.body.Bytes()); failed {
+			entry.Status = "failed"
+			entry.ErrorMessage = msg
+		}
+		services.RecordAudit(entry)
+	}
+}
+
+func sessionIdentity(session sessions.Session) (uint, string) {
+	var id uint
+	switch v := session.Get("admin_id").(type) {
+	case uint:
+		id = v
+	case int:
+		id = uint(v)
+	case int64:
+		id = uint(v)
+	}
+	name, _ := session.Get("username").(string)
+	return id, name
+}
+
+// requestParams 解析 JSON 或表单请求体并脱敏
+func requestParams(c *gin.Context, body []byte) map[string]interface{} {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	if strings.HasPrefix(c.ContentType(), "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			return services.RedactValues(values)
+		}
+	}
+	switch v := services.RedactJSON(body).(type) {
+	case map[string]interface{}:
+		return v
+	case []interface{}:
+		return map[string]interface{}{"items": v, "count": len(v)}
+	case nil:
+		return map[string]interface{}{"raw_size": len(body)}
+	default:
+		return map[string]interface{}{"value": v}
+	}
+}
+
+// fillAuditTarget 从路由参数、查询参数或请求体中提取操作目标
+func fillAuditTarget(entry *models.OperationLog, c *gin.Context, params map[string]interface{}) {
+	if id, err := strconv.ParseUint(c.Param("id"), 10, 32); err == nil {
+		entry.TargetID = uint(id)
+	}
+	if name := c.Param("name"); name != "" {
+		entry.TargetName = name
+	} else if hostname, ok := params["hostname"].(string); ok {
+		entry.TargetName = hostname
+	} else if name, ok := params["name"].(string); ok {
+		entry.TargetName = name
+	}
+
+	if entry.TargetType == "container" && entry.TargetID == 0 {
+		nodeID := c.Query("node_id")
+		if nodeID == "" && params["node_id"] != nil {
+			nodeID = fmt.Sprint(params["node_id"])
+		}
+		if id, err := strconv.ParseUint(nodeID, 10, 32); err == nil {
+			entry.TargetID = uint(id)
+		}
+	}
+}
+
+// auditOutcome 根据 HTTP 状态码和响应中的 code 判断操作是否失败
+func auditOutcome(status int, body []byte) (string, bool) {
+	var resp struct {
+		Code *int   `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	json.Unmarshal(body, &resp)
+
+	if status >= http.StatusBadRequest {
+		if resp.Msg == "" {
+			resp.Msg = http.StatusText(status)
+		}
+		return resp.Msg, true
+	}
+	if resp.Code != nil && *resp.Code != 200 {
+		return resp.Msg, true
+	}
+	return "", false
+}
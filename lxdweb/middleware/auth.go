@@ -1,19 +1,118 @@
 package middleware
 import (
 	"net/http"
+	"strings"
+	"lxdweb/database"
+	apperrors "lxdweb/errors"
+	"lxdweb/models"
+	"lxdweb/services"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
+// AuthRequired 校验会话或 Authorization: Bearer 令牌，未登录时 /api/* 返回 JSON 401，页面跳转登录
 func AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			tokenAuth(c, strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+			return
+		}
+
 		session := sessions.Default(c)
 		adminID := session.Get("admin_id")
 		if adminID == nil {
-			c.Redirect(http.StatusFound, "/login")
-			c.Abort()
+			unauthorized(c)
+			return
+		}
+		// 每次请求读取管理员，角色变更和账号删除立即生效
+		var admin models.Admin
+		if err := database.DB.First(&admin, adminID).Error; err != nil {
+			session.Clear()
+			session.Save()
+			unauthorized(c)
+			return
+		}
+		c.Set("admin_id", admin.ID)
+		c.Set("admin", &admin)
+
+		// 需要修改密码时只能打开页面、查看账号和修改密码，页面会弹出修改密码窗口
+		if admin.MustChangePassword && strings.HasPrefix(c.Request.URL.Path, "/api/") && !passwordChangeAllowed(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"code": apperrors.ERR_AUTH_PASSWORD_CHANGE,
+				"msg":  "请先修改密码",
+			})
+			return
+		}
+
+		// 强制两步验证时，未绑定的账号只能访问页面和账号接口，页面会弹出绑定窗口。
+		// 单点登录账号的多因素认证由身份提供方负责
+		if !admin.TOTPEnabled && admin.AuthSource != models.AuthSourceOIDC && strings.HasPrefix(c.Request.URL.Path, "/api/") &&
+			!strings.HasPrefix(c.Request.URL.Path, "/api/account") && services.TwoFactorEnforced() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"code": apperrors.ERR_AUTH_2FA_REQUIRED,
+				"msg":  "请先启用两步验证",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+// RequireSession 要求通过登录会话访问，用于令牌管理、修改密码等不允许令牌调用的接口
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if CurrentToken(c) != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"code": apperrors.ERR_AUTH_NO_PERMISSION,
+				"msg":  "该接口不支持API令牌访问",
+			})
 			return
 		}
-		c.Set("admin_id", adminID)
 		c.Next()
 	}
 }
+
+func passwordChangeAllowed(c *gin.Context) bool {
+	path := c.Request.URL.Path
+	return (c.Request.Method == http.MethodGet && path == "/api/account") ||
+		(c.Request.Method == http.MethodPost && path == "/api/account/password")
+}
+
+func unauthorized(c *gin.Context) {
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"code": apperrors.ERR_AUTH_FAILED,
+			"msg":  "未登录或登录已过期",
+		})
+		return
+	}
+	c.Redirect(http.StatusFound, "/login")
+	c.Abort()
+}
+
+// RequireRole 要求当前管理员角色不低于 role，需在 AuthRequired 之后使用
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		admin := CurrentAdmin(c)
+		if admin == nil || !admin.HasRole(role) {
+			if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"code": apperrors.ERR_AUTH_NO_PERMISSION,
+					"msg":  "权限不足",
+				})
+			} else {
+				c.String(http.StatusForbidden, "权限不足")
+				c.Abort()
+			}
+			return
+		}
+		c.Next()
+	}
+}
+// CurrentAdmin 返回 AuthRequired 加载的当前管理员
+func CurrentAdmin(c *gin.Context) *models.Admin {
+	if v, ok := c.Get("admin"); ok {
+		if admin, ok := v.(*models.Admin); ok {
+			return admin
+		}
+	}
+	return nil
+}
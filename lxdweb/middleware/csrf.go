@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	apperrors "lxdweb/errors"
+	"lxdweb/services"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// csrfSessionKey 会话中保存 CSRF 令牌的键
+	csrfSessionKey = "csrf_token"
+	// CSRFCookieName 页面脚本从该 Cookie 读取令牌，写请求通过 X-CSRF-Token 头或 csrf_token 表单字段提交
+	CSRFCookieName = "lxdweb_csrf"
+	CSRFHeaderName = "X-CSRF-Token"
+	csrfFormField  = "csrf_token"
+)
+
+// CSRF 为会话生成 CSRF 令牌并校验写请求提交的令牌。
+// 令牌保存在服务端会话中，Cookie 只用于让页面脚本读取；使用 API 令牌认证的请求不依赖 Cookie，直接放行
+func CSRF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if CurrentToken(c) != nil || strings.HasPrefix(c.GetHeader("Authorization"), "Bearer ") {
+			c.Next()
+			return
+		}
+
+		session := sessions.Default(c)
+		token, _ := session.Get(csrfSessionKey).(string)
+
+		if readOnlyMethod(c.Request.Method) {
+			if token == "" {
+				var err error
+				if token, err = newCSRFToken(); err != nil {
+					c.Next()
+					return
+				}
+				session.Set(csrfSessionKey, token)
+				session.Save()
+			}
+			if cookie, err := c.Cookie(CSRFCookieName); err != nil || cookie != token {
+				setCSRFCookie(c, token)
+			}
+			c.Next()
+			return
+		}
+
+		submitted := c.GetHeader(CSRFHeaderName)
+		if submitted == "" && strings.HasPrefix(c.ContentType(), "application/x-www-form-urlencoded") {
+			submitted = c.PostForm(csrfFormField)
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"code": apperrors.ERR_AUTH_CSRF,
+				"msg":  "CSRF 校验失败，请刷新页面后重试",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func setCSRFCookie(c *gin.Context, token string) {
+	secure, sameSite := services.SessionCookieOptions()
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    token,
+		Path:     "/",
+		Secure:   secure,
+		HttpOnly: false,
+		SameSite: sameSite,
+	})
+}
+
+func newCSRFToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"time"
+
+	"lxdweb/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics 记录每个请求的处理耗时，路由使用注册时的模板路径避免标签基数过大
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		services.ObserveHTTPRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"lxdweb/database"
+	apperrors "lxdweb/errors"
+	"lxdweb/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// nodeScope 当前请求可访问的节点，All 为 true 表示不受限制
+type nodeScope struct {
+	All bool
+	IDs map[uint]bool
+}
+
+// nodeParamRoutes 路由参数 :id 表示节点ID的路由前缀
+var nodeParamRoutes = []string{"/api/nodes/:id", "/nodes/:id", "/api/sync/node/:id"}
+
+// nodeRequiredRoutes 必须指定节点的路由前缀，缺少节点ID时直接拒绝
+var nodeRequiredRoutes = []string{"/api/containers/:name", "/api/containers/create", "/api/console/", "/api/nat/"}
+
+// NodeScope 拒绝访问未授权节点的请求。节点ID从路由参数、node_id 查询参数或 JSON 请求体中读取，
+// 查询参数与请求体同时给出时必须一致。解析出的节点ID保存在上下文中，处理函数通过 RequestNodeID 读取，
+// 不再自行解析请求；列表类接口需在处理函数中调用 ScopeNodes 过滤
+func NodeScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		nodeID, ok, err := requestNodeID(c)
+		if err != nil {
+			badNodeRequest(c, err.Error())
+			return
+		}
+		if !ok {
+			if nodeRequired(c.FullPath()) {
+				badNodeRequest(c, "缺少节点ID")
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Set("request_node_id", nodeID)
+		if !CanAccessNode(c, nodeID) {
+			denyNode(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequestNodeID 返回 NodeScope 从请求中解析出的节点ID
+func RequestNodeID(c *gin.Context) (uint, bool) {
+	if v, ok := c.Get("request_node_id"); ok {
+		return v.(uint), true
+	}
+	return 0, false
+}
+
+// CanAccessNode 判断当前管理员是否可以访问节点
+func CanAccessNode(c *gin.Context, nodeID uint) bool {
+	scope := currentScope(c)
+	return scope.All || scope.IDs[nodeID]
+}
+
+// ScopeNodes 为查询追加节点过滤条件，column 为节点ID所在的列
+func ScopeNodes(c *gin.Context, query *gorm.DB, column string) *gorm.DB {
+	scope := currentScope(c)
+	if scope.All {
+		return query
+	}
+	ids := make([]uint, 0, len(scope.IDs))
+	for id := range scope.IDs {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return query.Where("1 = 0")
+	}
+	return query.Where(column+" IN ?", ids)
+}
+
+// CanViewNodeSecrets 只有 owner 可以查看节点 API Key 的掩码等凭据信息
+func CanViewNodeSecrets(c *gin.Context) bool {
+	admin := CurrentAdmin(c)
+	return admin != nil && admin.Role == models.RoleOwner
+}
+
+func currentScope(c *gin.Context) *nodeScope {
+	if v, ok := c.Get("node_scope"); ok {
+		return v.(*nodeScope)
+	}
+
+	scope := &nodeScope{All: true}
+	admin := CurrentAdmin(c)
+	if admin == nil {
+		scope.All = false
+	} else if admin.NodeScoped && admin.Role != models.RoleOwner {
+		scope.All = false
+		scope.IDs = make(map[uint]bool)
+		var ids []uint
+		database.DB.Model(&models.AdminNode{}).Where("admin_id = ?", admin.ID).Pluck("node_id", &ids)
+		for _, id := range ids {
+			scope.IDs[id] = true
+		}
+	}
+
+	// 令牌限定了节点时取与管理员授权的交集
+	if token := CurrentToken(c); token != nil && len(token.NodeIDs) > 0 {
+		ids := make(map[uint]bool, len(token.NodeIDs))
+		for _, id := range token.NodeIDs {
+			if scope.All || scope.IDs[id] {
+				ids[id] = true
+			}
+		}
+		scope.All = false
+		scope.IDs = ids
+	}
+	c.Set("node_scope", scope)
+	return scope
+}
+
+// requestNodeID 读取请求中的节点ID，第二个返回值表示请求是否指定了节点。
+// 节点ID无法解析或查询参数与请求体不一致时返回错误
+func requestNodeID(c *gin.Context) (uint, bool, error) {
+	route := c.FullPath()
+	for _, prefix := range nodeParamRoutes {
+		if route == prefix || strings.HasPrefix(route, prefix+"/") {
+			id, err := parseNodeID(c.Param("id"))
+			return id, err == nil, err
+		}
+	}
+
+	var ids []uint
+	if v := c.Query("node_id"); v != "" {
+		id, err := parseNodeID(v)
+		if err != nil {
+			return 0, false, err
+		}
+		ids = append(ids, id)
+	}
+	if v, ok := bodyNodeID(c); ok {
+		id, err := parseNodeID(v)
+		if err != nil {
+			return 0, false, err
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return 0, false, nil
+	}
+	if len(ids) > 1 && ids[0] != ids[1] {
+		return 0, false, errors.New("查询参数与请求体中的节点ID不一致")
+	}
+	return ids[0], true, nil
+}
+
+// bodyNodeID 读取 JSON 请求体中的 node_id 字段，读取后恢复请求体供处理函数绑定
+func bodyNodeID(c *gin.Context) (string, bool) {
+	if c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), "application/json") {
+		return "", false
+	}
+	body, _ := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	var req struct {
+		NodeID json.RawMessage `json:"node_id"`
+	}
+	if json.Unmarshal(body, &req) != nil || len(req.NodeID) == 0 || string(req.NodeID) == "null" {
+		return "", false
+	}
+	v := strings.Trim(string(req.NodeID), `"`)
+	return v, v != ""
+}
+
+func parseNodeID(v string) (uint, error) {
+	id, err := strconv.ParseUint(strings.TrimSpace(v), 10, 32)
+	if err != nil || id == 0 {
+		return 0, errors.New("节点ID无效")
+	}
+	return uint(id), nil
+}
+
+func nodeRequired(route string) bool {
+	for _, prefix := range nodeRequiredRoutes {
+		if strings.HasPrefix(route, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func badNodeRequest(c *gin.Context, msg string) {
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"code": 400,
+			"msg":  msg,
+		})
+		return
+	}
+	c.String(http.StatusBadRequest, msg)
+	c.Abort()
+}
+
+func denyNode(c *gin.Context) {
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"code": apperrors.ERR_AUTH_NO_PERMISSION,
+			"msg":  "无权访问该节点",
+		})
+		return
+	}
+	c.String(http.StatusForbidden, "无权访问该节点")
+	c.Abort()
+}
@@ -4,14 +4,44 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
+// 管理员角色：owner 拥有全部权限，operator 可操作容器和同步，readonly 只能查看
+const (
+	RoleOwner    = "owner"
+	RoleOperator = "operator"
+	RoleReadOnly = "readonly"
+)
+// 账号来源：local 使用本地密码登录，oidc 由单点登录自动创建
+const (
+	AuthSourceLocal = "local"
+	AuthSourceOIDC  = "oidc"
+)
+var roleLevels = map[string]int{
+	RoleReadOnly: 1,
+	RoleOperator: 2,
+	RoleOwner:    3,
+}
 type Admin struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Username  string         `json:"username" gorm:"uniqueIndex;size:100;not null"`
-	Password  string         `json:"-" gorm:"size:255;not null"`
-	Email     string         `json:"email" gorm:"size:255"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                 uint           `json:"id" gorm:"primaryKey"`
+	Username           string         `json:"username" gorm:"uniqueIndex;size:100;not null"`
+	Password           string         `json:"-" gorm:"size:255;not null"`
+	Email              string         `json:"email" gorm:"size:255"`
+	Role               string         `json:"role" gorm:"size:20;not null;default:'owner'"`
+	// NodeScoped 为 true 时只能访问 admin_nodes 中授权的节点，owner 不受限制
+	NodeScoped         bool           `json:"node_scoped" gorm:"default:false"`
+	// NodeIDs 授权节点列表，由 admin_nodes 加载，不落库
+	NodeIDs            []uint         `json:"node_ids" gorm:"-"`
+	// TOTP 两步验证，TOTPLastStep 记录最近一次通过的时间步以防重放
+	TOTPSecret         string         `json:"-" gorm:"size:64"`
+	TOTPEnabled        bool           `json:"totp_enabled" gorm:"default:false"`
+	TOTPLastStep       int64          `json:"-" gorm:"default:0"`
+	// MustChangePassword 为 true 时除修改密码外的接口均不可用，修改密码后清除
+	MustChangePassword bool           `json:"must_change_password" gorm:"default:false"`
+	// AuthSource 账号来源，OIDCSubject 为身份提供方的 sub，用于单点登录时匹配账号
+	AuthSource         string         `json:"auth_source" gorm:"size:20;not null;default:'local'"`
+	OIDCSubject        string         `json:"-" gorm:"column:oidc_subject;size:255;index"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `json:"-" gorm:"index"`
 }
 func (a *Admin) HashPassword() error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(a.Password), bcrypt.DefaultCost)
@@ -25,3 +55,12 @@ func (a *Admin) CheckPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(a.Password), []byte(password))
 	return err == nil
 }
+// ValidRole 判断角色名是否有效
+func ValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+// HasRole 判断管理员角色是否不低于 role
+func (a *Admin) HasRole(role string) bool {
+	return roleLevels[a.Role] >= roleLevels[role]
+}
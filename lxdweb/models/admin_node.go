@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// AdminNode 管理员与节点的授权关系，仅对 NodeScoped 的管理员生效
+type AdminNode struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	AdminID   uint      `json:"admin_id" gorm:"not null;uniqueIndex:idx_admin_node"`
+	NodeID    uint      `json:"node_id" gorm:"not null;uniqueIndex:idx_admin_node;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (AdminNode) TableName() string {
+	return "admin_nodes"
+}
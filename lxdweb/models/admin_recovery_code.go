@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// AdminRecoveryCode 两步验证恢复码，只保存 SHA-256 摘要，使用后记录 UsedAt
+type AdminRecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	AdminID   uint       `json:"admin_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"size:64;not null;index"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (AdminRecoveryCode) TableName() string {
+	return "admin_recovery_codes"
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// AdminSession 服务端会话，Cookie 中只保存签名后的 SessionKey，删除记录即可吊销会话。
+// 登录前（验证码等）的匿名会话 AdminID 为 0
+type AdminSession struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	SessionKey string    `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	AdminID    uint      `json:"admin_id" gorm:"index"`
+	Data       []byte    `json:"-"`
+	IPAddress  string    `json:"ip_address" gorm:"size:50"`
+	UserAgent  string    `json:"user_agent" gorm:"size:255"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"index"`
+}
+
+func (AdminSession) TableName() string {
+	return "admin_sessions"
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// API 令牌权限范围：read 只允许 GET 请求，write 与所属管理员权限相同
+const (
+	TokenScopeRead  = "read"
+	TokenScopeWrite = "write"
+)
+
+// APIToken 个人访问令牌，只保存 SHA-256 摘要，Prefix 为明文前缀便于识别。
+// NodeIDs 非空时只能访问这些节点（仍受所属管理员的节点授权限制）
+type APIToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	AdminID    uint       `json:"admin_id" gorm:"not null;index"`
+	Name       string     `json:"name" gorm:"size:100;not null"`
+	Prefix     string     `json:"prefix" gorm:"size:20;not null"`
+	TokenHash  string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Scope      string     `json:"scope" gorm:"size:10;not null;default:'read'"`
+	NodeIDs    []uint     `json:"node_ids" gorm:"serializer:json;type:text"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip" gorm:"size:50"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
+
+// Expired 判断令牌是否已过期
+func (t *APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
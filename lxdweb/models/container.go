@@ -21,17 +21,20 @@ type Container struct {
 	Node Node `json:"node" gorm:"foreignKey:NodeID"`
 }
 
+// OperationLog 操作审计记录。容器操作的 TargetName 为容器名称，TargetID 为所在节点ID
 type OperationLog struct {
 	ID            uint      `json:"id" gorm:"primaryKey"`
 	AdminID       uint      `json:"admin_id" gorm:"index"`
-	OperationType string    `json:"operation_type" gorm:"size:50;not null"` 
+	Username      string    `json:"username" gorm:"size:100;index"`
+	OperationType string    `json:"operation_type" gorm:"size:50;not null;index"` 
 	TargetType    string    `json:"target_type" gorm:"size:50"`             
 	TargetID      uint      `json:"target_id"`
+	TargetName    string    `json:"target_name" gorm:"size:200"`
 	Details       string    `json:"details" gorm:"type:text"`
 	IPAddress     string    `json:"ip_address" gorm:"size:100"`
 	Status        string    `json:"status" gorm:"size:50"`       
 	ErrorMessage  string    `json:"error_message" gorm:"type:text"`
-	CreatedAt     time.Time `json:"created_at"`
+	CreatedAt     time.Time `json:"created_at" gorm:"index"`
 }
 type Image struct {
 	ID           uint           `json:"id" gorm:"primaryKey"`
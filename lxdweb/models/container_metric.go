@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// ContainerMetric 容器资源历史。Step 为 0 表示同步时写入的原始采样，
+// 其他值表示按该秒数聚合后的数据，Timestamp 为聚合区间起点
+type ContainerMetric struct {
+	ID           uint      `json:"-" gorm:"primaryKey"`
+	NodeID       uint      `json:"node_id" gorm:"not null;uniqueIndex:idx_metric_point"`
+	Hostname     string    `json:"hostname" gorm:"size:200;not null;uniqueIndex:idx_metric_point"`
+	Step         int       `json:"step" gorm:"not null;uniqueIndex:idx_metric_point;index:idx_metric_step_time"`
+	Timestamp    time.Time `json:"timestamp" gorm:"not null;uniqueIndex:idx_metric_point;index:idx_metric_step_time"`
+	Samples      int       `json:"samples"`
+	CPUUsage     float64   `json:"cpu_usage"`
+	CPUMax       float64   `json:"cpu_max"`
+	MemoryUsage  uint64    `json:"memory_usage"`
+	MemoryTotal  uint64    `json:"memory_total"`
+	DiskUsage    uint64    `json:"disk_usage"`
+	DiskTotal    uint64    `json:"disk_total"`
+	TrafficTotal uint64    `json:"traffic_total"`
+	TrafficIn    uint64    `json:"traffic_in"`
+	TrafficOut   uint64    `json:"traffic_out"`
+}
+
+func (ContainerMetric) TableName() string {
+	return "container_metrics"
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type IPv6BindRequest struct {
+	Description string `json:"description"`
+}
+
+type IPv6UnbindRequest struct {
+	PublicIPv6 string `json:"public_ipv6" binding:"required"`
+}
+
+// IPv6BindingCache 同步时从节点 /api/ipv6/list 拉取的独立 IPv6 绑定，用于统计地址池使用情况
+type IPv6BindingCache struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	NodeID      uint      `json:"node_id" gorm:"not null;index;uniqueIndex:idx_unique_ipv6_binding"`
+	NodeName    string    `json:"node_name" gorm:"size:200"`
+	Hostname    string    `json:"hostname" gorm:"size:200;not null;index"`
+	PublicIPv6  string    `json:"public_ipv6" gorm:"size:64;not null;uniqueIndex:idx_unique_ipv6_binding"`
+	Status      string    `json:"status" gorm:"size:50"`
+	Description string    `json:"description" gorm:"size:500"`
+	LastSync    time.Time `json:"last_sync"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (IPv6BindingCache) TableName() string {
+	return "ipv6_bindings"
+}
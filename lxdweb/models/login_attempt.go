@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// 登录锁定范围
+const (
+	LockoutScopeUser = "user"
+	LockoutScopeIP   = "ip"
+)
+
+// LoginAttempt 登录尝试记录，Reason 为失败原因：captcha、password、2fa、locked
+type LoginAttempt struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Username  string    `json:"username" gorm:"size:100;index:idx_login_user_time"`
+	IPAddress string    `json:"ip_address" gorm:"size:50;index:idx_login_ip_time"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason" gorm:"size:20"`
+	CreatedAt time.Time `json:"created_at" gorm:"index:idx_login_user_time;index:idx_login_ip_time;index"`
+}
+
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+// LoginLockout 用户名或 IP 的锁定状态，LockedUntil 之前拒绝登录。
+// CountFrom 之前的失败不再计入，锁定和解锁时更新，避免窗口内旧记录立即再次触发锁定
+type LoginLockout struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Scope       string    `json:"scope" gorm:"size:10;not null;uniqueIndex:idx_lockout_target"`
+	Value       string    `json:"value" gorm:"size:100;not null;uniqueIndex:idx_lockout_target"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until" gorm:"index"`
+	CountFrom   time.Time `json:"-"`
+	UnlockedBy  string    `json:"unlocked_by" gorm:"size:100"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (LoginLockout) TableName() string {
+	return "login_lockouts"
+}
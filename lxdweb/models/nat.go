@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// NATRuleRequest 添加或删除端口转发规则的参数，端口段需同时填写两个结束端口
+type NATRuleRequest struct {
+	// tcp | udp | both
+	Protocol        string `json:"protocol" binding:"required"`
+	ExternalPort    int    `json:"external_port"`
+	ExternalPortEnd int    `json:"external_port_end"`
+	InternalPort    int    `json:"internal_port" binding:"required"`
+	InternalPortEnd int    `json:"internal_port_end"`
+	Description     string `json:"description"`
+}
+
+// NATRuleCache 同步时从节点 /api/natlist 拉取的端口转发规则，用于全局端口分配视图
+type NATRuleCache struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	NodeID          uint      `json:"node_id" gorm:"not null;index:idx_nat_node_port;uniqueIndex:idx_unique_nat_rule"`
+	NodeName        string    `json:"node_name" gorm:"size:200"`
+	Hostname        string    `json:"hostname" gorm:"size:200;not null;uniqueIndex:idx_unique_nat_rule"`
+	Protocol        string    `json:"protocol" gorm:"size:10;not null;index:idx_nat_node_port;uniqueIndex:idx_unique_nat_rule"`
+	ExternalPort    int       `json:"external_port" gorm:"not null;index:idx_nat_node_port;uniqueIndex:idx_unique_nat_rule"`
+	ExternalPortEnd int       `json:"external_port_end"`
+	InternalPort    int       `json:"internal_port"`
+	InternalPortEnd int       `json:"internal_port_end"`
+	Status          string    `json:"status" gorm:"size:50"`
+	Description     string    `json:"description" gorm:"size:500"`
+	LastSync        time.Time `json:"last_sync"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (NATRuleCache) TableName() string {
+	return "nat_rules"
+}
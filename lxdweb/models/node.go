@@ -1,5 +1,6 @@
 package models
 import (
+	"encoding/json"
 	"time"
 	"gorm.io/gorm"
 )
@@ -8,34 +9,63 @@ type Node struct {
 	Name           string         `json:"name" gorm:"uniqueIndex;size:200;not null"`
 	Description    string         `json:"description" gorm:"type:text"`
 	Address        string         `json:"address" gorm:"size:500;not null"` 
-	APIKey         string         `json:"api_key" gorm:"size:500"`          
+	APIKey         string         `json:"-" gorm:"size:500"`                 
 	Status         string         `json:"status" gorm:"size:50;default:'inactive'"` 
 	LastCheck      *time.Time     `json:"last_check"`
+	LatencyMs      int64          `json:"latency_ms"`
+	FailCount      int            `json:"fail_count"`
 	AutoSync       bool           `json:"auto_sync" gorm:"default:false"`
 	SyncInterval   int            `json:"sync_interval" gorm:"default:300"`
 	BatchSize      int            `json:"batch_size" gorm:"default:5"`
 	BatchInterval  int            `json:"batch_interval" gorm:"default:5"`
+	TLSMode        string         `json:"tls_mode" gorm:"size:20;default:'pin'"`
+	TLSFingerprint string         `json:"tls_fingerprint" gorm:"size:100"`
+	TLSCACert      string         `json:"tls_ca_cert" gorm:"type:text"`
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
 }
 type CreateNodeRequest struct {
-	Name          string `json:"name" binding:"required"`
-	Description   string `json:"description"`
-	Address       string `json:"address" binding:"required"`
-	APIKey        string `json:"api_key"`
-	AutoSync      bool   `json:"auto_sync"`
-	SyncInterval  int    `json:"sync_interval"`
-	BatchSize     int    `json:"batch_size"`
-	BatchInterval int    `json:"batch_interval"`
+	Name           string `json:"name" binding:"required"`
+	Description    string `json:"description"`
+	Address        string `json:"address" binding:"required"`
+	APIKey         string `json:"api_key"`
+	AutoSync       bool   `json:"auto_sync"`
+	SyncInterval   int    `json:"sync_interval"`
+	BatchSize      int    `json:"batch_size"`
+	BatchInterval  int    `json:"batch_interval"`
+	TLSMode        string `json:"tls_mode"`
+	TLSFingerprint string `json:"tls_fingerprint"`
+	TLSCACert      string `json:"tls_ca_cert"`
 }
 type UpdateNodeRequest struct {
-	Name          string `json:"name"`
-	Description   string `json:"description"`
-	Address       string `json:"address"`
-	APIKey        string `json:"api_key"`
-	AutoSync      bool   `json:"auto_sync"`
-	SyncInterval  int    `json:"sync_interval"`
-	BatchSize     int    `json:"batch_size"`
-	BatchInterval int    `json:"batch_interval"`
+	Name                string `json:"name"`
+	Description         string `json:"description"`
+	Address             string `json:"address"`
+	APIKey              string `json:"api_key"`
+	AutoSync            bool   `json:"auto_sync"`
+	SyncInterval        int    `json:"sync_interval"`
+	BatchSize           int    `json:"batch_size"`
+	BatchInterval       int    `json:"batch_interval"`
+	TLSMode             string `json:"tls_mode"`
+	TLSFingerprint      string `json:"tls_fingerprint"`
+	TLSCACert           string `json:"tls_ca_cert"`
+	// 清空已固定的指纹，下次测试连接时重新信任
+	ResetTLSFingerprint bool   `json:"reset_tls_fingerprint"`
+}
+type ExportNodesRequest struct {
+	// 设置口令时导出数据加密，并包含 API 密钥
+	Passphrase string `json:"passphrase"`
+}
+type ImportNodesRequest struct {
+	// 导出文件内容，也兼容旧版的节点数组
+	Bundle     json.RawMessage `json:"bundle" binding:"required"`
+	Passphrase string          `json:"passphrase"`
+	// 名称冲突时的处理方式: skip | overwrite | rename
+	Strategy string `json:"strategy"`
+	DryRun   bool   `json:"dry_run"`
+	// 不请求节点 /api/check 验证连接
+	SkipCheck bool `json:"skip_check"`
+	// 允许导入未经过连接检查的节点（未提供 API 密钥或 skip_check），否则这些节点不会导入
+	AllowUnchecked bool `json:"allow_unchecked"`
 }
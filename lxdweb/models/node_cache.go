@@ -6,16 +6,21 @@ import (
 )
 
 type NodeInfoCache struct {
-	ID             uint           `json:"id" gorm:"primaryKey"`
-	NodeID         uint           `json:"node_id" gorm:"uniqueIndex;not null"`
+	ID               uint           `json:"id" gorm:"primaryKey"`
+	NodeID           uint           `json:"node_id" gorm:"uniqueIndex;not null"`
 
-	SystemInfo     string         `json:"system_info" gorm:"type:text"`
+	SystemInfo       string         `json:"system_info" gorm:"type:text"`
 
-	LastSync       time.Time      `json:"last_sync"`
+	LastSync         time.Time      `json:"last_sync"`
+
+	CircuitState     string         `json:"circuit_state" gorm:"size:20;default:'closed'"`
+	CircuitFailures  int            `json:"circuit_failures"`
+	CircuitOpenedAt  *time.Time     `json:"circuit_opened_at"`
+	CircuitLastError string         `json:"circuit_last_error" gorm:"type:text"`
 	
-	CreatedAt      time.Time      `json:"created_at"`
-	UpdatedAt      time.Time      `json:"updated_at"`
-	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 func (NodeInfoCache) TableName() string {
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// NodeHealthCheck 节点健康检查记录，每次探测 /api/check 写入一条
+type NodeHealthCheck struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	NodeID    uint      `json:"node_id" gorm:"not null;index:idx_node_checked"`
+	Status    string    `json:"status" gorm:"size:50"`
+	Success   bool      `json:"success"`
+	LatencyMs int64     `json:"latency_ms"`
+	Error     string    `json:"error" gorm:"type:text"`
+	CheckedAt time.Time `json:"checked_at" gorm:"index:idx_node_checked"`
+}
+
+func (NodeHealthCheck) TableName() string {
+	return "node_health_checks"
+}
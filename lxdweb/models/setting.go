@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// Setting 可在后台修改的全局设置，以键值形式保存
+type Setting struct {
+	Key       string    `json:"key" gorm:"primaryKey;size:100"`
+	Value     string    `json:"value" gorm:"type:text"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Setting) TableName() string {
+	return "settings"
+}
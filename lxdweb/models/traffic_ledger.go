@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// TrafficLedger 容器按自然月累计的流量账本。
+// Rx/Tx/Total 为本月累计增量，Last* 为最近一次读取到的节点计数器，用于计算下一次增量
+type TrafficLedger struct {
+	ID         uint   `json:"id" gorm:"primaryKey"`
+	NodeID     uint   `json:"node_id" gorm:"not null;uniqueIndex:idx_traffic_period"`
+	Hostname   string `json:"hostname" gorm:"size:200;not null;uniqueIndex:idx_traffic_period"`
+	Period     string `json:"period" gorm:"size:7;not null;uniqueIndex:idx_traffic_period;index"`
+	RxBytes    uint64 `json:"rx_bytes"`
+	TxBytes    uint64 `json:"tx_bytes"`
+	TotalBytes uint64 `json:"total_bytes"`
+	LastRx     uint64 `json:"last_rx"`
+	LastTx     uint64 `json:"last_tx"`
+	LastTotal  uint64 `json:"last_total"`
+	// 基线是否来自 /api/traffic 的分方向计数器，与 traffic_usage_raw 的总量不可混用
+	LastDirectional bool      `json:"last_directional"`
+	Resets          int       `json:"resets"`
+	LastSample      time.Time `json:"last_sample"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (TrafficLedger) TableName() string {
+	return "traffic_ledgers"
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// TrafficQuota 容器流量配额周期状态。AnchorDay、Action 为 0 或空时使用全局配置
+type TrafficQuota struct {
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	NodeID           uint       `json:"node_id" gorm:"not null;uniqueIndex:idx_quota_container"`
+	Hostname         string     `json:"hostname" gorm:"size:200;not null;uniqueIndex:idx_quota_container"`
+	AnchorDay        int        `json:"anchor_day"`
+	Action           string     `json:"action" gorm:"size:20"`
+	CycleStart       time.Time  `json:"cycle_start"`
+	NextReset        time.Time  `json:"next_reset" gorm:"index"`
+	WarnedLevel      int        `json:"warned_level"`
+	SuspendedByQuota bool       `json:"suspended_by_quota"`
+	LastResetAt      *time.Time `json:"last_reset_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// TrafficQuotaEvent 配额调度执行的动作记录：reset、warn、suspend、unsuspend
+type TrafficQuotaEvent struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	NodeID     uint      `json:"node_id" gorm:"index"`
+	Hostname   string    `json:"hostname" gorm:"size:200;index"`
+	Action     string    `json:"action" gorm:"size:20"`
+	Threshold  int       `json:"threshold"`
+	UsageBytes uint64    `json:"usage_bytes"`
+	LimitBytes uint64    `json:"limit_bytes"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error" gorm:"type:text"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+func (TrafficQuota) TableName() string {
+	return "traffic_quotas"
+}
+
+func (TrafficQuotaEvent) TableName() string {
+	return "traffic_quota_events"
+}
@@ -0,0 +1,196 @@
+package lxdapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BreakerState 熔断器状态
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// ErrCircuitOpen 节点熔断期间直接拒绝请求，不再等待超时
+var ErrCircuitOpen = errors.New("节点连续请求失败，已熔断")
+
+// IsCircuitOpen 判断错误是否由熔断引起
+func IsCircuitOpen(err error) bool {
+	return errors.Is(err, ErrCircuitOpen)
+}
+
+// BreakerSnapshot 熔断器当前状态
+type BreakerSnapshot struct {
+	State     BreakerState `json:"state"`
+	Failures  int          `json:"failures"`
+	OpenedAt  *time.Time   `json:"opened_at"`
+	LastError string       `json:"last_error"`
+}
+
+// BreakerOptions 熔断参数，OnStateChange 在状态切换后调用（不持有锁）
+type BreakerOptions struct {
+	Threshold     int
+	Cooldown      time.Duration
+	OnStateChange func(from, to BreakerState, snap BreakerSnapshot)
+}
+
+// Breaker 单个节点的熔断器。
+// closed 状态下连续失败 Threshold 次后进入 open，open 期间请求直接失败；
+// 冷却 Cooldown 后进入 half_open，只放行一个探测请求，成功则恢复 closed，失败则重新 open。
+type Breaker struct {
+	mu        sync.Mutex
+	opts      BreakerOptions
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	lastError string
+	probing   bool
+}
+
+func NewBreaker(opts BreakerOptions) *Breaker {
+	if opts.Threshold < 1 {
+		opts.Threshold = 5
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = 30 * time.Second
+	}
+	return &Breaker{opts: opts, state: BreakerClosed}
+}
+
+// Allow 判断是否放行请求
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.opts.Cooldown {
+			b.mu.Unlock()
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		b.transition(BreakerHalfOpen)
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			b.mu.Unlock()
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// Success 记录一次成功请求
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	b.failures = 0
+	b.probing = false
+	if b.state != BreakerClosed {
+		b.lastError = ""
+		b.transition(BreakerClosed)
+		return
+	}
+	b.mu.Unlock()
+}
+
+// Failure 记录一次失败请求
+func (b *Breaker) Failure(err error) {
+	b.mu.Lock()
+	b.failures++
+	b.probing = false
+	if err != nil {
+		b.lastError = err.Error()
+	}
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.opts.Threshold) {
+		b.openedAt = time.Now()
+		b.transition(BreakerOpen)
+		return
+	}
+	b.mu.Unlock()
+}
+
+// Abort 请求被调用方取消，不计入成功或失败
+func (b *Breaker) Abort() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// Reset 手动恢复为 closed，例如测试连接成功后
+func (b *Breaker) Reset() {
+	b.mu.Lock()
+	b.failures = 0
+	b.probing = false
+	b.lastError = ""
+	if b.state != BreakerClosed {
+		b.transition(BreakerClosed)
+		return
+	}
+	b.mu.Unlock()
+}
+
+func (b *Breaker) Snapshot() BreakerSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.snapshot()
+}
+
+func (b *Breaker) snapshot() BreakerSnapshot {
+	snap := BreakerSnapshot{State: b.state, Failures: b.failures, LastError: b.lastError}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		snap.OpenedAt = &openedAt
+	}
+	return snap
+}
+
+// transition 切换状态并释放锁，随后通知回调
+func (b *Breaker) transition(to BreakerState) {
+	from := b.state
+	b.state = to
+	snap := b.snapshot()
+	b.mu.Unlock()
+	if from != to && b.opts.OnStateChange != nil {
+		b.opts.OnStateChange(from, to, snap)
+	}
+}
+
+// NewBreakerTransport 在 base 之上套用熔断器，网络错误和 502/503/504 计为失败
+func NewBreakerTransport(base http.RoundTripper, breaker *Breaker) http.RoundTripper {
+	return &breakerTransport{base: base, breaker: breaker}
+}
+
+type breakerTransport struct {
+	base    http.RoundTripper
+	breaker *Breaker
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil && (errors.Is(err, ErrQueueTimeout) || errors.Is(req.Context().Err(), context.Canceled)):
+		// 排队超时或调用方主动取消，与节点健康无关
+		t.breaker.Abort()
+	case err != nil:
+		t.breaker.Failure(err)
+	case isGatewayStatus(resp.StatusCode):
+		t.breaker.Failure(fmt.Errorf("HTTP %d", resp.StatusCode))
+	default:
+		t.breaker.Success()
+	}
+	return resp, err
+}
+
+func isGatewayStatus(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
@@ -0,0 +1,148 @@
+package lxdapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const CodeSuccess = 200
+
+// Client 单个 lxdapi 节点的客户端
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+type envelope struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Result 操作类接口的响应，Data 为节点返回的原始 data，由调用方原样透传
+type Result struct {
+	Msg  string
+	Data json.RawMessage
+}
+
+func NewClient(baseURL, apiKey string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: httpClient,
+	}
+}
+
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	fullPath := path
+	if len(query) > 0 {
+		fullPath += "?" + query.Encode()
+	}
+
+	// url.Values 按表单提交，兼容只接收表单参数的接口，其余按 JSON 提交
+	var reader io.Reader
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case url.Values:
+		reader = strings.NewReader(b.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	default:
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, &TransportError{Method: method, Path: path, Err: fmt.Errorf("请求编码失败: %w", err)}
+		}
+		reader = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+fullPath, reader)
+	if err != nil {
+		return nil, &TransportError{Method: method, Path: path, Err: fmt.Errorf("请求创建失败: %w", err)}
+	}
+	if c.apiKey != "" {
+		req.Header.Set("apikey", c.apiKey)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &TransportError{Method: method, Path: path, Err: err}
+	}
+	return resp, nil
+}
+
+// call 发送请求并解析 {code, msg, data} 响应，data 解码到 out（可为 nil）
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, body, out interface{}) (string, error) {
+	resp, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return "", &TransportError{Method: method, Path: path, Err: fmt.Errorf("响应解析失败 (HTTP %d): %w", resp.StatusCode, err)}
+	}
+	if env.Code != CodeSuccess {
+		return env.Msg, &APIError{Path: path, Code: env.Code, Msg: env.Msg, HTTPStatus: resp.StatusCode}
+	}
+	if out != nil && len(env.Data) > 0 && string(env.Data) != "null" {
+		if err := json.Unmarshal(env.Data, out); err != nil {
+			return env.Msg, &TransportError{Method: method, Path: path, Err: fmt.Errorf("响应数据格式错误: %w", err)}
+		}
+	}
+	return env.Msg, nil
+}
+
+// callResult 用于操作类接口，返回节点的提示信息和原始 data
+func (c *Client) callResult(ctx context.Context, method, path string, query url.Values, body interface{}) (*Result, error) {
+	var data json.RawMessage
+	msg, err := c.call(ctx, method, path, query, body, &data)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Msg: msg, Data: data}, nil
+}
+
+// get 用于只读查询，失败时可由传输层重试
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) (string, error) {
+	return c.call(idempotent(ctx), http.MethodGet, path, query, nil, out)
+}
+
+// callRaw 用于不带 {code, msg, data} 包装的只读接口，HTTP 状态码非 200 视为 APIError
+func (c *Client) callRaw(ctx context.Context, method, path string, out interface{}) error {
+	resp, err := c.send(idempotent(ctx), method, path, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &APIError{Path: path, Code: resp.StatusCode, Msg: fmt.Sprintf("HTTP %d", resp.StatusCode), HTTPStatus: resp.StatusCode}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return &TransportError{Method: method, Path: path, Err: fmt.Errorf("响应解析失败: %w", err)}
+	}
+	return nil
+}
+
+func hostnameQuery(hostname string) url.Values {
+	return url.Values{"hostname": {hostname}}
+}
@@ -0,0 +1,70 @@
+package lxdapi
+
+import (
+	"context"
+	"net/http"
+)
+
+func (c *Client) Info(ctx context.Context, hostname string) (*ContainerInfo, error) {
+	var info ContainerInfo
+	if _, err := c.get(ctx, "/api/info", hostnameQuery(hostname), &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (c *Client) List(ctx context.Context) ([]ContainerInfo, error) {
+	var list []ContainerInfo
+	if _, err := c.get(ctx, "/api/list", nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *Client) Boot(ctx context.Context, hostname string) (*Result, error) {
+	return c.callResult(ctx, http.MethodGet, "/api/boot", hostnameQuery(hostname), nil)
+}
+
+func (c *Client) Stop(ctx context.Context, hostname string) (*Result, error) {
+	return c.callResult(ctx, http.MethodGet, "/api/stop", hostnameQuery(hostname), nil)
+}
+
+func (c *Client) Reboot(ctx context.Context, hostname string) (*Result, error) {
+	return c.callResult(ctx, http.MethodGet, "/api/reboot", hostnameQuery(hostname), nil)
+}
+
+func (c *Client) Delete(ctx context.Context, hostname string) (*Result, error) {
+	return c.callResult(ctx, http.MethodGet, "/api/delete", hostnameQuery(hostname), nil)
+}
+
+func (c *Client) Suspend(ctx context.Context, hostname string) (*Result, error) {
+	return c.callResult(ctx, http.MethodGet, "/api/suspend", hostnameQuery(hostname), nil)
+}
+
+func (c *Client) Unsuspend(ctx context.Context, hostname string) (*Result, error) {
+	return c.callResult(ctx, http.MethodGet, "/api/unsuspend", hostnameQuery(hostname), nil)
+}
+
+func (c *Client) Create(ctx context.Context, req *CreateRequest) (*Result, error) {
+	return c.callResult(ctx, http.MethodPost, "/api/create", nil, req)
+}
+
+func (c *Client) Reinstall(ctx context.Context, req *ReinstallRequest) (*Result, error) {
+	return c.callResult(ctx, http.MethodPost, "/api/reinstall", nil, req)
+}
+
+func (c *Client) SetPassword(ctx context.Context, req *PasswordRequest) (*Result, error) {
+	return c.callResult(ctx, http.MethodPost, "/api/password", nil, req)
+}
+
+func (c *Client) ResetTraffic(ctx context.Context, hostname string) (*Result, error) {
+	return c.callResult(ctx, http.MethodPost, "/api/traffic/reset", hostnameQuery(hostname), nil)
+}
+
+func (c *Client) CreateConsoleToken(ctx context.Context, req *ConsoleTokenRequest) (*ConsoleToken, error) {
+	var token ConsoleToken
+	if _, err := c.call(ctx, http.MethodPost, "/api/console/create-token", nil, req, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
@@ -0,0 +1,49 @@
+package lxdapi
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TransportError 表示请求未能得到有效响应（网络、TLS、超时或响应无法解析）
+type TransportError struct {
+	Method string
+	Path   string
+	Err    error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Method, e.Path, e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// APIError 表示节点已响应，但返回了非 200 的业务状态码
+type APIError struct {
+	Path       string
+	Code       int
+	Msg        string
+	HTTPStatus int
+}
+
+func (e *APIError) Error() string {
+	if e.Msg != "" {
+		return fmt.Sprintf("%s: %s (code: %d)", e.Path, e.Msg, e.Code)
+	}
+	return fmt.Sprintf("%s: code %d", e.Path, e.Code)
+}
+
+func AsAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+func IsTransportError(err error) bool {
+	var tErr *TransportError
+	return errors.As(err, &tErr)
+}
@@ -0,0 +1,68 @@
+package lxdapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// IPv6Binding /api/ipv6/list 返回的独立 IPv6 绑定
+type IPv6Binding struct {
+	Hostname    string `json:"hostname"`
+	PublicIPv6  string `json:"public_ipv6"`
+	Status      string `json:"status"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// ipv6List 兼容 data 直接为数组和 data.list 两种返回格式
+type ipv6List []IPv6Binding
+
+func (l *ipv6List) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, (*[]IPv6Binding)(l))
+	}
+	var wrapped struct {
+		List []IPv6Binding `json:"list"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return err
+	}
+	*l = wrapped.List
+	return nil
+}
+
+// IPv6List 读取容器绑定的独立 IPv6 地址
+func (c *Client) IPv6List(ctx context.Context, hostname string) ([]IPv6Binding, error) {
+	var list ipv6List
+	if _, err := c.get(ctx, "/api/ipv6/list", hostnameQuery(hostname), &list); err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].Hostname == "" {
+			list[i].Hostname = hostname
+		}
+	}
+	return list, nil
+}
+
+// AddIPv6 从节点地址池为容器分配并绑定一个独立 IPv6 地址
+func (c *Client) AddIPv6(ctx context.Context, hostname, description string) (*Result, error) {
+	form := url.Values{"hostname": {hostname}}
+	if description != "" {
+		form.Set("description", description)
+	}
+	return c.callResult(ctx, http.MethodPost, "/api/ipv6/add", nil, form)
+}
+
+// DeleteIPv6 解除容器的独立 IPv6 绑定
+func (c *Client) DeleteIPv6(ctx context.Context, hostname, publicIPv6 string) (*Result, error) {
+	form := url.Values{
+		"hostname":    {hostname},
+		"public_ipv6": {publicIPv6},
+	}
+	return c.callResult(ctx, http.MethodPost, "/api/ipv6/delete", nil, form)
+}
@@ -0,0 +1,211 @@
+package lxdapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Class 请求类别，同一节点的并发额度按类别公平分配
+type Class int
+
+const (
+	ClassInteractive Class = iota // 用户在页面上触发的操作
+	ClassCache                    // 节点信息缓存刷新
+	ClassSync                     // 容器同步
+	numClasses
+)
+
+// ErrQueueTimeout 等待节点并发额度时上下文结束
+var ErrQueueTimeout = errors.New("等待节点请求额度超时")
+
+type classKey struct{}
+
+// WithClass 为请求上下文标记类别，未标记的请求视为交互请求
+func WithClass(ctx context.Context, class Class) context.Context {
+	return context.WithValue(ctx, classKey{}, class)
+}
+
+// ClassFrom 返回上下文中的请求类别
+func ClassFrom(ctx context.Context) Class {
+	if class, ok := ctx.Value(classKey{}).(Class); ok && class >= 0 && class < numClasses {
+		return class
+	}
+	return ClassInteractive
+}
+
+// LimiterStats 并发额度的使用情况
+type LimiterStats struct {
+	Max      int `json:"max"`
+	Reserved int `json:"reserved"`
+	Active   int `json:"active"`
+	Waiting  int `json:"waiting"`
+}
+
+// Limiter 限制单个节点的在途请求数。
+// 后台请求（缓存刷新、同步）最多占用 max-reserved 个额度，剩余额度只留给交互请求；
+// 后台类别之间轮流放行，避免大批量同步饿死缓存刷新。
+type Limiter struct {
+	mu         sync.Mutex
+	max        int
+	reserved   int
+	active     int
+	background int
+	waiters    [numClasses][]chan struct{}
+	next       Class
+}
+
+func NewLimiter(max, reserved int) *Limiter {
+	if max < 1 {
+		max = 1
+	}
+	if reserved >= max {
+		reserved = max - 1
+	}
+	if reserved < 0 {
+		reserved = 0
+	}
+	return &Limiter{max: max, reserved: reserved, next: ClassCache}
+}
+
+// Acquire 等待一个额度，ctx 结束时放弃等待
+func (l *Limiter) Acquire(ctx context.Context, class Class) error {
+	l.mu.Lock()
+	if len(l.waiters[class]) == 0 && l.canRun(class) {
+		l.grant(class)
+		l.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	l.waiters[class] = append(l.waiters[class], ch)
+	l.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		if !l.removeWaiter(class, ch) {
+			// 取消的同时已被放行，归还额度
+			l.mu.Unlock()
+			l.Release(class)
+			return ctx.Err()
+		}
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Release 归还额度并唤醒等待者
+func (l *Limiter) Release(class Class) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	if class != ClassInteractive {
+		l.background--
+	}
+	l.dispatch()
+}
+
+func (l *Limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	waiting := 0
+	for _, q := range l.waiters {
+		waiting += len(q)
+	}
+	return LimiterStats{Max: l.max, Reserved: l.reserved, Active: l.active, Waiting: waiting}
+}
+
+func (l *Limiter) canRun(class Class) bool {
+	if l.active >= l.max {
+		return false
+	}
+	return class == ClassInteractive || l.background < l.max-l.reserved
+}
+
+func (l *Limiter) grant(class Class) {
+	l.active++
+	if class != ClassInteractive {
+		l.background++
+	}
+}
+
+func (l *Limiter) dispatch() {
+	for {
+		if len(l.waiters[ClassInteractive]) > 0 && l.canRun(ClassInteractive) {
+			l.wake(ClassInteractive)
+			continue
+		}
+		woke := false
+		for i := Class(0); i < numClasses-1; i++ {
+			class := ClassCache + (l.next-ClassCache+i)%(numClasses-1)
+			if len(l.waiters[class]) > 0 && l.canRun(class) {
+				l.wake(class)
+				l.next = ClassCache + (class-ClassCache+1)%(numClasses-1)
+				woke = true
+				break
+			}
+		}
+		if !woke {
+			return
+		}
+	}
+}
+
+func (l *Limiter) wake(class Class) {
+	ch := l.waiters[class][0]
+	l.waiters[class] = l.waiters[class][1:]
+	l.grant(class)
+	close(ch)
+}
+
+func (l *Limiter) removeWaiter(class Class, ch chan struct{}) bool {
+	q := l.waiters[class]
+	for i, w := range q {
+		if w == ch {
+			l.waiters[class] = append(q[:i], q[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// NewLimitedTransport 在 base 之上按 limiter 控制并发，额度在响应体关闭时归还
+func NewLimitedTransport(base http.RoundTripper, limiter *Limiter) http.RoundTripper {
+	return &limitedTransport{base: base, limiter: limiter}
+}
+
+type limitedTransport struct {
+	base    http.RoundTripper
+	limiter *Limiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	class := ClassFrom(req.Context())
+	if err := t.limiter.Acquire(req.Context(), class); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQueueTimeout, err)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.limiter.Release(class)
+		return nil, err
+	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: func() { t.limiter.Release(class) }}
+	return resp, nil
+}
+
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,170 @@
+package lxdapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// NATRule /api/natlist 返回的端口转发规则，端口段的 End 字段大于 0
+type NATRule struct {
+	Protocol        string `json:"protocol"`
+	ExternalPort    int    `json:"external_port"`
+	ExternalPortEnd int    `json:"external_port_end"`
+	InternalPort    int    `json:"internal_port"`
+	InternalPortEnd int    `json:"internal_port_end"`
+	Status          string `json:"status"`
+	Description     string `json:"description"`
+}
+
+// UnmarshalJSON 兼容 external_port/dport 两种字段命名，端口可能以字符串返回
+func (r *NATRule) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Protocol        string   `json:"protocol"`
+		DType           string   `json:"dtype"`
+		ExternalPort    *flexInt `json:"external_port"`
+		DPort           *flexInt `json:"dport"`
+		ExternalPortEnd *flexInt `json:"external_port_end"`
+		DPortEnd        *flexInt `json:"dport_end"`
+		InternalPort    *flexInt `json:"internal_port"`
+		SPort           *flexInt `json:"sport"`
+		InternalPortEnd *flexInt `json:"internal_port_end"`
+		SPortEnd        *flexInt `json:"sport_end"`
+		Status          string   `json:"status"`
+		Description     string   `json:"description"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	pick := func(values ...*flexInt) int {
+		for _, v := range values {
+			if v != nil {
+				return int(*v)
+			}
+		}
+		return 0
+	}
+	protocol := raw.Protocol
+	if protocol == "" {
+		protocol = raw.DType
+	}
+
+	*r = NATRule{
+		Protocol:        strings.ToLower(strings.TrimSpace(protocol)),
+		ExternalPort:    pick(raw.ExternalPort, raw.DPort),
+		ExternalPortEnd: pick(raw.ExternalPortEnd, raw.DPortEnd),
+		InternalPort:    pick(raw.InternalPort, raw.SPort),
+		InternalPortEnd: pick(raw.InternalPortEnd, raw.SPortEnd),
+		Status:          raw.Status,
+		Description:     raw.Description,
+	}
+	return nil
+}
+
+// Overlaps 判断规则的外部端口是否与指定协议和端口段重叠，end 为 0 表示单个端口
+func (r NATRule) Overlaps(protocol string, start, end int) bool {
+	if r.Protocol != protocol && r.Protocol != "both" && protocol != "both" {
+		return false
+	}
+	if end == 0 {
+		end = start
+	}
+	ruleEnd := r.ExternalPortEnd
+	if ruleEnd == 0 {
+		ruleEnd = r.ExternalPort
+	}
+	return r.ExternalPort <= end && start <= ruleEnd
+}
+
+// NATRequest /api/addport 与 /api/delport 的参数，Protocol 为 tcp、udp 或 both（仅添加时支持）
+type NATRequest struct {
+	Hostname        string
+	Protocol        string
+	ExternalPort    int
+	ExternalPortEnd int
+	InternalPort    int
+	InternalPortEnd int
+	Description     string
+}
+
+// form 转换为表单参数，ExternalPort 为 0 时由节点自动分配外部端口
+func (r *NATRequest) form() url.Values {
+	form := url.Values{
+		"hostname": {r.Hostname},
+		"dtype":    {r.Protocol},
+		"sport":    {strconv.Itoa(r.InternalPort)},
+	}
+	if r.ExternalPort > 0 {
+		form.Set("dport", strconv.Itoa(r.ExternalPort))
+	}
+	if r.ExternalPortEnd > 0 && r.InternalPortEnd > 0 {
+		form.Set("dport_end", strconv.Itoa(r.ExternalPortEnd))
+		form.Set("sport_end", strconv.Itoa(r.InternalPortEnd))
+	}
+	if r.Description != "" {
+		form.Set("description", r.Description)
+	}
+	return form
+}
+
+// NATCheckResult /api/nat/check 返回的端口可用性
+type NATCheckResult struct {
+	Available bool   `json:"available"`
+	Reason    string `json:"reason"`
+}
+
+// NATList 读取容器的端口转发规则
+func (c *Client) NATList(ctx context.Context, hostname string) ([]NATRule, error) {
+	var rules []NATRule
+	if _, err := c.get(ctx, "/api/natlist", hostnameQuery(hostname), &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// AddNAT 添加端口转发规则，与 ZJMF 插件一样以表单提交
+func (c *Client) AddNAT(ctx context.Context, req *NATRequest) (*Result, error) {
+	return c.callResult(ctx, http.MethodPost, "/api/addport", nil, req.form())
+}
+
+// DeleteNAT 删除端口转发规则，Protocol 只能是 tcp 或 udp
+func (c *Client) DeleteNAT(ctx context.Context, req *NATRequest) (*Result, error) {
+	form := req.form()
+	form.Del("description")
+	return c.callResult(ctx, http.MethodPost, "/api/delport", nil, form)
+}
+
+// CheckNATPort 检查外部端口在节点上是否可用
+func (c *Client) CheckNATPort(ctx context.Context, hostname, protocol string, port int) (*NATCheckResult, error) {
+	query := url.Values{
+		"hostname": {hostname},
+		"protocol": {protocol},
+		"port":     {strconv.Itoa(port)},
+	}
+	var result NATCheckResult
+	if _, err := c.get(ctx, "/api/nat/check", query, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// flexInt 同时接受数字和数字字符串
+type flexInt int
+
+func (v *flexInt) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*v = 0
+		return nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*v = flexInt(n)
+	return nil
+}
@@ -0,0 +1,33 @@
+package lxdapi
+
+import (
+	"context"
+	"net/http"
+)
+
+// Check 调用 /api/check 检测节点连通性与 API Key
+func (c *Client) Check(ctx context.Context) error {
+	return c.callRaw(ctx, http.MethodGet, "/api/check", nil)
+}
+
+func (c *Client) SystemInfo(ctx context.Context) (SystemInfo, error) {
+	var info SystemInfo
+	if err := c.callRaw(ctx, http.MethodGet, "/", &info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// CachedContainers 读取 lxdapi 端的容器缓存
+func (c *Client) CachedContainers(ctx context.Context) ([]ContainerInfo, error) {
+	var list []ContainerInfo
+	if _, err := c.get(ctx, "/api/cache/containers", nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// RefreshCache 触发 lxdapi 端重建容器缓存
+func (c *Client) RefreshCache(ctx context.Context) (*Result, error) {
+	return c.callResult(ctx, http.MethodGet, "/api/cache/containers/refresh", nil, nil)
+}
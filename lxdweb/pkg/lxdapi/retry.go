@@ -0,0 +1,92 @@
+package lxdapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy 只读请求的重试策略，Backoff 每次翻倍，不超过 MaxBackoff
+type RetryPolicy struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+type idempotentKey struct{}
+
+// idempotent 标记请求可安全重试。lxdapi 的开关机、删除等操作也使用 GET，因此不能按方法判断
+func idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(req *http.Request) bool {
+	marked, _ := req.Context().Value(idempotentKey{}).(bool)
+	return marked && req.Method == http.MethodGet && (req.Body == nil || req.Body == http.NoBody)
+}
+
+// NewRetryTransport 对标记为只读的请求在网络错误或 502/503/504 时按指数退避重试
+func NewRetryTransport(base http.RoundTripper, policy RetryPolicy) http.RoundTripper {
+	if policy.Attempts < 1 {
+		policy.Attempts = 1
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = 500 * time.Millisecond
+	}
+	if policy.MaxBackoff < policy.Backoff {
+		policy.MaxBackoff = 8 * policy.Backoff
+	}
+	return &retryTransport{base: base, policy: policy}
+}
+
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isIdempotent(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	backoff := t.policy.Backoff
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.policy.Attempts || !shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		// 加入 ±20% 抖动，避免多个请求同时重试
+		wait := backoff + time.Duration((rand.Float64()*0.4-0.2)*float64(backoff))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+		if backoff > t.policy.MaxBackoff {
+			backoff = t.policy.MaxBackoff
+		}
+	}
+}
+
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		if _, ok := AsFingerprintMismatch(err); ok {
+			return false
+		}
+		return !errors.Is(err, ErrCircuitOpen) && !errors.Is(err, ErrQueueTimeout)
+	}
+	return isGatewayStatus(resp.StatusCode)
+}
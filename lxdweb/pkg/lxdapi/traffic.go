@@ -0,0 +1,64 @@
+package lxdapi
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// TrafficStats /api/traffic 返回的流量计数（字节），计数器在 /api/traffic/reset 后归零
+type TrafficStats struct {
+	RxBytes    uint64
+	TxBytes    uint64
+	TotalBytes uint64
+	// Directional 为 false 表示节点只返回了总量，没有分方向的计数
+	Directional bool
+}
+
+// UnmarshalJSON 兼容不同版本 lxdapi 的字段命名，总量缺失时由 TotalGB 或 rx+tx 推算
+func (s *TrafficStats) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		RxBytes    *float64 `json:"RxBytes"`
+		TxBytes    *float64 `json:"TxBytes"`
+		TotalBytes *float64 `json:"TotalBytes"`
+		TotalGB    *float64 `json:"TotalGB"`
+		RxSnake    *float64 `json:"rx_bytes"`
+		TxSnake    *float64 `json:"tx_bytes"`
+		TotalSnake *float64 `json:"total_bytes"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	pick := func(values ...*float64) (uint64, bool) {
+		for _, v := range values {
+			if v != nil && *v >= 0 {
+				return uint64(*v), true
+			}
+		}
+		return 0, false
+	}
+
+	rx, hasRx := pick(raw.RxBytes, raw.RxSnake)
+	tx, hasTx := pick(raw.TxBytes, raw.TxSnake)
+	total, hasTotal := pick(raw.TotalBytes, raw.TotalSnake)
+
+	*s = TrafficStats{RxBytes: rx, TxBytes: tx, Directional: hasRx && hasTx}
+	switch {
+	case hasTotal:
+		s.TotalBytes = total
+	case s.Directional:
+		s.TotalBytes = rx + tx
+	case raw.TotalGB != nil && *raw.TotalGB >= 0:
+		s.TotalBytes = uint64(*raw.TotalGB * 1024 * 1024 * 1024)
+	}
+	return nil
+}
+
+// Traffic 读取容器当前的流量计数
+func (c *Client) Traffic(ctx context.Context, hostname string) (*TrafficStats, error) {
+	var stats TrafficStats
+	if _, err := c.get(ctx, "/api/traffic", hostnameQuery(hostname), &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
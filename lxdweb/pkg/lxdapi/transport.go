@@ -0,0 +1,36 @@
+package lxdapi
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// TransportOptions 节点长连接池参数
+type TransportOptions struct {
+	MaxConns        int
+	IdleConnTimeout time.Duration
+}
+
+// NewTransport 创建复用 keep-alive 连接的 Transport，同一节点的请求共用 TLS 会话
+func NewTransport(trust TrustConfig, opts TransportOptions) *http.Transport {
+	if opts.MaxConns < 1 {
+		opts.MaxConns = 1
+	}
+	if opts.IdleConnTimeout <= 0 {
+		opts.IdleConnTimeout = 90 * time.Second
+	}
+	return &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       trust.TLSConfig(),
+		TLSHandshakeTimeout:   10 * time.Second,
+		MaxIdleConns:          opts.MaxConns,
+		MaxIdleConnsPerHost:   opts.MaxConns,
+		MaxConnsPerHost:       opts.MaxConns,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
@@ -0,0 +1,160 @@
+package lxdapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// 节点证书信任模式
+const (
+	TrustPin    = "pin"
+	TrustCA     = "ca"
+	TrustSystem = "system"
+)
+
+var ErrFingerprintNotPinned = errors.New("节点证书指纹尚未确认，请先测试连接")
+
+// FingerprintMismatchError 节点证书与已固定的指纹不一致
+type FingerprintMismatchError struct {
+	Expected string
+	Actual   string
+}
+
+func (e *FingerprintMismatchError) Error() string {
+	return fmt.Sprintf("节点证书指纹不匹配: 期望 %s, 实际 %s", e.Expected, e.Actual)
+}
+
+// TrustConfig 单个节点的 TLS 信任配置
+type TrustConfig struct {
+	Mode        string
+	Fingerprint string
+	CACertPEM   string
+}
+
+func ValidTrustMode(mode string) bool {
+	switch mode {
+	case TrustPin, TrustCA, TrustSystem:
+		return true
+	}
+	return false
+}
+
+// Fingerprint 返回证书 DER 的 SHA-256 小写十六进制
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeFingerprint 接受带冒号或空格、大小写混合的指纹并统一格式
+func NormalizeFingerprint(fp string) (string, error) {
+	fp = strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(strings.TrimSpace(fp)))
+	if fp == "" {
+		return "", nil
+	}
+	if len(fp) != sha256.Size*2 {
+		return "", fmt.Errorf("指纹长度应为 %d 个十六进制字符", sha256.Size*2)
+	}
+	if _, err := hex.DecodeString(fp); err != nil {
+		return "", fmt.Errorf("指纹不是有效的十六进制: %w", err)
+	}
+	return fp, nil
+}
+
+func ParseCABundle(pemData string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(pemData)) {
+		return nil, errors.New("CA 证书无效或为空")
+	}
+	return pool, nil
+}
+
+// TLSConfig 根据信任模式构建 tls.Config，配置错误会在握手时返回
+func (t TrustConfig) TLSConfig() *tls.Config {
+	switch t.Mode {
+	case TrustSystem:
+		return &tls.Config{MinVersion: tls.VersionTLS12}
+	case TrustCA:
+		pool, err := ParseCABundle(t.CACertPEM)
+		if err != nil {
+			return failingTLSConfig(err)
+		}
+		return &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool}
+	default:
+		expected, err := NormalizeFingerprint(t.Fingerprint)
+		if err != nil {
+			return failingTLSConfig(err)
+		}
+		if expected == "" {
+			return failingTLSConfig(ErrFingerprintNotPinned)
+		}
+		return &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			// 证书链校验由下面的指纹比对代替
+			InsecureSkipVerify: true,
+			VerifyConnection: func(cs tls.ConnectionState) error {
+				if len(cs.PeerCertificates) == 0 {
+					return errors.New("节点未提供证书")
+				}
+				actual := Fingerprint(cs.PeerCertificates[0])
+				if actual != expected {
+					return &FingerprintMismatchError{Expected: expected, Actual: actual}
+				}
+				return nil
+			},
+		}
+	}
+}
+
+func failingTLSConfig(err error) *tls.Config {
+	return &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyConnection: func(tls.ConnectionState) error {
+			return err
+		},
+	}
+}
+
+// FetchFingerprint 连接节点并返回其叶子证书指纹，仅用于首次信任（TOFU）
+func FetchFingerprint(ctx context.Context, address string) (string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", fmt.Errorf("节点地址无效: %w", err)
+	}
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("节点地址不是 https: %s", address)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	dialer := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", errors.New("节点未提供证书")
+	}
+	return Fingerprint(certs[0]), nil
+}
+
+// AsFingerprintMismatch 判断错误是否由证书指纹变化引起
+func AsFingerprintMismatch(err error) (*FingerprintMismatchError, bool) {
+	var mErr *FingerprintMismatchError
+	if errors.As(err, &mErr) {
+		return mErr, true
+	}
+	return nil, false
+}
@@ -0,0 +1,109 @@
+package lxdapi
+
+// ContainerConfig /api/info 返回的容器配置
+type ContainerConfig struct {
+	Memory       string `json:"memory,omitempty"`
+	Disk         string `json:"disk,omitempty"`
+	TrafficLimit int    `json:"traffic_limit,omitempty"`
+	Ingress      string `json:"ingress,omitempty"`
+	Egress       string `json:"egress,omitempty"`
+}
+
+// ContainerInfo /api/info、/api/list 与 /api/cache/containers 返回的容器信息
+type ContainerInfo struct {
+	Hostname string          `json:"hostname"`
+	Status   string          `json:"status"`
+	IPv4     string          `json:"ipv4"`
+	IPv6     string          `json:"ipv6"`
+	Image    string          `json:"image"`
+	CPUs     int             `json:"cpus"`
+	Memory   float64         `json:"memory"`
+	Disk     float64         `json:"disk"`
+	Config   ContainerConfig `json:"config"`
+
+	CPUPercent *float64 `json:"cpu_percent,omitempty"`
+	CPUUsage   *float64 `json:"cpu_usage,omitempty"`
+
+	MemoryUsage    string  `json:"memory_usage,omitempty"`
+	MemoryUsageRaw float64 `json:"memory_usage_raw"`
+	MemoryPercent  float64 `json:"memory_percent,omitempty"`
+	DiskUsage      string  `json:"disk_usage,omitempty"`
+	DiskUsageRaw   float64 `json:"disk_usage_raw"`
+	DiskPercent    float64 `json:"disk_percent,omitempty"`
+
+	TrafficUsage    string  `json:"traffic_usage,omitempty"`
+	TrafficUsageRaw float64 `json:"traffic_usage_raw"`
+}
+
+// CPU 优先返回 cpu_percent，缺失时回退到 cpu_usage
+func (i *ContainerInfo) CPU() (float64, bool) {
+	if i.CPUPercent != nil {
+		return *i.CPUPercent, true
+	}
+	if i.CPUUsage != nil {
+		return *i.CPUUsage, true
+	}
+	return 0, false
+}
+
+// CreateRequest /api/create 请求参数
+type CreateRequest struct {
+	Hostname     string `json:"hostname"`
+	Password     string `json:"password"`
+	Image        string `json:"image"`
+	CPUs         int    `json:"cpus"`
+	Memory       string `json:"memory"`
+	Disk         string `json:"disk"`
+	Ingress      string `json:"ingress"`
+	Egress       string `json:"egress"`
+	TrafficLimit int    `json:"traffic_limit"`
+	AllowNesting bool   `json:"allow_nesting"`
+	MemorySwap   bool   `json:"memory_swap"`
+	MaxProcesses int    `json:"max_processes"`
+	CPUAllowance string `json:"cpu_allowance"`
+	DiskIOLimit  string `json:"disk_io_limit,omitempty"`
+	Privileged   bool   `json:"privileged"`
+}
+
+// ReinstallRequest /api/reinstall 请求参数
+type ReinstallRequest struct {
+	Hostname     string `json:"hostname"`
+	System       string `json:"system"`
+	Password     string `json:"password"`
+	CPUs         int    `json:"cpus"`
+	Memory       string `json:"memory"`
+	Disk         string `json:"disk"`
+	Ingress      string `json:"ingress"`
+	Egress       string `json:"egress"`
+	TrafficLimit int    `json:"traffic_limit"`
+	AllowNesting bool   `json:"allow_nesting"`
+	MemorySwap   bool   `json:"memory_swap"`
+	MaxProcesses int    `json:"max_processes"`
+	CPUAllowance string `json:"cpu_allowance"`
+	DiskIOLimit  string `json:"disk_io_limit,omitempty"`
+	Privileged   bool   `json:"privileged"`
+	EnableLXCFS  bool   `json:"enable_lxcfs"`
+}
+
+// PasswordRequest /api/password 请求参数
+type PasswordRequest struct {
+	Hostname string `json:"hostname"`
+	Password string `json:"password"`
+}
+
+// ConsoleTokenRequest /api/console/create-token 请求参数
+type ConsoleTokenRequest struct {
+	Hostname  string `json:"hostname"`
+	UserID    int    `json:"user_id"`
+	ServiceID int    `json:"service_id"`
+	ServerIP  string `json:"server_ip"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// ConsoleToken /api/console/create-token 返回数据
+type ConsoleToken struct {
+	Token string `json:"token"`
+}
+
+// SystemInfo 节点根路径 / 返回的系统信息，原样缓存到 NodeInfoCache
+type SystemInfo map[string]interface{}
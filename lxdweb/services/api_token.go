@@ -0,0 +1,67 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"lxdweb/database"
+	"lxdweb/models"
+)
+
+// apiTokenPrefix 令牌明文前缀，便于在日志和密钥扫描中识别
+const apiTokenPrefix = "lxdw_"
+
+// tokenTouchInterval 最近使用时间的最小更新间隔，避免每个请求都写库
+const tokenTouchInterval = time.Minute
+
+var (
+	ErrTokenInvalid = errors.New("API令牌无效")
+	ErrTokenExpired = errors.New("API令牌已过期")
+)
+
+// GenerateAPIToken 生成新的令牌明文，返回明文、显示前缀和摘要
+func GenerateAPIToken() (string, string, string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	raw := apiTokenPrefix + hex.EncodeToString(buf)
+	return raw, raw[:len(apiTokenPrefix)+8], HashAPIToken(raw), nil
+}
+
+// HashAPIToken 计算令牌摘要
+func HashAPIToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// LookupAPIToken 校验令牌明文，返回未过期的令牌记录
+func LookupAPIToken(raw string) (*models.APIToken, error) {
+	if !strings.HasPrefix(raw, apiTokenPrefix) {
+		return nil, ErrTokenInvalid
+	}
+	var token models.APIToken
+	if err := database.DB.Where("token_hash = ?", HashAPIToken(raw)).First(&token).Error; err != nil {
+		return nil, ErrTokenInvalid
+	}
+	if token.Expired(time.Now()) {
+		return nil, ErrTokenExpired
+	}
+	return &token, nil
+}
+
+// TouchAPIToken 记录令牌最近使用时间和来源 IP
+func TouchAPIToken(token *models.APIToken, ip string) {
+	now := time.Now()
+	if token.LastUsedAt != nil && now.Sub(*token.LastUsedAt) < tokenTouchInterval && token.LastUsedIP == ip {
+		return
+	}
+	database.DB.Model(token).Updates(map[string]interface{}{
+		"last_used_at": now,
+		"last_used_ip": ip,
+	})
+}
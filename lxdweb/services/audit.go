@@ -0,0 +1,84 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"strings"
+
+	"lxdweb/database"
+	"lxdweb/models"
+)
+
+// maxAuditDetails 详情字段最大长度，超出部分截断
+const maxAuditDetails = 8192
+
+// sensitiveKeys 字段名包含这些片段时记录为 ***
+var sensitiveKeys = []string{"password", "passwd", "secret", "token", "apikey", "api_key", "passphrase", "captcha", "private", "recovery", "totp", "otp", "code"}
+
+// RecordAudit 写入一条操作审计记录，失败只记日志不影响请求
+func RecordAudit(entry *models.OperationLog) {
+	if len(entry.Details) > maxAuditDetails {
+		entry.Details = entry.Details[:maxAuditDetails] + "...(已截断)"
+	}
+	if err := database.DB.Create(entry).Error; err != nil {
+		log.Printf("[AUDIT] 写入审计记录失败 %s: %v", entry.OperationType, err)
+	}
+}
+
+// IsSensitiveKey 判断字段是否需要脱敏
+func IsSensitiveKey(key string) bool {
+	k := strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactJSON 解析 JSON 并递归替换敏感字段，无法解析时返回 nil
+func RedactJSON(data []byte) interface{} {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil
+	}
+	return redactValue(v)
+}
+
+// RedactValues 脱敏表单或查询参数
+func RedactValues(values url.Values) map[string]interface{} {
+	result := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		switch {
+		case IsSensitiveKey(k):
+			result[k] = "***"
+		case len(v) == 1:
+			result[k] = v[0]
+		default:
+			result[k] = v
+		}
+	}
+	return result
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if IsSensitiveKey(k) {
+				val[k] = "***"
+			} else {
+				val[k] = redactValue(item)
+			}
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactValue(item)
+		}
+		return val
+	default:
+		return v
+	}
+}
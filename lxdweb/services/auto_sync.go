@@ -39,7 +39,7 @@ func autoSyncLoop() {
 
 func checkAndSyncNodes() {
 	var nodes []models.Node
-	if err := database.DB.Where("status = ? AND auto_sync = ?", "active", true).Find(&nodes).Error; err != nil {
+	if err := database.DB.Where("status IN ? AND auto_sync = ?", []string{"active", "degraded"}, true).Find(&nodes).Error; err != nil {
 		log.Printf("[AUTO-SYNC] 查询节点失败: %v", err)
 		return
 	}
@@ -104,7 +104,7 @@ func SyncAllNodesFullAsync() {
 	log.Println("[AUTO-SYNC] 开始执行完整实时同步任务")
 
 	var nodes []models.Node
-	if err := database.DB.Where("status = ?", "active").Find(&nodes).Error; err != nil {
+	if err := database.DB.Where("status IN ?", []string{"active", "degraded"}).Find(&nodes).Error; err != nil {
 		log.Printf("[AUTO-SYNC] 查询节点失败: %v", err)
 		return
 	}
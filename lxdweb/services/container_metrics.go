@@ -0,0 +1,301 @@
+package services
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"lxdweb/config"
+	"lxdweb/database"
+	"lxdweb/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// rollupWindow 单次聚合读取的最大时间跨度，避免历史积压时一次加载过多原始数据
+const rollupWindow = 6 * time.Hour
+
+// metricTier 一个存储层级，Step 为 0 表示原始采样
+type metricTier struct {
+	Step      int
+	Retention time.Duration
+}
+
+// MetricPoint 查询返回的单个数据点
+type MetricPoint struct {
+	Time         time.Time `json:"time"`
+	Samples      int       `json:"samples"`
+	CPUUsage     float64   `json:"cpu_usage"`
+	CPUMax       float64   `json:"cpu_max"`
+	MemoryUsage  uint64    `json:"memory_usage"`
+	MemoryTotal  uint64    `json:"memory_total"`
+	DiskUsage    uint64    `json:"disk_usage"`
+	DiskTotal    uint64    `json:"disk_total"`
+	TrafficTotal uint64    `json:"traffic_total"`
+	TrafficIn    uint64    `json:"traffic_in"`
+	TrafficOut   uint64    `json:"traffic_out"`
+}
+
+func StartContainerMetricsService() {
+	log.Println("[METRICS] 容器监控历史服务启动")
+
+	go func() {
+		ticker := time.NewTicker(1 * time.Minute)
+		for range ticker.C {
+			rollupAllMetrics()
+		}
+	}()
+
+	go func() {
+		pruneContainerMetrics()
+		ticker := time.NewTicker(1 * time.Hour)
+		for range ticker.C {
+			pruneContainerMetrics()
+		}
+	}()
+}
+
+// recordContainerMetric 写入一条原始采样，在容器缓存更新成功后调用
+func recordContainerMetric(cache *models.ContainerCache) {
+	metric := models.ContainerMetric{
+		NodeID:       cache.NodeID,
+		Hostname:     cache.Hostname,
+		Step:         0,
+		Timestamp:    cache.LastSync,
+		Samples:      1,
+		CPUUsage:     cache.CPUUsage,
+		CPUMax:       cache.CPUUsage,
+		MemoryUsage:  cache.MemoryUsage,
+		MemoryTotal:  cache.MemoryTotal,
+		DiskUsage:    cache.DiskUsage,
+		DiskTotal:    cache.DiskTotal,
+		TrafficTotal: cache.TrafficTotal,
+		TrafficIn:    cache.TrafficIn,
+		TrafficOut:   cache.TrafficOut,
+	}
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&metric)
+	if result.Error != nil {
+		log.Printf("[METRICS] 记录容器 %s 监控数据失败: %v", cache.Hostname, result.Error)
+	}
+}
+
+// metricTiers 返回按粒度升序排列的存储层级，第一个为原始采样。
+// 配置中粒度不是上一层整数倍的层级会被忽略，保证每层都能由上一层完整聚合
+func metricTiers() []metricTier {
+	cfg := config.MetricsConfig{
+		RawRetention: 24,
+		Rollups:      []config.MetricsRollup{{Step: 300, Retention: 168}, {Step: 3600, Retention: 2160}},
+	}
+	if config.AppConfig != nil {
+		if config.AppConfig.Metrics.RawRetention > 0 {
+			cfg.RawRetention = config.AppConfig.Metrics.RawRetention
+		}
+		if len(config.AppConfig.Metrics.Rollups) > 0 {
+			cfg.Rollups = config.AppConfig.Metrics.Rollups
+		}
+	}
+
+	rollups := append([]config.MetricsRollup(nil), cfg.Rollups...)
+	sort.Slice(rollups, func(i, j int) bool { return rollups[i].Step < rollups[j].Step })
+
+	tiers := []metricTier{{Step: 0, Retention: time.Duration(cfg.RawRetention) * time.Hour}}
+	for _, r := range rollups {
+		prev := tiers[len(tiers)-1].Step
+		if r.Step <= prev || r.Retention <= 0 || (prev > 0 && r.Step%prev != 0) {
+			log.Printf("[METRICS] 忽略无效的降采样配置: step=%d retention=%d", r.Step, r.Retention)
+			continue
+		}
+		tiers = append(tiers, metricTier{Step: r.Step, Retention: time.Duration(r.Retention) * time.Hour})
+	}
+	return tiers
+}
+
+func rollupAllMetrics() {
+	tiers := metricTiers()
+	for i := 1; i < len(tiers); i++ {
+		rollupMetrics(tiers[i-1].Step, tiers[i].Step)
+	}
+}
+
+// rollupMetrics 将 source 层已结束的时间段聚合写入 target 层
+func rollupMetrics(source, target int) {
+	stepDur := time.Duration(target) * time.Second
+	end := time.Now().Truncate(stepDur)
+
+	var start time.Time
+	var last models.ContainerMetric
+	if err := database.DB.Where("step = ?", target).Order("timestamp desc").First(&last).Error; err == nil {
+		start = last.Timestamp.Add(stepDur)
+	} else {
+		var first models.ContainerMetric
+		if err := database.DB.Where("step = ?", source).Order("timestamp asc").First(&first).Error; err != nil {
+			return
+		}
+		start = first.Timestamp.Truncate(stepDur)
+	}
+
+	for start.Before(end) {
+		windowEnd := start.Add(rollupWindow).Truncate(stepDur)
+		if !windowEnd.After(start) {
+			windowEnd = start.Add(stepDur)
+		}
+		if windowEnd.After(end) {
+			windowEnd = end
+		}
+
+		var rows []models.ContainerMetric
+		database.DB.Where("step = ? AND timestamp >= ? AND timestamp < ?", source, start, windowEnd).
+			Order("timestamp asc").Find(&rows)
+
+		if len(rows) > 0 {
+			aggregated := aggregateMetrics(rows, target)
+			result := database.DB.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "node_id"}, {Name: "hostname"}, {Name: "step"}, {Name: "timestamp"}},
+				UpdateAll: true,
+			}).CreateInBatches(aggregated, 200)
+			if result.Error != nil {
+				log.Printf("[METRICS] 聚合 %ds 数据失败: %v", target, result.Error)
+				return
+			}
+		}
+		start = windowEnd
+	}
+}
+
+// aggregateMetrics 按容器和时间段聚合，rows 需按时间升序。
+// 使用量按采样数加权平均，CPU 峰值取最大值，总量和流量计数取区间内最后一次
+func aggregateMetrics(rows []models.ContainerMetric, step int) []models.ContainerMetric {
+	type bucketKey struct {
+		nodeID   uint
+		hostname string
+		ts       int64
+	}
+	type bucket struct {
+		metric  models.ContainerMetric
+		cpuSum  float64
+		memSum  float64
+		diskSum float64
+	}
+
+	stepDur := time.Duration(step) * time.Second
+	buckets := make(map[bucketKey]*bucket)
+	var order []bucketKey
+
+	for _, row := range rows {
+		ts := row.Timestamp.Truncate(stepDur)
+		key := bucketKey{row.NodeID, row.Hostname, ts.Unix()}
+		b := buckets[key]
+		if b == nil {
+			b = &bucket{metric: models.ContainerMetric{
+				NodeID:    row.NodeID,
+				Hostname:  row.Hostname,
+				Step:      step,
+				Timestamp: ts,
+			}}
+			buckets[key] = b
+			order = append(order, key)
+		}
+
+		samples := row.Samples
+		if samples < 1 {
+			samples = 1
+		}
+		b.metric.Samples += samples
+		b.cpuSum += row.CPUUsage * float64(samples)
+		b.memSum += float64(row.MemoryUsage) * float64(samples)
+		b.diskSum += float64(row.DiskUsage) * float64(samples)
+		if row.CPUMax > b.metric.CPUMax {
+			b.metric.CPUMax = row.CPUMax
+		}
+		b.metric.MemoryTotal = row.MemoryTotal
+		b.metric.DiskTotal = row.DiskTotal
+		b.metric.TrafficTotal = row.TrafficTotal
+		b.metric.TrafficIn = row.TrafficIn
+		b.metric.TrafficOut = row.TrafficOut
+	}
+
+	result := make([]models.ContainerMetric, 0, len(order))
+	for _, key := range order {
+		b := buckets[key]
+		n := float64(b.metric.Samples)
+		b.metric.CPUUsage = b.cpuSum / n
+		b.metric.MemoryUsage = uint64(b.memSum / n)
+		b.metric.DiskUsage = uint64(b.diskSum / n)
+		result = append(result, b.metric)
+	}
+	return result
+}
+
+func pruneContainerMetrics() {
+	now := time.Now()
+	for _, tier := range metricTiers() {
+		result := database.DB.Where("step = ? AND timestamp < ?", tier.Step, now.Add(-tier.Retention)).Delete(&models.ContainerMetric{})
+		if result.Error != nil {
+			log.Printf("[METRICS] 清理 %ds 数据失败: %v", tier.Step, result.Error)
+		} else if result.RowsAffected > 0 {
+			log.Printf("[METRICS] 清理 %d 条过期 %ds 数据", result.RowsAffected, tier.Step)
+		}
+	}
+}
+
+// QueryContainerMetrics 查询容器历史数据，返回实际使用的粒度（秒）。
+// step 为 0 时自动取约 500 个点，所选层级粒度更细时按 step 再次聚合
+func QueryContainerMetrics(nodeID uint, hostname string, from, to time.Time, step int) ([]MetricPoint, int) {
+	if step <= 0 {
+		step = int(to.Sub(from).Seconds()) / 500
+	}
+	if step < 60 {
+		step = 60
+	}
+
+	tier := selectMetricTier(metricTiers(), from, step)
+	if tier.Step > step {
+		step = tier.Step
+	}
+
+	var rows []models.ContainerMetric
+	database.DB.Where("node_id = ? AND hostname = ? AND step = ? AND timestamp >= ? AND timestamp <= ?",
+		nodeID, hostname, tier.Step, from, to).Order("timestamp asc").Find(&rows)
+
+	if tier.Step != step {
+		rows = aggregateMetrics(rows, step)
+	}
+
+	points := make([]MetricPoint, 0, len(rows))
+	for _, row := range rows {
+		points = append(points, MetricPoint{
+			Time:         row.Timestamp,
+			Samples:      row.Samples,
+			CPUUsage:     row.CPUUsage,
+			CPUMax:       row.CPUMax,
+			MemoryUsage:  row.MemoryUsage,
+			MemoryTotal:  row.MemoryTotal,
+			DiskUsage:    row.DiskUsage,
+			DiskTotal:    row.DiskTotal,
+			TrafficTotal: row.TrafficTotal,
+			TrafficIn:    row.TrafficIn,
+			TrafficOut:   row.TrafficOut,
+		})
+	}
+	return points, step
+}
+
+// selectMetricTier 优先选择覆盖起始时间且粒度不超过 step 的最粗层级，
+// 其次选择覆盖起始时间的最细层级，都不满足时使用保留最久的层级
+func selectMetricTier(tiers []metricTier, from time.Time, step int) metricTier {
+	now := time.Now()
+	covers := func(t metricTier) bool {
+		return !from.Before(now.Add(-t.Retention))
+	}
+	for i := len(tiers) - 1; i >= 0; i-- {
+		if tiers[i].Step <= step && covers(tiers[i]) {
+			return tiers[i]
+		}
+	}
+	for _, t := range tiers {
+		if covers(t) {
+			return t
+		}
+	}
+	return tiers[len(tiers)-1]
+}
@@ -1,19 +1,16 @@
 package services
 
 import (
-	"bytes"
-	"crypto/tls"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"sync"
 	"time"
 
 	"lxdweb/config"
 	"lxdweb/database"
 	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
 	"gorm.io/gorm/clause"
 )
 
@@ -29,7 +26,7 @@ func StartContainerSyncService() {
 // SyncAllNodesAsync 同步所有活动节点的容器
 func SyncAllNodesAsync() {
 	var nodes []models.Node
-	database.DB.Where("status = ?", "active").Find(&nodes)
+	database.DB.Where("status IN ?", []string{"active", "degraded"}).Find(&nodes)
 	
 	log.Printf("[SYNC] 开始实时同步 %d 个活动节点", len(nodes))
 	
@@ -78,43 +75,38 @@ func RefreshNodeContainers(nodeID uint, manual bool) error {
 	
 	log.Printf("[REFRESH] 开始刷新节点 %s (ID: %d)%s", node.Name, node.ID, map[bool]string{true: " [手动]", false: ""}[manual])
 
+	ctx := lxdapi.WithClass(context.Background(), lxdapi.ClassSync)
+	client := NodeClient(node)
+
 	// 第一步：刷新 lxdapi 缓存
 	log.Printf("[REFRESH] 步骤1: 调用节点 %s 刷新缓存", node.Name)
-	refreshResult := callNodeAPI(node, "GET", "/api/cache/containers/refresh", nil)
-	if refreshResult["code"] != float64(200) {
-		log.Printf("[REFRESH] 节点 %s 刷新缓存失败: %v，继续尝试获取旧缓存", node.Name, refreshResult["msg"])
+	if _, err := client.RefreshCache(ctx); err != nil {
+		log.Printf("[REFRESH] 节点 %s 刷新缓存失败: %v，继续尝试获取旧缓存", node.Name, err)
 	} else {
 		log.Printf("[REFRESH] 节点 %s 缓存刷新成功", node.Name)
 	}
 
 	// 第二步：获取缓存数据
 	log.Printf("[REFRESH] 步骤2: 获取节点 %s 缓存数据", node.Name)
-	listResult := callNodeAPI(node, "GET", "/api/cache/containers", nil)
-	if listResult["code"] != float64(200) {
-		task.Status = "failed"
-		task.ErrorMessage = fmt.Sprintf("获取容器缓存失败: %v", listResult["msg"])
-		endTime := time.Now()
-		task.EndTime = &endTime
-		database.DB.Save(&task)
-
-		log.Printf("[REFRESH] 节点 %s 获取缓存失败，清理旧缓存数据", node.Name)
-		database.DB.Unscoped().Where("node_id = ?", node.ID).Delete(&models.ContainerCache{})
-		
-		return fmt.Errorf("获取容器缓存失败")
-	}
-	
-	data, ok := listResult["data"].([]interface{})
-	if !ok {
+	data, err := client.CachedContainers(ctx)
+	if err != nil {
 		task.Status = "failed"
-		task.ErrorMessage = "容器列表格式错误"
+		task.ErrorMessage = fmt.Sprintf("获取容器缓存失败: %v", err)
 		endTime := time.Now()
 		task.EndTime = &endTime
 		database.DB.Save(&task)
+		observeSyncTask(&task)
 
-		log.Printf("[REFRESH] 节点 %s 返回数据格式错误，清理旧缓存数据", node.Name)
-		database.DB.Unscoped().Where("node_id = ?", node.ID).Delete(&models.ContainerCache{})
+		if lxdapi.IsCircuitOpen(err) {
+			log.Printf("[REFRESH] 节点 %s 已熔断，保留旧缓存数据", node.Name)
+		} else {
+			log.Printf("[REFRESH] 节点 %s 获取缓存失败，清理旧缓存数据", node.Name)
+			database.DB.Unscoped().Where("node_id = ?", node.ID).Delete(&models.ContainerCache{})
+			ClearNATRules(node.ID, "")
+			ClearIPv6Bindings(node.ID, "")
+		}
 		
-		return fmt.Errorf("容器列表格式错误")
+		return fmt.Errorf("获取容器缓存失败: %w", err)
 	}
 	
 	task.TotalCount = len(data)
@@ -125,21 +117,27 @@ func RefreshNodeContainers(nodeID uint, manual bool) error {
 
 	log.Printf("[REFRESH] 节点 %s 开始处理缓存数据，共 %d 个容器", node.Name, len(data))
 
-	for _, item := range data {
-		container, ok := item.(map[string]interface{})
-		if !ok {
-			failedCount++
-			continue
+	existingHostnames := make(map[string]bool)
+	for i := range data {
+		if data[i].Hostname != "" {
+			existingHostnames[data[i].Hostname] = true
 		}
-		
-		hostname, _ := container["hostname"].(string)
-		if hostname == "" {
+	}
+	hostnames := make([]string, 0, len(existingHostnames))
+	for hostname := range existingHostnames {
+		hostnames = append(hostnames, hostname)
+	}
+	traffic := fetchNodeTraffic(ctx, client, node, hostnames)
+
+	for i := range data {
+		container := &data[i]
+		if container.Hostname == "" {
 			failedCount++
 			continue
 		}
 		
-		if err := updateContainerCache(node, container); err != nil {
-			log.Printf("[REFRESH] 更新容器缓存失败 %s: %v", hostname, err)
+		if err := updateContainerCache(node, container, traffic[container.Hostname]); err != nil {
+			log.Printf("[REFRESH] 更新容器缓存失败 %s: %v", container.Hostname, err)
 			failedCount++
 		} else {
 			successCount++
@@ -149,15 +147,6 @@ func RefreshNodeContainers(nodeID uint, manual bool) error {
 	var cachedContainers []models.ContainerCache
 	database.DB.Where("node_id = ?", node.ID).Find(&cachedContainers)
 	
-	existingHostnames := make(map[string]bool)
-	for _, item := range data {
-		if container, ok := item.(map[string]interface{}); ok {
-			if hostname, ok := container["hostname"].(string); ok {
-				existingHostnames[hostname] = true
-			}
-		}
-	}
-	
 	for _, cached := range cachedContainers {
 		if !existingHostnames[cached.Hostname] {
 			database.DB.Unscoped().Delete(&cached)
@@ -165,12 +154,21 @@ func RefreshNodeContainers(nodeID uint, manual bool) error {
 		}
 	}
 
+	// 第三步：同步端口转发规则和 IPv6 绑定
+	if _, err := SyncNATRules(ctx, client, node, hostnames); err != nil {
+		log.Printf("[REFRESH] 节点 %s 端口转发规则同步失败: %v", node.Name, err)
+	}
+	if _, err := SyncIPv6Bindings(ctx, client, node, hostnames); err != nil {
+		log.Printf("[REFRESH] 节点 %s IPv6 绑定同步失败: %v", node.Name, err)
+	}
+
 	task.Status = "completed"
 	task.SuccessCount = successCount
 	task.FailedCount = failedCount
 	endTime := time.Now()
 	task.EndTime = &endTime
 	database.DB.Save(&task)
+	observeSyncTask(&task)
 	
 	log.Printf("[REFRESH] 节点 %s 刷新完成: 成功 %d, 失败 %d, 总计 %d", 
 		node.Name, successCount, failedCount, task.TotalCount)
@@ -210,28 +208,20 @@ func SyncNodeContainers(nodeID uint, manual bool) error {
 	
 	log.Printf("[SYNC] 开始实时同步节点 %s (ID: %d)%s", node.Name, node.ID, map[bool]string{true: " [手动]", false: ""}[manual])
 
-	cacheResult := callNodeAPI(node, "GET", "/api/cache/containers", nil)
-	if cacheResult["code"] != float64(200) {
-		task.Status = "failed"
-		task.ErrorMessage = fmt.Sprintf("获取容器列表失败: %v", cacheResult["msg"])
-		endTime := time.Now()
-		task.EndTime = &endTime
-		database.DB.Save(&task)
-		
-		log.Printf("[SYNC] 节点 %s 获取容器列表失败", node.Name)
-		return fmt.Errorf("获取容器列表失败")
-	}
-	
-	data, ok := cacheResult["data"].([]interface{})
-	if !ok {
+	ctx := lxdapi.WithClass(context.Background(), lxdapi.ClassSync)
+	client := NodeClient(node)
+
+	data, err := client.CachedContainers(ctx)
+	if err != nil {
 		task.Status = "failed"
-		task.ErrorMessage = "容器列表格式错误"
+		task.ErrorMessage = fmt.Sprintf("获取容器列表失败: %v", err)
 		endTime := time.Now()
 		task.EndTime = &endTime
 		database.DB.Save(&task)
+		observeSyncTask(&task)
 		
-		log.Printf("[SYNC] 节点 %s 容器列表格式错误", node.Name)
-		return fmt.Errorf("容器列表格式错误")
+		log.Printf("[SYNC] 节点 %s 获取容器列表失败: %v", node.Name, err)
+		return fmt.Errorf("获取容器列表失败: %w", err)
 	}
 	
 	task.TotalCount = len(data)
@@ -243,16 +233,17 @@ func SyncNodeContainers(nodeID uint, manual bool) error {
 	log.Printf("[SYNC] 节点 %s 开始实时同步，共 %d 个容器，批次大小: %d, 批次间隔: %d秒", 
 		node.Name, len(data), node.BatchSize, node.BatchInterval)
 
-	batchSize := node.BatchSize
-	if batchSize <= 0 {
-		batchSize = 5
-	}
-	batchInterval := time.Duration(node.BatchInterval) * time.Second
-	if node.BatchInterval <= 0 {
-		batchInterval = 5 * time.Second
-	}
+	batchSize, batchInterval := nodeBatchSettings(node)
 
+	aborted := false
 	for i := 0; i < len(data); i += batchSize {
+		if NodeCircuitOpen(node.ID) {
+			log.Printf("[SYNC] 节点 %s 已熔断，中止剩余 %d 个容器的同步", node.Name, len(data)-i)
+			failedCount += len(data) - i
+			aborted = true
+			break
+		}
+
 		end := i + batchSize
 		if end > len(data) {
 			end = len(data)
@@ -265,16 +256,7 @@ func SyncNodeContainers(nodeID uint, manual bool) error {
 		var mu sync.Mutex
 		
 		for _, item := range batch {
-			container, ok := item.(map[string]interface{})
-			if !ok {
-				mu.Lock()
-				failedCount++
-				mu.Unlock()
-				continue
-			}
-			
-			hostname, _ := container["hostname"].(string)
-			if hostname == "" {
+			if item.Hostname == "" {
 				mu.Lock()
 				failedCount++
 				mu.Unlock()
@@ -286,33 +268,27 @@ func SyncNodeContainers(nodeID uint, manual bool) error {
 				defer wg.Done()
 				
 				log.Printf("[SYNC] 同步容器 %s", h)
-				infoResult := callNodeAPI(node, "GET", fmt.Sprintf("/api/info?hostname=%s", h), nil)
-				
-				if infoResult["code"] != float64(200) {
-					log.Printf("[SYNC] 容器 %s 同步失败: %v", h, infoResult["msg"])
+				info, err := client.Info(ctx, h)
+				if err != nil {
+					log.Printf("[SYNC] 容器 %s 同步失败: %v", h, err)
 					mu.Lock()
 					failedCount++
 					mu.Unlock()
 					return
 				}
 				
-				if infoData, ok := infoResult["data"].(map[string]interface{}); ok {
-					if err := updateContainerCache(node, infoData); err != nil {
-						log.Printf("[SYNC] 容器 %s 缓存更新失败: %v", h, err)
-						mu.Lock()
-						failedCount++
-						mu.Unlock()
-					} else {
-						mu.Lock()
-						successCount++
-						mu.Unlock()
-					}
-				} else {
+				traffic := fetchContainerTraffic(ctx, client, h)
+				if err := updateContainerCache(node, info, traffic); err != nil {
+					log.Printf("[SYNC] 容器 %s 缓存更新失败: %v", h, err)
 					mu.Lock()
 					failedCount++
 					mu.Unlock()
+				} else {
+					mu.Lock()
+					successCount++
+					mu.Unlock()
 				}
-			}(hostname)
+			}(item.Hostname)
 		}
 		
 		wg.Wait()
@@ -323,12 +299,32 @@ func SyncNodeContainers(nodeID uint, manual bool) error {
 		}
 	}
 
+	if !aborted {
+		hostnames := make([]string, 0, len(data))
+		for _, item := range data {
+			if item.Hostname != "" {
+				hostnames = append(hostnames, item.Hostname)
+			}
+		}
+		if _, err := SyncNATRules(ctx, client, node, hostnames); err != nil {
+			log.Printf("[SYNC] 节点 %s 端口转发规则同步失败: %v", node.Name, err)
+		}
+		if _, err := SyncIPv6Bindings(ctx, client, node, hostnames); err != nil {
+			log.Printf("[SYNC] 节点 %s IPv6 绑定同步失败: %v", node.Name, err)
+		}
+	}
+
 	task.Status = "completed"
+	if aborted {
+		task.Status = "failed"
+		task.ErrorMessage = lxdapi.ErrCircuitOpen.Error()
+	}
 	task.SuccessCount = successCount
 	task.FailedCount = failedCount
 	endTime := time.Now()
 	task.EndTime = &endTime
 	database.DB.Save(&task)
+	observeSyncTask(&task)
 	
 	log.Printf("[SYNC] 节点 %s 实时同步完成: 成功 %d, 失败 %d, 总计 %d", 
 		node.Name, successCount, failedCount, task.TotalCount)
@@ -336,235 +332,144 @@ func SyncNodeContainers(nodeID uint, manual bool) error {
 	return nil
 }
 
-func updateContainerCache(node models.Node, data map[string]interface{}) error {
-	hostname, _ := data["hostname"].(string)
-	if hostname == "" {
-		return fmt.Errorf("hostname为空")
-	}
-
-	updates := map[string]interface{}{
-		"node_name": node.Name,
-		"last_sync": time.Now(),
-		"sync_error": "",
-	}
-
-	if status, ok := data["status"].(string); ok {
-		updates["status"] = status
-	}
-	if ipv4, ok := data["ipv4"].(string); ok {
-		updates["ipv4"] = ipv4
-	}
-	if ipv6, ok := data["ipv6"].(string); ok {
-		updates["ipv6"] = ipv6
-	}
-	if image, ok := data["image"].(string); ok {
-		updates["image"] = image
+// nodeBatchSettings 返回节点的批次大小和批次间隔，未配置时均为默认值 5
+func nodeBatchSettings(node models.Node) (int, time.Duration) {
+	batchSize := node.BatchSize
+	if batchSize <= 0 {
+		batchSize = 5
 	}
-	if cpus, ok := data["cpus"].(float64); ok {
-		updates["cpus"] = int(cpus)
+	batchInterval := time.Duration(node.BatchInterval) * time.Second
+	if node.BatchInterval <= 0 {
+		batchInterval = 5 * time.Second
 	}
+	return batchSize, batchInterval
+}
 
-	var memory, disk, ingress, egress string
-	var trafficLimit int
-	if config, ok := data["config"].(map[string]interface{}); ok {
-		if mem, ok := config["memory"].(string); ok {
-			memory = mem
-		}
-		if dsk, ok := config["disk"].(string); ok {
-			disk = dsk
-		}
-		if limit, ok := config["traffic_limit"].(float64); ok {
-			trafficLimit = int(limit)
+// runNodeBatches 按节点的批次大小并发对每个容器执行 fn，批次之间等待批次间隔。
+// 节点熔断时中止剩余批次并返回 ErrCircuitOpen
+func runNodeBatches(node models.Node, hostnames []string, fn func(hostname string)) error {
+	batchSize, batchInterval := nodeBatchSettings(node)
+	for i := 0; i < len(hostnames); i += batchSize {
+		if NodeCircuitOpen(node.ID) {
+			return lxdapi.ErrCircuitOpen
 		}
-		if ing, ok := config["ingress"].(string); ok {
-			ingress = ing
-		}
-		if egr, ok := config["egress"].(string); ok {
-			egress = egr
+		end := i + batchSize
+		if end > len(hostnames) {
+			end = len(hostnames)
 		}
-	}
 
-	if memory == "" {
-		if mem, ok := data["memory"].(float64); ok {
-			memory = fmt.Sprintf("%.0fMB", mem)
-		}
-	}
-	if disk == "" {
-		if dsk, ok := data["disk"].(float64); ok {
-			disk = fmt.Sprintf("%.0fMB", dsk)
+		var wg sync.WaitGroup
+		for _, hostname := range hostnames[i:end] {
+			wg.Add(1)
+			go func(h string) {
+				defer wg.Done()
+				fn(h)
+			}(hostname)
 		}
-	}
-	
-	if memory != "" {
-		updates["memory"] = memory
-	}
-	if disk != "" {
-		updates["disk"] = disk
-	}
-	if trafficLimit > 0 {
-		updates["traffic_limit"] = trafficLimit
-	}
-	if ingress != "" {
-		updates["ingress"] = ingress
-	}
-	if egress != "" {
-		updates["egress"] = egress
-	}
+		wg.Wait()
 
-	if cpuUsage, ok := data["cpu_percent"].(float64); ok {
-		updates["cpu_usage"] = cpuUsage
-	} else if cpuUsage, ok := data["cpu_usage"].(float64); ok {
-		updates["cpu_usage"] = cpuUsage
+		if end < len(hostnames) {
+			time.Sleep(batchInterval)
+		}
 	}
+	return nil
+}
 
-	if memUsageRaw, ok := data["memory_usage_raw"].(float64); ok {
-		updates["memory_usage"] = uint64(memUsageRaw)
-	}
-	if memTotal, ok := data["memory"].(float64); ok {
-		updates["memory_total"] = uint64(memTotal * 1024 * 1024) 
+// updateContainerCache 写入容器缓存并记录监控与流量账本。
+// traffic 为 nil 或节点未返回分方向计数时，总量取 traffic_usage_raw，进出流量保留上次的值
+func updateContainerCache(node models.Node, info *lxdapi.ContainerInfo, traffic *lxdapi.TrafficStats) error {
+	if info.Hostname == "" {
+		return fmt.Errorf("hostname为空")
 	}
 
-	if diskUsageRaw, ok := data["disk_usage_raw"].(float64); ok {
-		updates["disk_usage"] = uint64(diskUsageRaw)
+	memory := info.Config.Memory
+	if memory == "" && info.Memory > 0 {
+		memory = fmt.Sprintf("%.0fMB", info.Memory)
 	}
-	if diskTotal, ok := data["disk"].(float64); ok {
-		updates["disk_total"] = uint64(diskTotal * 1024 * 1024) 
-	}
-
-	if trafficRaw, ok := data["traffic_usage_raw"].(float64); ok {
-		updates["traffic_total"] = uint64(trafficRaw)
-		updates["traffic_in"] = uint64(trafficRaw * 0.5)
-		updates["traffic_out"] = uint64(trafficRaw * 0.5)
+	disk := info.Config.Disk
+	if disk == "" && info.Disk > 0 {
+		disk = fmt.Sprintf("%.0fMB", info.Disk)
 	}
 
 	cache := models.ContainerCache{
-		NodeID:   node.ID,
-		NodeName: node.Name,
-		Hostname: hostname,
-	}
-
-	if status, ok := updates["status"].(string); ok {
-		cache.Status = status
-	}
-	if ipv4, ok := updates["ipv4"].(string); ok {
-		cache.IPv4 = ipv4
-	}
-	if ipv6, ok := updates["ipv6"].(string); ok {
-		cache.IPv6 = ipv6
+		NodeID:       node.ID,
+		NodeName:     node.Name,
+		Hostname:     info.Hostname,
+		Status:       info.Status,
+		IPv4:         info.IPv4,
+		IPv6:         info.IPv6,
+		Image:        info.Image,
+		CPUs:         info.CPUs,
+		Memory:       memory,
+		Disk:         disk,
+		TrafficLimit: info.Config.TrafficLimit,
+		Ingress:      info.Config.Ingress,
+		Egress:       info.Config.Egress,
+		MemoryUsage:  uint64(info.MemoryUsageRaw),
+		MemoryTotal:  uint64(info.Memory * 1024 * 1024),
+		DiskUsage:    uint64(info.DiskUsageRaw),
+		DiskTotal:    uint64(info.Disk * 1024 * 1024),
+		TrafficTotal: uint64(info.TrafficUsageRaw),
+		LastSync:     time.Now(),
+		SyncError:    "",
+	}
+	// 节点未返回的配置和占用保留缓存中的旧值，不用零值覆盖
+	columns := []string{
+		"node_name", "status", "ipv4", "ipv6", "image", "cpus",
+		"memory_usage", "disk_usage",
+		"traffic_total", "traffic_in", "traffic_out",
+		"last_sync", "sync_error",
 	}
-	if image, ok := updates["image"].(string); ok {
-		cache.Image = image
+	if memory != "" {
+		columns = append(columns, "memory")
 	}
-	if cpus, ok := updates["cpus"].(int); ok {
-		cache.CPUs = cpus
+	if disk != "" {
+		columns = append(columns, "disk")
 	}
-	if memory, ok := updates["memory"].(string); ok {
-		cache.Memory = memory
+	if info.Config.TrafficLimit > 0 {
+		columns = append(columns, "traffic_limit")
 	}
-	if disk, ok := updates["disk"].(string); ok {
-		cache.Disk = disk
+	if info.Config.Ingress != "" {
+		columns = append(columns, "ingress")
 	}
-	if trafficLimit, ok := updates["traffic_limit"].(int); ok {
-		cache.TrafficLimit = trafficLimit
+	if info.Config.Egress != "" {
+		columns = append(columns, "egress")
 	}
-	if ingress, ok := updates["ingress"].(string); ok {
-		cache.Ingress = ingress
+	if info.Memory > 0 {
+		columns = append(columns, "memory_total")
 	}
-	if egress, ok := updates["egress"].(string); ok {
-		cache.Egress = egress
+	if info.Disk > 0 {
+		columns = append(columns, "disk_total")
 	}
-	if cpuUsage, ok := updates["cpu_usage"].(float64); ok {
+	if cpuUsage, ok := info.CPU(); ok {
 		cache.CPUUsage = cpuUsage
+		columns = append(columns, "cpu_usage")
 	}
-	if memUsage, ok := updates["memory_usage"].(uint64); ok {
-		cache.MemoryUsage = memUsage
-	}
-	if memTotal, ok := updates["memory_total"].(uint64); ok {
-		cache.MemoryTotal = memTotal
-	}
-	if diskUsage, ok := updates["disk_usage"].(uint64); ok {
-		cache.DiskUsage = diskUsage
+	if traffic == nil {
+		traffic = &lxdapi.TrafficStats{TotalBytes: cache.TrafficTotal}
 	}
-	if diskTotal, ok := updates["disk_total"].(uint64); ok {
-		cache.DiskTotal = diskTotal
-	}
-	if trafficTotal, ok := updates["traffic_total"].(uint64); ok {
-		cache.TrafficTotal = trafficTotal
-	}
-	if trafficIn, ok := updates["traffic_in"].(uint64); ok {
-		cache.TrafficIn = trafficIn
-	}
-	if trafficOut, ok := updates["traffic_out"].(uint64); ok {
-		cache.TrafficOut = trafficOut
-	}
-	if lastSync, ok := updates["last_sync"].(time.Time); ok {
-		cache.LastSync = lastSync
-	}
-	if syncError, ok := updates["sync_error"].(string); ok {
-		cache.SyncError = syncError
+	if traffic.Directional {
+		cache.TrafficTotal = traffic.TotalBytes
+		cache.TrafficIn = traffic.RxBytes
+		cache.TrafficOut = traffic.TxBytes
+	} else {
+		database.DB.Model(&models.ContainerCache{}).Select("traffic_in", "traffic_out").
+			Where("node_id = ? AND hostname = ?", node.ID, info.Hostname).Scan(&cache)
 	}
 
 	result := database.DB.Clauses(clause.OnConflict{
 		Columns: []clause.Column{{Name: "node_id"}, {Name: "hostname"}},
-		DoUpdates: clause.AssignmentColumns([]string{
-			"node_name", "status", "ipv4", "ipv6", "image",
-			"cpus", "memory", "disk", "traffic_limit",
-			"ingress", "egress",
-			"cpu_usage", "memory_usage", "memory_total",
-			"disk_usage", "disk_total",
-			"traffic_total", "traffic_in", "traffic_out",
-			"last_sync", "sync_error",
-		}),
+		DoUpdates: clause.AssignmentColumns(columns),
 	}).Create(&cache)
-	
-	return result.Error
-}
-
-func callNodeAPI(node models.Node, method, path string, data interface{}) map[string]interface{} {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+	if result.Error != nil {
+		return result.Error
 	}
-	
-	var body io.Reader
-	if data != nil {
-		jsonData, _ := json.Marshal(data)
-		body = bytes.NewBuffer(jsonData)
-	}
-	
-	req, err := http.NewRequest(method, node.Address+path, body)
-	if err != nil {
-		return map[string]interface{}{
-			"code": 500,
-			"msg":  "请求创建失败: " + err.Error(),
-		}
-	}
-	
-	if node.APIKey != "" {
-		req.Header.Set("apikey", node.APIKey)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	
-	resp, err := client.Do(req)
-	if err != nil {
-		return map[string]interface{}{
-			"code": 500,
-			"msg":  "请求失败: " + err.Error(),
-		}
-	}
-	defer resp.Body.Close()
-	
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return map[string]interface{}{
-			"code": 500,
-			"msg":  "响应解析失败: " + err.Error(),
-		}
-	}
-	
-	return result
+	// 后续的监控与配额逻辑使用合并后的缓存
+	database.DB.Where("node_id = ? AND hostname = ?", node.ID, cache.Hostname).First(&cache)
+
+	recordContainerMetric(&cache)
+	recordTrafficSample(node.ID, cache.Hostname, traffic, cache.LastSync)
+	return nil
 }
 
 func IsSyncing(nodeID uint) bool {
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"lxdweb/database"
+	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	_ "modernc.org/sqlite"
+)
+
+// newTestDB 为每个测试创建独立的内存数据库并替换 database.DB
+func newTestDB(t *testing.T) {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	sqlDB, err := sql.Open("sqlite", fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	db, err := gorm.Open(sqlite.Dialector{Conn: sqlDB}, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(
+		&models.Admin{},
+		&models.AdminNode{},
+		&models.Node{},
+		&models.ContainerCache{},
+		&models.ContainerMetric{},
+		&models.TrafficLedger{},
+		&models.NodeHealthCheck{},
+	); err != nil {
+		t.Fatal(err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = prev
+		sqlDB.Close()
+	})
+}
+
+func TestUpdateContainerCacheKeepsMissingFields(t *testing.T) {
+	newTestDB(t)
+	node := models.Node{Name: "n1", Address: "https://127.0.0.1:8443"}
+	database.DB.Create(&node)
+
+	cpu := 12.5
+	full := &lxdapi.ContainerInfo{
+		Hostname: "c1",
+		Status:   "RUNNING",
+		CPUs:     2,
+		Memory:   1024,
+		Disk:     10240,
+		Config: lxdapi.ContainerConfig{
+			Memory:       "1024MB",
+			Disk:         "10GB",
+			TrafficLimit: 500,
+			Ingress:      "100Mbit",
+			Egress:       "50Mbit",
+		},
+		CPUPercent:      &cpu,
+		TrafficUsageRaw: 1000,
+	}
+	if err := updateContainerCache(node, full, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	sparse := &lxdapi.ContainerInfo{Hostname: "c1", Status: "STOPPED", CPUs: 2, TrafficUsageRaw: 2000}
+	if err := updateContainerCache(node, sparse, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var cache models.ContainerCache
+	if err := database.DB.Where("node_id = ? AND hostname = ?", node.ID, "c1").First(&cache).Error; err != nil {
+		t.Fatal(err)
+	}
+	checks := []struct {
+		field     string
+		got, want interface{}
+	}{
+		{"status", cache.Status, "STOPPED"},
+		{"traffic_total", cache.TrafficTotal, uint64(2000)},
+		{"memory", cache.Memory, "1024MB"},
+		{"disk", cache.Disk, "10GB"},
+		{"traffic_limit", cache.TrafficLimit, 500},
+		{"ingress", cache.Ingress, "100Mbit"},
+		{"egress", cache.Egress, "50Mbit"},
+		{"cpu_usage", cache.CPUUsage, 12.5},
+		{"memory_total", cache.MemoryTotal, uint64(1024 * 1024 * 1024)},
+		{"disk_total", cache.DiskTotal, uint64(10240 * 1024 * 1024)},
+	}
+	for _, c := range checks {
+		if fmt.Sprint(c.got) != fmt.Sprint(c.want) {
+			t.Errorf("%s = %v, want %v", c.field, c.got, c.want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"lxdweb/database"
+	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+
+	"gorm.io/gorm"
+)
+
+// IPv6PoolConfig 节点系统信息中 ipv6_binding 的配置
+type IPv6PoolConfig struct {
+	Enabled      bool   `json:"enabled"`
+	Interface    string `json:"interface"`
+	Start        string `json:"start"`
+	PrefixLength int    `json:"prefix_length"`
+	PoolSize     int    `json:"pool_size"`
+}
+
+// IPv6PoolUsage 节点 IPv6 地址池的使用情况，Used 只统计落在地址池范围内的绑定
+type IPv6PoolUsage struct {
+	NodeID       uint                      `json:"node_id"`
+	NodeName     string                    `json:"node_name"`
+	Configured   bool                      `json:"configured"`
+	Pool         *IPv6PoolConfig           `json:"pool"`
+	End          string                    `json:"end"`
+	Used         int                       `json:"used"`
+	Free         int                       `json:"free"`
+	UsagePercent float64                   `json:"usage_percent"`
+	OutOfPool    int                       `json:"out_of_pool"`
+	Bindings     []models.IPv6BindingCache `json:"bindings"`
+	LastSync     *time.Time                `json:"last_sync"`
+}
+
+// NodeIPv6Pool 从 NodeInfoCache 读取节点上报的 IPv6 地址池配置，节点未上报时 ok 为 false
+func NodeIPv6Pool(nodeID uint) (*IPv6PoolConfig, bool) {
+	sysInfo, err := GetNodeCache(nodeID)
+	if err != nil {
+		return nil, false
+	}
+	binding, ok := sysInfo["ipv6_binding"].(map[string]interface{})
+	if !ok {
+		cfg, _ := sysInfo["config"].(map[string]interface{})
+		if binding, ok = cfg["ipv6_binding"].(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+
+	pool := &IPv6PoolConfig{
+		Enabled:   infoBool(binding["enabled"]),
+		Interface: infoString(binding["interface"]),
+	}
+	if p, ok := binding["ipv6_pool"].(map[string]interface{}); ok {
+		pool.Start = infoString(p["start"])
+		pool.PrefixLength = infoInt(p["prefix_length"])
+		pool.PoolSize = infoInt(p["pool_size"])
+	}
+	return pool, true
+}
+
+// SyncIPv6Bindings 按节点的批次大小和批次间隔拉取各容器的 /api/ipv6/list 写入 ipv6_bindings 缓存，并清理已不存在的容器的绑定。
+// 节点上报未启用 IPv6 独立绑定时直接清空缓存，不再逐个请求
+func SyncIPv6Bindings(ctx context.Context, client *lxdapi.Client, node models.Node, hostnames []string) (int, error) {
+	if pool, ok := NodeIPv6Pool(node.ID); ok && !pool.Enabled {
+		ClearIPv6Bindings(node.ID, "")
+		return 0, nil
+	}
+
+	var mu sync.Mutex
+	synced := 0
+	err := runNodeBatches(node, hostnames, func(hostname string) {
+		if err := RefreshContainerIPv6Bindings(ctx, client, node, hostname); err != nil {
+			log.Printf("[IPV6] 容器 %s 的 IPv6 绑定同步失败: %v", hostname, err)
+			return
+		}
+		mu.Lock()
+		synced++
+		mu.Unlock()
+	})
+	if err != nil {
+		return synced, err
+	}
+
+	query := database.DB.Where("node_id = ?", node.ID)
+	if len(hostnames) > 0 {
+		query = query.Where("hostname NOT IN ?", hostnames)
+	}
+	if err := query.Delete(&models.IPv6BindingCache{}).Error; err != nil {
+		return synced, err
+	}
+	log.Printf("[IPV6] 节点 %s IPv6 绑定同步完成: %d/%d 个容器", node.Name, synced, len(hostnames))
+	return synced, nil
+}
+
+// RefreshContainerIPv6Bindings 重新拉取单个容器的 IPv6 绑定并替换缓存
+func RefreshContainerIPv6Bindings(ctx context.Context, client *lxdapi.Client, node models.Node, hostname string) error {
+	list, err := client.IPv6List(ctx, hostname)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	rows := make([]models.IPv6BindingCache, 0, len(list))
+	seen := make(map[string]bool)
+	for _, b := range list {
+		addr, err := netip.ParseAddr(strings.TrimSpace(b.PublicIPv6))
+		if err != nil || !addr.Is6() || seen[addr.String()] {
+			continue
+		}
+		seen[addr.String()] = true
+		rows = append(rows, models.IPv6BindingCache{
+			NodeID:      node.ID,
+			NodeName:    node.Name,
+			Hostname:    hostname,
+			PublicIPv6:  addr.String(),
+			Status:      b.Status,
+			Description: b.Description,
+			LastSync:    now,
+		})
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("node_id = ? AND hostname = ?", node.ID, hostname).Delete(&models.IPv6BindingCache{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		// 地址从其他容器迁移过来时旧缓存可能仍占用唯一索引
+		addrs := make([]string, 0, len(rows))
+		for _, r := range rows {
+			addrs = append(addrs, r.PublicIPv6)
+		}
+		if err := tx.Where("node_id = ? AND public_ipv6 IN ?", node.ID, addrs).Delete(&models.IPv6BindingCache{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&rows).Error
+	})
+}
+
+// ClearIPv6Bindings 删除节点的 IPv6 绑定缓存，hostname 为空时删除整个节点的
+func ClearIPv6Bindings(nodeID uint, hostname string) {
+	query := database.DB.Where("node_id = ?", nodeID)
+	if hostname != "" {
+		query = query.Where("hostname = ?", hostname)
+	}
+	query.Delete(&models.IPv6BindingCache{})
+}
+
+// BuildIPv6PoolUsage 结合节点上报的地址池配置和 ipv6_bindings 缓存统计地址池使用情况
+func BuildIPv6PoolUsage(node models.Node) (*IPv6PoolUsage, error) {
+	usage := &IPv6PoolUsage{
+		NodeID:   node.ID,
+		NodeName: node.Name,
+		Bindings: []models.IPv6BindingCache{},
+	}
+	if err := database.DB.Where("node_id = ?", node.ID).Order("hostname ASC, public_ipv6 ASC").
+		Find(&usage.Bindings).Error; err != nil {
+		return nil, err
+	}
+	for _, b := range usage.Bindings {
+		if usage.LastSync == nil || b.LastSync.After(*usage.LastSync) {
+			last := b.LastSync
+			usage.LastSync = &last
+		}
+	}
+
+	pool, ok := NodeIPv6Pool(node.ID)
+	usage.Pool = pool
+	if !ok || pool.Start == "" || pool.PoolSize <= 0 {
+		usage.Used = len(usage.Bindings)
+		return usage, nil
+	}
+	start, err := netip.ParseAddr(pool.Start)
+	if err != nil || !start.Is6() {
+		usage.Used = len(usage.Bindings)
+		return usage, nil
+	}
+
+	usage.Configured = true
+	end := ipv6Offset(start, pool.PoolSize-1)
+	usage.End = end.String()
+	for _, b := range usage.Bindings {
+		addr, err := netip.ParseAddr(b.PublicIPv6)
+		if err == nil && addr.Compare(start) >= 0 && addr.Compare(end) <= 0 {
+			usage.Used++
+		} else {
+			usage.OutOfPool++
+		}
+	}
+	usage.Free = pool.PoolSize - usage.Used
+	if usage.Free < 0 {
+		usage.Free = 0
+	}
+	usage.UsagePercent = float64(usage.Used) * 100 / float64(pool.PoolSize)
+	return usage, nil
+}
+
+// ipv6Offset 返回 addr 之后第 n 个地址，超出地址空间时返回最大地址
+func ipv6Offset(addr netip.Addr, n int) netip.Addr {
+	b := addr.As16()
+	v := new(big.Int).SetBytes(b[:])
+	v.Add(v, big.NewInt(int64(n)))
+	if v.BitLen() > 128 {
+		v.Lsh(big.NewInt(1), 128).Sub(v, big.NewInt(1))
+	}
+	var out [16]byte
+	v.FillBytes(out[:])
+	return netip.AddrFrom16(out)
+}
+
+func infoString(v interface{}) string {
+	s, _ := v.(string)
+	return strings.TrimSpace(s)
+}
+
+func infoInt(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case string:
+		i, _ := strconv.Atoi(strings.TrimSpace(n))
+		return i
+	}
+	return 0
+}
+
+func infoBool(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		ok, _ := strconv.ParseBool(strings.TrimSpace(b))
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"lxdweb/config"
+	"lxdweb/database"
+	"lxdweb/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// 登录失败原因
+const (
+	LoginFailCaptcha  = "captcha"
+	LoginFailPassword = "password"
+	LoginFail2FA      = "2fa"
+	LoginFailLocked   = "locked"
+	LoginFailOIDC     = "oidc"
+)
+
+// loginGuardMu 串行化失败计数与加锁，避免并发请求同时越过阈值
+var loginGuardMu sync.Mutex
+
+func StartLoginGuardService() {
+	cfg := getLoginConfig()
+	log.Printf("[LOGIN] 登录保护已启用，%d 秒内用户名失败 %d 次或 IP 失败 %d 次锁定 %d 秒",
+		cfg.Window, cfg.MaxUserFailures, cfg.MaxIPFailures, cfg.LockoutDuration)
+
+	go func() {
+		pruneLoginAttempts()
+		ticker := time.NewTicker(1 * time.Hour)
+		for range ticker.C {
+			pruneLoginAttempts()
+		}
+	}()
+}
+
+// LoginBlocked 返回 IP 或用户名上仍在生效的锁定
+func LoginBlocked(ip, username string) (*models.LoginLockout, bool) {
+	var lockout models.LoginLockout
+	err := database.DB.Where("locked_until > ?", time.Now()).
+		Where("(scope = ? AND value = ?) OR (scope = ? AND value = ?)",
+			models.LockoutScopeIP, ip, models.LockoutScopeUser, username).
+		Order("locked_until desc").First(&lockout).Error
+	if err != nil {
+		return nil, false
+	}
+	return &lockout, true
+}
+
+// RecordLoginFailure 记录失败并在滑动窗口内失败次数达到阈值时锁定。
+// 验证码错误只计入 IP，被锁定期间的请求只记录不计数
+func RecordLoginFailure(ip, username, reason string) {
+	loginGuardMu.Lock()
+	defer loginGuardMu.Unlock()
+
+	now := time.Now()
+	database.DB.Create(&models.LoginAttempt{
+		Username:  username,
+		IPAddress: ip,
+		Reason:    reason,
+	})
+	if reason == LoginFailLocked {
+		return
+	}
+
+	cfg := getLoginConfig()
+	windowStart := now.Add(-time.Duration(cfg.Window) * time.Second)
+
+	if ip != "" {
+		var failures int64
+		database.DB.Model(&models.LoginAttempt{}).
+			Where("ip_address = ? AND success = ? AND reason <> ? AND created_at > ?",
+				ip, false, LoginFailLocked, countFrom(models.LockoutScopeIP, ip, windowStart)).
+			Count(&failures)
+		if failures >= int64(cfg.MaxIPFailures) {
+			lockLogin(models.LockoutScopeIP, ip, int(failures), now, cfg)
+		}
+	}
+
+	if username != "" && reason != LoginFailCaptcha {
+		from := countFrom(models.LockoutScopeUser, username, windowStart)
+		// 登录成功后重新计数
+		var last models.LoginAttempt
+		if database.DB.Where("username = ? AND success = ?", username, true).
+			Order("created_at desc").First(&last).Error == nil && last.CreatedAt.After(from) {
+			from = last.CreatedAt
+		}
+
+		var failures int64
+		database.DB.Model(&models.LoginAttempt{}).
+			Where("username = ? AND success = ? AND reason IN ? AND created_at > ?",
+				username, false, []string{LoginFailPassword, LoginFail2FA}, from).
+			Count(&failures)
+		if failures >= int64(cfg.MaxUserFailures) {
+			lockLogin(models.LockoutScopeUser, username, int(failures), now, cfg)
+		}
+	}
+}
+
+// RecordLoginSuccess 记录登录成功
+func RecordLoginSuccess(ip, username string) {
+	database.DB.Create(&models.LoginAttempt{
+		Username:  username,
+		IPAddress: ip,
+		Success:   true,
+	})
+}
+
+// UnlockLogin 提前解除锁定，之前的失败记录不再计数
+func UnlockLogin(lockout *models.LoginLockout, by string) error {
+	now := time.Now()
+	return database.DB.Model(lockout).Updates(map[string]interface{}{
+		"locked_until": now,
+		"count_from":   now,
+		"unlocked_by":  by,
+	}).Error
+}
+
+func lockLogin(scope, value string, failures int, now time.Time, cfg config.LoginConfig) {
+	until := now.Add(time.Duration(cfg.LockoutDuration) * time.Second)
+	lockout := models.LoginLockout{
+		Scope:       scope,
+		Value:       value,
+		Failures:    failures,
+		LockedUntil: until,
+		CountFrom:   until,
+	}
+	err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "scope"}, {Name: "value"}},
+		DoUpdates: clause.AssignmentColumns([]string{"failures", "locked_until", "count_from", "unlocked_by", "updated_at"}),
+	}).Create(&lockout).Error
+	if err != nil {
+		log.Printf("[LOGIN] 锁定 %s %s 失败: %v", scope, value, err)
+		return
+	}
+	log.Printf("[LOGIN] %s %s 登录失败 %d 次，锁定至 %s", scope, value, failures, until.Format("2006-01-02 15:04:05"))
+}
+
+// countFrom 返回失败计数的起点：窗口开始时间与上次锁定/解锁时间中较晚者
+func countFrom(scope, value string, windowStart time.Time) time.Time {
+	var lockout models.LoginLockout
+	if database.DB.Where("scope = ? AND value = ?", scope, value).First(&lockout).Error == nil &&
+		lockout.CountFrom.After(windowStart) {
+		return lockout.CountFrom
+	}
+	return windowStart
+}
+
+func pruneLoginAttempts() {
+	cutoff := time.Now().AddDate(0, 0, -getLoginConfig().Retention)
+	result := database.DB.Where("created_at < ?", cutoff).Delete(&models.LoginAttempt{})
+	if result.Error != nil {
+		log.Printf("[LOGIN] 清理登录记录失败: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("[LOGIN] 清理 %d 条过期登录记录", result.RowsAffected)
+	}
+	database.DB.Where("locked_until < ?", cutoff).Delete(&models.LoginLockout{})
+}
+
+func getLoginConfig() config.LoginConfig {
+	cfg := config.LoginConfig{
+		Window:          900,
+		MaxUserFailures: 5,
+		MaxIPFailures:   20,
+		LockoutDuration: 900,
+		Retention:       30,
+	}
+	if config.AppConfig == nil {
+		return cfg
+	}
+	l := config.AppConfig.Login
+	if l.Window > 0 {
+		cfg.Window = l.Window
+	}
+	if l.MaxUserFailures > 0 {
+		cfg.MaxUserFailures = l.MaxUserFailures
+	}
+	if l.MaxIPFailures > 0 {
+		cfg.MaxIPFailures = l.MaxIPFailures
+	}
+	if l.LockoutDuration > 0 {
+		cfg.LockoutDuration = l.LockoutDuration
+	}
+	if l.Retention > 0 {
+		cfg.Retention = l.Retention
+	}
+	return cfg
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"lxdweb/database"
+	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+)
+
+const (
+	natInternalPortMin = 1
+	natExternalPortMin = 10000
+	natPortMax         = 65535
+	// 端口段最多逐个检查的端口数，超过时拒绝添加
+	natMaxRangeSize = 100
+)
+
+// NATConflictError 外部端口已被占用
+type NATConflictError struct {
+	Protocol string
+	Port     int
+	Reason   string
+}
+
+func (e *NATConflictError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("外部端口 %d/%s 不可用: %s", e.Port, e.Protocol, e.Reason)
+	}
+	return fmt.Sprintf("外部端口 %d/%s 不可用", e.Port, e.Protocol)
+}
+
+// AsNATConflict 判断错误是否为端口冲突
+func AsNATConflict(err error) (*NATConflictError, bool) {
+	var conflict *NATConflictError
+	ok := errors.As(err, &conflict)
+	return conflict, ok
+}
+
+// ValidateNATRequest 校验端口转发参数，规则与 ZJMF 插件一致：
+// 内部端口 1-65535，外部端口 10000-65535，添加时外部端口为 0 表示由节点自动分配，
+// 端口段需同时填写两个结束端口且内外端口数量一致
+func ValidateNATRequest(req *models.NATRuleRequest, forDelete bool) error {
+	req.Protocol = strings.ToLower(strings.TrimSpace(req.Protocol))
+	if req.Protocol != "tcp" && req.Protocol != "udp" && req.Protocol != "both" {
+		return errors.New("协议只能是 tcp、udp 或 both")
+	}
+	if req.InternalPort < natInternalPortMin || req.InternalPort > natPortMax {
+		return fmt.Errorf("内部端口范围为 %d-%d", natInternalPortMin, natPortMax)
+	}
+	if req.ExternalPort == 0 {
+		if forDelete {
+			return errors.New("请指定外部端口")
+		}
+		if req.ExternalPortEnd > 0 || req.InternalPortEnd > 0 {
+			return errors.New("端口段需要指定起始外部端口")
+		}
+		return nil
+	}
+	if req.ExternalPort < natExternalPortMin || req.ExternalPort > natPortMax {
+		return fmt.Errorf("外部端口范围为 %d-%d", natExternalPortMin, natPortMax)
+	}
+
+	if req.ExternalPortEnd == 0 && req.InternalPortEnd == 0 {
+		return nil
+	}
+	if req.ExternalPortEnd == 0 || req.InternalPortEnd == 0 {
+		return errors.New("端口段需要同时指定外部和内部结束端口")
+	}
+	if req.ExternalPortEnd < req.ExternalPort || req.ExternalPortEnd > natPortMax {
+		return errors.New("外部结束端口无效")
+	}
+	if req.InternalPortEnd < req.InternalPort || req.InternalPortEnd > natPortMax {
+		return errors.New("内部结束端口无效")
+	}
+	if req.ExternalPortEnd-req.ExternalPort != req.InternalPortEnd-req.InternalPort {
+		return errors.New("外部端口段与内部端口段的数量不一致")
+	}
+	if req.ExternalPortEnd-req.ExternalPort+1 > natMaxRangeSize {
+		return fmt.Errorf("端口段最多包含 %d 个端口", natMaxRangeSize)
+	}
+	return nil
+}
+
+// NATProtocols 展开协议，both 对应 tcp 和 udp 两条规则
+func NATProtocols(protocol string) []string {
+	if protocol == "both" {
+		return []string{"tcp", "udp"}
+	}
+	return []string{protocol}
+}
+
+// CheckNATConflict 添加前检查外部端口是否冲突：先对比容器已有规则和 nat_rules 缓存中同一 IP 上其他容器的规则，
+// 再逐个端口请求节点 /api/nat/check。外部端口为 0 时由节点分配，跳过检查。
+// canView 判断能否展示冲突规则所在的节点和容器，返回 false 时原因中不包含其名称
+func CheckNATConflict(ctx context.Context, client *lxdapi.Client, node models.Node, hostname string, req *models.NATRuleRequest, canView func(nodeID uint) bool) error {
+	if req.ExternalPort == 0 {
+		return nil
+	}
+	end := req.ExternalPortEnd
+	if end == 0 {
+		end = req.ExternalPort
+	}
+
+	rules, err := client.NATList(ctx, hostname)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if rule.Overlaps(req.Protocol, req.ExternalPort, end) {
+			return &NATConflictError{
+				Protocol: rule.Protocol,
+				Port:     rule.ExternalPort,
+				Reason:   "与容器已有的转发规则重叠",
+			}
+		}
+	}
+
+	var cached []models.NATRuleCache
+	database.DB.Where("node_id IN ? AND protocol IN ? AND external_port <= ?", sharedIPNodeIDs(node), NATProtocols(req.Protocol), end).
+		Where("NOT (node_id = ? AND hostname = ?)", node.ID, hostname).
+		Order("external_port ASC").Find(&cached)
+	for _, rule := range cached {
+		ruleEnd := rule.ExternalPortEnd
+		if ruleEnd < rule.ExternalPort {
+			ruleEnd = rule.ExternalPort
+		}
+		if ruleEnd >= req.ExternalPort {
+			reason := "已被共用该 IP 的其他节点上的容器使用"
+			if canView == nil || canView(rule.NodeID) {
+				reason = fmt.Sprintf("已被节点 %s 上的容器 %s 使用", rule.NodeName, rule.Hostname)
+			}
+			return &NATConflictError{
+				Protocol: rule.Protocol,
+				Port:     rule.ExternalPort,
+				Reason:   reason,
+			}
+		}
+	}
+
+	for _, protocol := range NATProtocols(req.Protocol) {
+		for port := req.ExternalPort; port <= end; port++ {
+			result, err := client.CheckNATPort(ctx, hostname, protocol, port)
+			if err != nil {
+				return err
+			}
+			if !result.Available {
+				return &NATConflictError{Protocol: protocol, Port: port, Reason: result.Reason}
+			}
+		}
+	}
+	return nil
+}
+
+// NATRequestFor 转换为节点接口参数
+func NATRequestFor(hostname, protocol string, req *models.NATRuleRequest) *lxdapi.NATRequest {
+	return &lxdapi.NATRequest{
+		Hostname:        hostname,
+		Protocol:        protocol,
+		ExternalPort:    req.ExternalPort,
+		ExternalPortEnd: req.ExternalPortEnd,
+		InternalPort:    req.InternalPort,
+		InternalPortEnd: req.InternalPortEnd,
+		Description:     req.Description,
+	}
+}
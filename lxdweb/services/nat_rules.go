@@ -0,0 +1,348 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"lxdweb/database"
+	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+
+	"gorm.io/gorm"
+)
+
+// 单次最多推荐的端口数
+const natMaxAllocate = 100
+
+// NATPortRange 闭区间端口段
+type NATPortRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// NATPortUsage 已被转发规则占用的外部端口段
+type NATPortUsage struct {
+	NodeID       uint   `json:"node_id"`
+	NodeName     string `json:"node_name"`
+	Hostname     string `json:"hostname"`
+	Protocol     string `json:"protocol"`
+	Start        int    `json:"start"`
+	End          int    `json:"end"`
+	InternalPort int    `json:"internal_port"`
+	Description  string `json:"description"`
+}
+
+// NATPortConflict 同一 IP 上外部端口重叠的两条规则
+type NATPortConflict struct {
+	Protocol string       `json:"protocol"`
+	Ports    NATPortRange `json:"ports"`
+	First    NATPortUsage `json:"first"`
+	Second   NATPortUsage `json:"second"`
+}
+
+// NATPortMap 节点 IP 上的外部端口分配情况。多个节点使用同一 IP 时共享端口空间，规则合并计算
+type NATPortMap struct {
+	NodeID   uint   `json:"node_id"`
+	NodeName string `json:"node_name"`
+	NodeIP   string `json:"node_ip"`
+	// 共用该 IP 的节点，接口返回前按调用方的节点权限过滤
+	NodeIDs   []uint                    `json:"node_ids"`
+	Range     NATPortRange              `json:"range"`
+	Used      map[string][]NATPortUsage `json:"used"`
+	Free      map[string][]NATPortRange `json:"free"`
+	FreeCount map[string]int            `json:"free_count"`
+	Conflicts []NATPortConflict         `json:"conflicts"`
+	LastSync  *time.Time                `json:"last_sync"`
+}
+
+// SyncNATRules 按节点的批次大小和批次间隔拉取各容器的 /api/natlist 写入 nat_rules 缓存，并清理已不存在的容器的规则。
+// 单个容器拉取失败时保留其旧数据，节点熔断时中止，返回成功同步的容器数
+func SyncNATRules(ctx context.Context, client *lxdapi.Client, node models.Node, hostnames []string) (int, error) {
+	var mu sync.Mutex
+	synced := 0
+	err := runNodeBatches(node, hostnames, func(hostname string) {
+		if err := RefreshContainerNATRules(ctx, client, node, hostname); err != nil {
+			log.Printf("[NAT] 容器 %s 的端口转发规则同步失败: %v", hostname, err)
+			return
+		}
+		mu.Lock()
+		synced++
+		mu.Unlock()
+	})
+	if err != nil {
+		return synced, err
+	}
+
+	query := database.DB.Where("node_id = ?", node.ID)
+	if len(hostnames) > 0 {
+		query = query.Where("hostname NOT IN ?", hostnames)
+	}
+	if err := query.Delete(&models.NATRuleCache{}).Error; err != nil {
+		return synced, err
+	}
+	log.Printf("[NAT] 节点 %s 端口转发规则同步完成: %d/%d 个容器", node.Name, synced, len(hostnames))
+	return synced, nil
+}
+
+// RefreshContainerNATRules 重新拉取单个容器的转发规则并替换缓存
+func RefreshContainerNATRules(ctx context.Context, client *lxdapi.Client, node models.Node, hostname string) error {
+	rules, err := client.NATList(ctx, hostname)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	rows := make([]models.NATRuleCache, 0, len(rules))
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		for _, protocol := range NATProtocols(rule.Protocol) {
+			key := fmt.Sprintf("%s/%d", protocol, rule.ExternalPort)
+			if rule.ExternalPort == 0 || seen[key] || (protocol != "tcp" && protocol != "udp") {
+				continue
+			}
+			seen[key] = true
+			rows = append(rows, models.NATRuleCache{
+				NodeID:          node.ID,
+				NodeName:        node.Name,
+				Hostname:        hostname,
+				Protocol:        protocol,
+				ExternalPort:    rule.ExternalPort,
+				ExternalPortEnd: rule.ExternalPortEnd,
+				InternalPort:    rule.InternalPort,
+				InternalPortEnd: rule.InternalPortEnd,
+				Status:          rule.Status,
+				Description:     rule.Description,
+				LastSync:        now,
+			})
+		}
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("node_id = ? AND hostname = ?", node.ID, hostname).Delete(&models.NATRuleCache{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Create(&rows).Error
+	})
+}
+
+// ClearNATRules 删除节点的转发规则缓存，hostname 为空时删除整个节点的
+func ClearNATRules(nodeID uint, hostname string) {
+	query := database.DB.Where("node_id = ?", nodeID)
+	if hostname != "" {
+		query = query.Where("hostname = ?", hostname)
+	}
+	query.Delete(&models.NATRuleCache{})
+}
+
+// NodeIP 从节点地址中取出主机部分
+func NodeIP(node models.Node) string {
+	address := node.Address
+	if !strings.Contains(address, "://") {
+		address = "https://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil || u.Hostname() == "" {
+		return node.Address
+	}
+	return u.Hostname()
+}
+
+// sharedIPNodeIDs 返回与节点使用同一 IP 的全部节点ID（包含自身）
+func sharedIPNodeIDs(node models.Node) []uint {
+	ip := NodeIP(node)
+	var nodes []models.Node
+	database.DB.Select("id", "address").Find(&nodes)
+	ids := []uint{node.ID}
+	for _, n := range nodes {
+		if n.ID != node.ID && NodeIP(n) == ip {
+			ids = append(ids, n.ID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// BuildNATPortMap 根据 nat_rules 缓存计算节点 IP 上各协议已用、空闲的外部端口段和冲突的规则
+func BuildNATPortMap(node models.Node) (*NATPortMap, error) {
+	nodeIDs := sharedIPNodeIDs(node)
+	var rules []models.NATRuleCache
+	if err := database.DB.Where("node_id IN ?", nodeIDs).
+		Order("protocol ASC, external_port ASC, node_id ASC, hostname ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	m := &NATPortMap{
+		NodeID:    node.ID,
+		NodeName:  node.Name,
+		NodeIP:    NodeIP(node),
+		NodeIDs:   nodeIDs,
+		Range:     NATPortRange{Start: natExternalPortMin, End: natPortMax},
+		Used:      map[string][]NATPortUsage{"tcp": {}, "udp": {}},
+		Free:      make(map[string][]NATPortRange),
+		FreeCount: make(map[string]int),
+		Conflicts: []NATPortConflict{},
+	}
+	for _, rule := range rules {
+		if m.LastSync == nil || rule.LastSync.After(*m.LastSync) {
+			last := rule.LastSync
+			m.LastSync = &last
+		}
+		end := rule.ExternalPortEnd
+		if end < rule.ExternalPort {
+			end = rule.ExternalPort
+		}
+		m.Used[rule.Protocol] = append(m.Used[rule.Protocol], NATPortUsage{
+			NodeID:       rule.NodeID,
+			NodeName:     rule.NodeName,
+			Hostname:     rule.Hostname,
+			Protocol:     rule.Protocol,
+			Start:        rule.ExternalPort,
+			End:          end,
+			InternalPort: rule.InternalPort,
+			Description:  rule.Description,
+		})
+	}
+
+	for protocol, used := range m.Used {
+		m.Conflicts = append(m.Conflicts, findNATConflicts(protocol, used)...)
+		m.Free[protocol] = freeNATRanges(used, m.Range)
+		for _, r := range m.Free[protocol] {
+			m.FreeCount[protocol] += r.End - r.Start + 1
+		}
+	}
+	sort.Slice(m.Conflicts, func(i, j int) bool {
+		if m.Conflicts[i].Protocol != m.Conflicts[j].Protocol {
+			return m.Conflicts[i].Protocol < m.Conflicts[j].Protocol
+		}
+		return m.Conflicts[i].Ports.Start < m.Conflicts[j].Ports.Start
+	})
+	return m, nil
+}
+
+// findNATConflicts 找出外部端口重叠的规则对，used 需按起始端口排序
+func findNATConflicts(protocol string, used []NATPortUsage) []NATPortConflict {
+	var conflicts []NATPortConflict
+	for i := range used {
+		for j := i + 1; j < len(used) && used[j].Start <= used[i].End; j++ {
+			end := used[i].End
+			if used[j].End < end {
+				end = used[j].End
+			}
+			conflicts = append(conflicts, NATPortConflict{
+				Protocol: protocol,
+				Ports:    NATPortRange{Start: used[j].Start, End: end},
+				First:    used[i],
+				Second:   used[j],
+			})
+		}
+	}
+	return conflicts
+}
+
+// freeNATRanges 计算端口范围内未被占用的端口段，used 需按起始端口排序
+func freeNATRanges(used []NATPortUsage, bounds NATPortRange) []NATPortRange {
+	free := []NATPortRange{}
+	next := bounds.Start
+	for _, u := range used {
+		if u.End < next {
+			continue
+		}
+		if u.Start > next {
+			end := u.Start - 1
+			if end > bounds.End {
+				end = bounds.End
+			}
+			if next <= end {
+				free = append(free, NATPortRange{Start: next, End: end})
+			}
+		}
+		next = u.End + 1
+		if next > bounds.End {
+			return free
+		}
+	}
+	free = append(free, NATPortRange{Start: next, End: bounds.End})
+	return free
+}
+
+// AllocateNATPorts 从端口分配视图中推荐 count 个空闲外部端口，protocol 为 both 时要求 tcp 和 udp 同时空闲。
+// contiguous 为 true 时返回一段连续端口，用于端口段规则；from 大于 0 时从该端口开始查找
+func AllocateNATPorts(m *NATPortMap, protocol string, count int, contiguous bool, from int) ([]int, error) {
+	if count <= 0 || count > natMaxAllocate {
+		return nil, fmt.Errorf("推荐数量范围为 1-%d", natMaxAllocate)
+	}
+	protocol = strings.ToLower(strings.TrimSpace(protocol))
+	if protocol != "tcp" && protocol != "udp" && protocol != "both" {
+		return nil, errors.New("协议只能是 tcp、udp 或 both")
+	}
+	if from < m.Range.Start {
+		from = m.Range.Start
+	}
+
+	free := m.Free["tcp"]
+	if protocol == "udp" {
+		free = m.Free["udp"]
+	} else if protocol == "both" {
+		free = intersectNATRanges(m.Free["tcp"], m.Free["udp"])
+	}
+
+	ports := make([]int, 0, count)
+	for _, r := range free {
+		start := r.Start
+		if start < from {
+			start = from
+		}
+		if start > r.End {
+			continue
+		}
+		if contiguous {
+			if r.End-start+1 >= count {
+				for p := start; p < start+count; p++ {
+					ports = append(ports, p)
+				}
+				return ports, nil
+			}
+			continue
+		}
+		for p := start; p <= r.End && len(ports) < count; p++ {
+			ports = append(ports, p)
+		}
+		if len(ports) == count {
+			return ports, nil
+		}
+	}
+	return nil, errors.New("没有足够的空闲端口")
+}
+
+// intersectNATRanges 求两组有序端口段的交集
+func intersectNATRanges(a, b []NATPortRange) []NATPortRange {
+	var out []NATPortRange
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		start, end := a[i].Start, a[i].End
+		if b[j].Start > start {
+			start = b[j].Start
+		}
+		if b[j].End < end {
+			end = b[j].End
+		}
+		if start <= end {
+			out = append(out, NATPortRange{Start: start, End: end})
+		}
+		if a[i].End < b[j].End {
+			i++
+		} else {
+			j++
+		}
+	}
+	return out
+}
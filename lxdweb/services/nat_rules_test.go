@@ -0,0 +1,148 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func usage(start, end int) NATPortUsage {
+	return NATPortUsage{Protocol: "tcp", Start: start, End: end}
+}
+
+func TestFindNATConflicts(t *testing.T) {
+	tests := []struct {
+		name string
+		used []NATPortUsage
+		want []NATPortRange
+	}{
+		{"无规则", nil, nil},
+		{"相邻不冲突", []NATPortUsage{usage(10000, 10009), usage(10010, 10020)}, nil},
+		{"单端口重复", []NATPortUsage{usage(10000, 10000), usage(10000, 10000)}, []NATPortRange{{10000, 10000}}},
+		{"端口段部分重叠", []NATPortUsage{usage(10000, 10050), usage(10040, 10080)}, []NATPortRange{{10040, 10050}}},
+		{"端口段嵌套", []NATPortUsage{usage(10000, 10100), usage(10010, 10020), usage(10050, 10060)},
+			[]NATPortRange{{10010, 10020}, {10050, 10060}}},
+		{"单端口落在端口段内", []NATPortUsage{usage(20000, 20100), usage(20050, 20050)}, []NATPortRange{{20050, 20050}}},
+		{"三段依次重叠", []NATPortUsage{usage(10000, 10020), usage(10010, 10030), usage(10025, 10040)},
+			[]NATPortRange{{10010, 10020}, {10025, 10030}}},
+		{"低于 10000 的规则", []NATPortUsage{usage(8000, 8100), usage(8050, 8050)}, []NATPortRange{{8050, 8050}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []NATPortRange
+			for _, c := range findNATConflicts("tcp", tt.used) {
+				if c.Protocol != "tcp" {
+					t.Fatalf("protocol = %s", c.Protocol)
+				}
+				got = append(got, c.Ports)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("findNATConflicts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFreeNATRanges(t *testing.T) {
+	bounds := NATPortRange{Start: natExternalPortMin, End: natPortMax}
+	tests := []struct {
+		name string
+		used []NATPortUsage
+		want []NATPortRange
+	}{
+		{"全部空闲", nil, []NATPortRange{{10000, 65535}}},
+		{"单个端口", []NATPortUsage{usage(20000, 20000)}, []NATPortRange{{10000, 19999}, {20001, 65535}}},
+		{"从下界开始占用", []NATPortUsage{usage(10000, 10010)}, []NATPortRange{{10011, 65535}}},
+		{"占用到上界", []NATPortUsage{usage(65530, 65535)}, []NATPortRange{{10000, 65529}}},
+		{"全部占用", []NATPortUsage{usage(10000, 65535)}, []NATPortRange{}},
+		{"端口段重叠", []NATPortUsage{usage(10000, 10050), usage(10040, 10080)}, []NATPortRange{{10081, 65535}}},
+		{"端口段嵌套", []NATPortUsage{usage(20000, 20100), usage(20010, 20020), usage(20200, 20200)},
+			[]NATPortRange{{10000, 19999}, {20101, 20199}, {20201, 65535}}},
+		{"低于 10000 的规则被忽略", []NATPortUsage{usage(22, 22), usage(8000, 9999)}, []NATPortRange{{10000, 65535}}},
+		{"跨越下界的端口段", []NATPortUsage{usage(9990, 10010)}, []NATPortRange{{10011, 65535}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := freeNATRanges(tt.used, bounds)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("freeNATRanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntersectNATRanges(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []NATPortRange
+		want []NATPortRange
+	}{
+		{"一侧为空", []NATPortRange{{10000, 10100}}, nil, nil},
+		{"不相交", []NATPortRange{{10000, 10100}}, []NATPortRange{{10200, 10300}}, nil},
+		{"部分重叠", []NATPortRange{{10000, 10100}, {10200, 10300}}, []NATPortRange{{10050, 10250}},
+			[]NATPortRange{{10050, 10100}, {10200, 10250}}},
+		{"嵌套", []NATPortRange{{10000, 20000}}, []NATPortRange{{11000, 12000}, {13000, 14000}},
+			[]NATPortRange{{11000, 12000}, {13000, 14000}}},
+		{"端点相接", []NATPortRange{{10000, 10100}}, []NATPortRange{{10100, 10200}}, []NATPortRange{{10100, 10100}}},
+		{"完全相同", []NATPortRange{{10000, 65535}}, []NATPortRange{{10000, 65535}}, []NATPortRange{{10000, 65535}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := intersectNATRanges(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("intersectNATRanges() = %v, want %v", got, tt.want)
+			}
+			if got := intersectNATRanges(tt.b, tt.a); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("intersectNATRanges() reversed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocateNATPorts(t *testing.T) {
+	m := &NATPortMap{
+		Range: NATPortRange{Start: natExternalPortMin, End: natPortMax},
+		Free: map[string][]NATPortRange{
+			"tcp": {{10000, 10001}, {10005, 10010}, {10020, 65535}},
+			"udp": {{10000, 10000}, {10008, 65535}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		protocol   string
+		count      int
+		contiguous bool
+		from       int
+		want       []int
+		wantErr    bool
+	}{
+		{"tcp 零散端口", "tcp", 4, false, 0, []int{10000, 10001, 10005, 10006}, false},
+		{"tcp 连续端口跳过过短的空闲段", "tcp", 3, true, 0, []int{10005, 10006, 10007}, false},
+		{"tcp 连续端口跨过已用端口", "tcp", 8, true, 0, []int{10020, 10021, 10022, 10023, 10024, 10025, 10026, 10027}, false},
+		{"udp", "udp", 2, false, 0, []int{10000, 10008}, false},
+		{"both 取交集", "both", 3, false, 0, []int{10000, 10008, 10009}, false},
+		{"both 连续", "both", 3, true, 0, []int{10008, 10009, 10010}, false},
+		{"both 连续跳过过短的交集", "both", 4, true, 0, []int{10020, 10021, 10022, 10023}, false},
+		{"起始端口", "tcp", 2, false, 10009, []int{10009, 10010}, false},
+		{"起始端口低于 10000", "tcp", 1, false, 80, []int{10000}, false},
+		{"协议大小写", " TCP ", 1, false, 0, []int{10000}, false},
+		{"空闲端口不足", "tcp", 2, true, 65535, nil, true},
+		{"数量为 0", "tcp", 0, false, 0, nil, true},
+		{"数量超过上限", "tcp", natMaxAllocate + 1, false, 0, nil, true},
+		{"无效协议", "icmp", 1, false, 0, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AllocateNATPorts(m, tt.protocol, tt.count, tt.contiguous, tt.from)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AllocateNATPorts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("AllocateNATPorts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
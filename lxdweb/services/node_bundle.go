@@ -0,0 +1,542 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"lxdweb/database"
+	"lxdweb/models"
+
+	"golang.org/x/crypto/scrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	NodeBundleFormat  = "lxdweb-nodes"
+	NodeBundleVersion = 2
+
+	ImportSkip      = "skip"
+	ImportOverwrite = "overwrite"
+	ImportRename    = "rename"
+
+	minBundlePassphrase = 8
+	bundleCheckTimeout  = 10 * time.Second
+	bundleCheckWorkers  = 5
+)
+
+var ErrBundlePassphrase = errors.New("口令错误或导出文件已损坏")
+
+// NodeBundle 节点导出文件。checksum 为节点列表规范化 JSON 的 SHA256，只能发现损坏，不能证明来源；
+// 设置口令时节点列表以 AES-256-GCM 加密后放在 ciphertext 中，并以口令派生的密钥对文件头和节点列表
+// 计算 HMAC-SHA256 写入 signature。密钥由口令经 scrypt 派生，前 32 字节用于加密，后 32 字节用于签名
+type NodeBundle struct {
+	Format         string            `json:"format"`
+	Version        int               `json:"version"`
+	CreatedAt      time.Time         `json:"created_at"`
+	NodeCount      int               `json:"node_count"`
+	IncludeAPIKeys bool              `json:"include_api_keys"`
+	Checksum       string            `json:"checksum"`
+	Signature      string            `json:"signature,omitempty"`
+	Encryption     *BundleEncryption `json:"encryption,omitempty"`
+	Nodes          []BundleNode      `json:"nodes,omitempty"`
+	Ciphertext     string            `json:"ciphertext,omitempty"`
+}
+
+type BundleEncryption struct {
+	Cipher string `json:"cipher"`
+	KDF    string `json:"kdf"`
+	Salt   string `json:"salt"`
+	N      int    `json:"n"`
+	R      int    `json:"r"`
+	P      int    `json:"p"`
+	Nonce  string `json:"nonce"`
+}
+
+type BundleNode struct {
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	Address        string `json:"address"`
+	APIKey         string `json:"api_key,omitempty"`
+	AutoSync       bool   `json:"auto_sync"`
+	SyncInterval   int    `json:"sync_interval"`
+	BatchSize      int    `json:"batch_size"`
+	BatchInterval  int    `json:"batch_interval"`
+	TLSMode        string `json:"tls_mode"`
+	TLSFingerprint string `json:"tls_fingerprint"`
+	TLSCACert      string `json:"tls_ca_cert"`
+}
+
+// ImportItem 单个节点的导入计划与结果
+type ImportItem struct {
+	Name       string `json:"name"`
+	TargetName string `json:"target_name,omitempty"`
+	// create | overwrite | rename | skip | error
+	Action string `json:"action"`
+	// ok | failed | skipped，未检查时为空
+	Check   string `json:"check,omitempty"`
+	Message string `json:"message,omitempty"`
+
+	node     models.Node
+	existing *models.Node
+}
+
+type ImportResult struct {
+	DryRun  bool `json:"dry_run"`
+	Version int  `json:"version"`
+	// 导出文件是否带有口令签名，未签名的文件只校验了校验和
+	Signed       bool         `json:"signed"`
+	Items        []ImportItem `json:"items"`
+	SuccessCount int          `json:"success_count"`
+	SkippedCount int          `json:"skipped_count"`
+	FailedCount  int          `json:"failed_count"`
+	// 未经过连接检查的节点数，未允许时这些节点计入失败
+	UncheckedCount int `json:"unchecked_count"`
+}
+
+// ExportNodeBundle 生成节点导出文件，只有设置口令时才包含 API 密钥
+func ExportNodeBundle(nodes []models.Node, passphrase string) (*NodeBundle, error) {
+	if passphrase != "" && len(passphrase) < minBundlePassphrase {
+		return nil, fmt.Errorf("加密口令不能少于%d位", minBundlePassphrase)
+	}
+	encrypt := passphrase != ""
+
+	list := make([]BundleNode, 0, len(nodes))
+	for _, node := range nodes {
+		item := BundleNode{
+			Name:           node.Name,
+			Description:    node.Description,
+			Address:        node.Address,
+			AutoSync:       node.AutoSync,
+			SyncInterval:   node.SyncInterval,
+			BatchSize:      node.BatchSize,
+			BatchInterval:  node.BatchInterval,
+			TLSMode:        node.TLSMode,
+			TLSFingerprint: node.TLSFingerprint,
+			TLSCACert:      node.TLSCACert,
+		}
+		if encrypt {
+			plain, err := DecryptSecret(node.APIKey)
+			if err != nil {
+				return nil, fmt.Errorf("节点 %s 的 API 密钥解密失败: %v", node.Name, err)
+			}
+			item.APIKey = plain
+		}
+		list = append(list, item)
+	}
+
+	plain, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &NodeBundle{
+		Format:         NodeBundleFormat,
+		Version:        NodeBundleVersion,
+		CreatedAt:      time.Now(),
+		NodeCount:      len(list),
+		IncludeAPIKeys: encrypt,
+		Checksum:       bundleChecksum(plain),
+	}
+	if !encrypt {
+		bundle.Nodes = list
+		return bundle, nil
+	}
+
+	enc := &BundleEncryption{Cipher: "aes-256-gcm", KDF: "scrypt", N: 32768, R: 8, P: 1}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, enc.N, enc.R, enc.P, 64)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key[:32])
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	enc.Salt = base64.StdEncoding.EncodeToString(salt)
+	enc.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	bundle.Encryption = enc
+	bundle.Signature = bundleSignature(key[32:], bundle, plain)
+	bundle.Ciphertext = base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plain, bundleAAD(bundle)))
+	return bundle, nil
+}
+
+// ParsedBundle 解析并校验后的导出文件
+type ParsedBundle struct {
+	Nodes   []BundleNode
+	Version int
+	// 口令签名校验通过
+	Signed bool
+}
+
+// ParseNodeBundle 解析导出文件并校验版本、校验和与签名，加密的导出文件需要口令。
+// 旧版直接导出的节点数组按版本 0 处理，不做校验；版本 1 的加密文件没有签名，只由 AES-GCM 认证
+func ParseNodeBundle(raw json.RawMessage, passphrase string) (*ParsedBundle, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '[' {
+		var list []BundleNode
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, fmt.Errorf("节点数据格式错误: %v", err)
+		}
+		return &ParsedBundle{Nodes: list}, nil
+	}
+
+	var bundle NodeBundle
+	if err := json.Unmarshal(raw, &bundle); err != nil {
+		return nil, fmt.Errorf("导出文件格式错误: %v", err)
+	}
+	if bundle.Format != NodeBundleFormat {
+		return nil, errors.New("不是 lxdweb 节点导出文件")
+	}
+	if bundle.Version < 1 || bundle.Version > NodeBundleVersion {
+		return nil, fmt.Errorf("不支持的导出文件版本 %d，当前支持 1-%d", bundle.Version, NodeBundleVersion)
+	}
+
+	parsed := &ParsedBundle{Nodes: bundle.Nodes, Version: bundle.Version}
+	if bundle.Encryption != nil {
+		if passphrase == "" {
+			return nil, errors.New("导出文件已加密，请输入口令")
+		}
+		var err error
+		if parsed.Nodes, parsed.Signed, err = decryptBundle(&bundle, passphrase); err != nil {
+			return nil, err
+		}
+	}
+	if parsed.Nodes == nil {
+		parsed.Nodes = []BundleNode{}
+	}
+
+	plain, err := json.Marshal(parsed.Nodes)
+	if err != nil {
+		return nil, err
+	}
+	if bundleChecksum(plain) != bundle.Checksum {
+		return nil, errors.New("校验和不匹配，导出文件已被修改或损坏")
+	}
+	if len(parsed.Nodes) != bundle.NodeCount {
+		return nil, errors.New("节点数量与导出文件记录不一致")
+	}
+	return parsed, nil
+}
+
+// decryptBundle 解密节点列表，版本 2 起同时校验口令签名
+func decryptBundle(bundle *NodeBundle, passphrase string) ([]BundleNode, bool, error) {
+	enc := bundle.Encryption
+	if enc.Cipher != "aes-256-gcm" || enc.KDF != "scrypt" {
+		return nil, false, fmt.Errorf("不支持的加密方式: %s/%s", enc.Cipher, enc.KDF)
+	}
+	// 限制 scrypt 参数，防止构造的文件消耗过多内存（内存占用约为 128*N*r 字节）
+	if enc.N < 2 || enc.N > 1<<17 || enc.R < 1 || enc.R > 8 || enc.P < 1 || enc.P > 4 {
+		return nil, false, errors.New("导出文件的密钥派生参数无效")
+	}
+	signed := bundle.Version >= 2
+	if signed && bundle.Signature == "" {
+		return nil, false, errors.New("导出文件缺少签名")
+	}
+	salt, err1 := base64.StdEncoding.DecodeString(enc.Salt)
+	nonce, err2 := base64.StdEncoding.DecodeString(enc.Nonce)
+	sealed, err3 := base64.StdEncoding.DecodeString(bundle.Ciphertext)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, false, errors.New("导出文件格式错误")
+	}
+
+	keyLen := 32
+	if signed {
+		keyLen = 64
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, enc.N, enc.R, enc.P, keyLen)
+	if err != nil {
+		return nil, false, err
+	}
+	aead, err := newAEAD(key[:32])
+	if err != nil {
+		return nil, false, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, false, errors.New("导出文件格式错误")
+	}
+	plain, err := aead.Open(nil, nonce, sealed, bundleAAD(bundle))
+	if err != nil {
+		return nil, false, ErrBundlePassphrase
+	}
+	if signed && !hmac.Equal([]byte(bundleSignature(key[32:], bundle, plain)), []byte(bundle.Signature)) {
+		return nil, false, errors.New("签名校验失败，导出文件已被修改")
+	}
+
+	var list []BundleNode
+	if err := json.Unmarshal(plain, &list); err != nil {
+		return nil, false, fmt.Errorf("节点数据格式错误: %v", err)
+	}
+	return list, signed, nil
+}
+
+// bundleAAD 把文件头中的关键字段纳入认证，防止被篡改
+func bundleAAD(bundle *NodeBundle) []byte {
+	return []byte(fmt.Sprintf("%s|%d|%d|%t|%s", bundle.Format, bundle.Version, bundle.NodeCount, bundle.IncludeAPIKeys, bundle.Checksum))
+}
+
+// bundleSignature 以口令派生的密钥对文件头和节点列表计算 HMAC-SHA256
+func bundleSignature(key []byte, bundle *NodeBundle, plain []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(bundleAAD(bundle))
+	mac.Write([]byte{'\n'})
+	mac.Write(plain)
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func bundleChecksum(plain []byte) string {
+	sum := sha256.Sum256(plain)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ImportNodeBundle 按冲突策略生成导入计划，逐个请求 /api/check 验证后在一个事务中写入，
+// 验证失败的节点不会导入，未经过检查的节点只有 allowUnchecked 时才导入。dryRun 时只返回计划和检查结果
+func ImportNodeBundle(ctx context.Context, list []BundleNode, strategy string, dryRun, skipCheck, allowUnchecked bool) (*ImportResult, error) {
+	if strategy == "" {
+		strategy = ImportSkip
+	}
+	if !ValidImportStrategy(strategy) {
+		return nil, fmt.Errorf("不支持的冲突策略: %s", strategy)
+	}
+
+	result := &ImportResult{DryRun: dryRun, Items: make([]ImportItem, len(list))}
+	planned := make(map[string]bool, len(list))
+	for i, bn := range list {
+		result.Items[i] = planImport(bn, strategy, planned)
+	}
+
+	if !skipCheck {
+		checkImports(ctx, result.Items)
+	}
+	for i := range result.Items {
+		item := &result.Items[i]
+		if item.Action == "skip" || item.Action == "error" || item.Check == "ok" {
+			continue
+		}
+		if item.Check == "" {
+			item.Check, item.Message = "skipped", "已选择不验证节点连接"
+		}
+		result.UncheckedCount++
+		if !allowUnchecked {
+			item.Action = "error"
+			item.Message += "，未允许导入未验证的节点"
+		}
+	}
+
+	if !dryRun {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			for i := range result.Items {
+				if err := applyImport(tx, &result.Items[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, item := range result.Items {
+		switch item.Action {
+		case "skip":
+			result.SkippedCount++
+		case "error":
+			result.FailedCount++
+		default:
+			result.SuccessCount++
+			if !dryRun && item.Check == "ok" {
+				go RefreshNodeCache(item.node.ID)
+			}
+		}
+	}
+	return result, nil
+}
+
+// ValidImportStrategy 判断冲突策略是否有效，空值按 skip 处理
+func ValidImportStrategy(strategy string) bool {
+	switch strategy {
+	case "", ImportSkip, ImportOverwrite, ImportRename:
+		return true
+	}
+	return false
+}
+
+func planImport(bn BundleNode, strategy string, planned map[string]bool) ImportItem {
+	item := ImportItem{Name: bn.Name, TargetName: bn.Name, Action: "create"}
+	fail := func(msg string) ImportItem {
+		item.Action, item.Message = "error", msg
+		return item
+	}
+
+	bn.Name = strings.TrimSpace(bn.Name)
+	bn.Address = strings.TrimRight(strings.TrimSpace(bn.Address), "/")
+	if bn.Name == "" || bn.Address == "" {
+		return fail("缺少名称或地址")
+	}
+	mode, fp, err := NormalizeNodeTrust(bn.Address, bn.TLSMode, bn.TLSFingerprint, bn.TLSCACert)
+	if err != nil {
+		return fail("TLS 配置无效: " + err.Error())
+	}
+	apiKey, err := EncryptSecret(bn.APIKey)
+	if err != nil {
+		return fail("加密 API 密钥失败: " + err.Error())
+	}
+
+	item.node = models.Node{
+		Name:           bn.Name,
+		Description:    bn.Description,
+		Address:        bn.Address,
+		APIKey:         apiKey,
+		Status:         "inactive",
+		AutoSync:       bn.AutoSync,
+		SyncInterval:   positiveOr(bn.SyncInterval, 300),
+		BatchSize:      positiveOr(bn.BatchSize, 5),
+		BatchInterval:  positiveOr(bn.BatchInterval, 5),
+		TLSMode:        mode,
+		TLSFingerprint: fp,
+		TLSCACert:      bn.TLSCACert,
+	}
+
+	var existing models.Node
+	exists := database.DB.Unscoped().Where("name = ?", bn.Name).First(&existing).Error == nil
+	if !exists && !planned[bn.Name] {
+		planned[bn.Name] = true
+		return item
+	}
+
+	switch strategy {
+	case ImportSkip:
+		item.Action, item.Message = "skip", "节点名称已存在"
+	case ImportRename:
+		item.TargetName = uniqueNodeName(bn.Name, planned)
+		item.node.Name = item.TargetName
+		item.Action = "rename"
+		planned[item.TargetName] = true
+	case ImportOverwrite:
+		if !exists {
+			return fail("导入数据中节点名称重复")
+		}
+		if existing.DeletedAt.Valid {
+			return fail("同名节点已删除，无法覆盖，请使用重命名")
+		}
+		item.Action = "overwrite"
+		item.existing = &existing
+		item.node.ID = existing.ID
+		if bn.APIKey == "" {
+			// 导出文件未包含 API 密钥时沿用原有密钥
+			item.node.APIKey = existing.APIKey
+		}
+	}
+	return item
+}
+
+func uniqueNodeName(name string, planned map[string]bool) string {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", name, i)
+		if planned[candidate] {
+			continue
+		}
+		var count int64
+		database.DB.Unscoped().Model(&models.Node{}).Where("name = ?", candidate).Count(&count)
+		if count == 0 {
+			return candidate
+		}
+	}
+}
+
+// checkImports 并发请求待导入节点的 /api/check，未提供 API 密钥的节点跳过检查
+func checkImports(ctx context.Context, items []ImportItem) {
+	sem := make(chan struct{}, bundleCheckWorkers)
+	var wg sync.WaitGroup
+	for i := range items {
+		item := &items[i]
+		if item.Action == "skip" || item.Action == "error" {
+			continue
+		}
+		if item.node.APIKey == "" {
+			item.Check, item.Message = "skipped", "未提供 API 密钥，跳过连接检查"
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			checkCtx, cancel := context.WithTimeout(ctx, bundleCheckTimeout)
+			defer cancel()
+			trust, pinned, err := ProbeNode(checkCtx, item.node)
+			if err != nil {
+				item.Check, item.Action, item.Message = "failed", "error", "连接检查失败: "+err.Error()
+				return
+			}
+			item.Check = "ok"
+			item.node.Status = "active"
+			if pinned {
+				item.node.TLSFingerprint = trust.Fingerprint
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func applyImport(tx *gorm.DB, item *ImportItem) error {
+	node := &item.node
+	if item.Check == "ok" {
+		now := time.Now()
+		node.LastCheck = &now
+	}
+
+	switch item.Action {
+	case "create", "rename":
+		if err := tx.Create(node).Error; err != nil {
+			return fmt.Errorf("创建节点 %s 失败: %v", node.Name, err)
+		}
+	case "overwrite":
+		updates := map[string]interface{}{
+			"description":     node.Description,
+			"address":         node.Address,
+			"api_key":         node.APIKey,
+			"auto_sync":       node.AutoSync,
+			"sync_interval":   node.SyncInterval,
+			"batch_size":      node.BatchSize,
+			"batch_interval":  node.BatchInterval,
+			"tls_mode":        node.TLSMode,
+			"tls_fingerprint": node.TLSFingerprint,
+			"tls_ca_cert":     node.TLSCACert,
+		}
+		if item.Check == "ok" {
+			updates["status"] = node.Status
+			updates["last_check"] = node.LastCheck
+		}
+		if err := tx.Model(item.existing).Updates(updates).Error; err != nil {
+			return fmt.Errorf("覆盖节点 %s 失败: %v", node.Name, err)
+		}
+	}
+	return nil
+}
+
+func positiveOr(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
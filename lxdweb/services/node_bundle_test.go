@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"lxdweb/database"
+	"lxdweb/models"
+)
+
+func TestNodeBundleSignature(t *testing.T) {
+	nodes := []models.Node{{Name: "n1", Address: "https://10.0.0.1:8443", APIKey: "key-1", TLSMode: "pin"}}
+	const passphrase = "correct-horse"
+
+	signedBundle, err := ExportNodeBundle(nodes, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(signedBundle.Signature, "hmac-sha256:") {
+		t.Fatalf("signature = %q", signedBundle.Signature)
+	}
+	plainBundle, err := ExportNodeBundle(nodes, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plainBundle.Signature != "" {
+		t.Fatalf("unencrypted bundle signature = %q", plainBundle.Signature)
+	}
+
+	marshal := func(b NodeBundle) json.RawMessage {
+		raw, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	forged := *signedBundle
+	forged.Signature = "hmac-sha256:" + strings.Repeat("0", 64)
+	unsigned := *signedBundle
+	unsigned.Signature = ""
+
+	tests := []struct {
+		name       string
+		raw        json.RawMessage
+		passphrase string
+		wantSigned bool
+		wantErr    string
+	}{
+		{"加密并签名", marshal(*signedBundle), passphrase, true, ""},
+		{"未加密不签名", marshal(*plainBundle), "", false, ""},
+		{"口令错误", marshal(*signedBundle), "wrong-passphrase", false, "口令错误"},
+		{"签名被替换", marshal(forged), passphrase, false, "签名校验失败"},
+		{"签名被删除", marshal(unsigned), passphrase, false, "缺少签名"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseNodeBundle(tt.raw, tt.passphrase)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Signed != tt.wantSigned {
+				t.Fatalf("signed = %v, want %v", parsed.Signed, tt.wantSigned)
+			}
+			if len(parsed.Nodes) != 1 || parsed.Nodes[0].Name != "n1" {
+				t.Fatalf("nodes = %+v", parsed.Nodes)
+			}
+		})
+	}
+}
+
+func TestImportNodeBundleUnchecked(t *testing.T) {
+	list := []BundleNode{{Name: "n1", Address: "https://10.0.0.1:8443"}}
+
+	tests := []struct {
+		name           string
+		skipCheck      bool
+		allowUnchecked bool
+		wantAction     string
+		wantNodes      int64
+	}{
+		{"缺少 API 密钥且未允许", false, false, "error", 0},
+		{"缺少 API 密钥但已允许", false, true, "create", 1},
+		{"不验证连接且未允许", true, false, "error", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestDB(t)
+			result, err := ImportNodeBundle(context.Background(), list, ImportSkip, false, tt.skipCheck, tt.allowUnchecked)
+			if err != nil {
+				t.Fatal(err)
+			}
+			item := result.Items[0]
+			if item.Action != tt.wantAction || item.Check != "skipped" || result.UncheckedCount != 1 {
+				t.Fatalf("action = %s, check = %s, unchecked = %d", item.Action, item.Check, result.UncheckedCount)
+			}
+			var count int64
+			database.DB.Model(&models.Node{}).Count(&count)
+			if count != tt.wantNodes {
+				t.Fatalf("nodes in db = %d, want %d", count, tt.wantNodes)
+			}
+		})
+	}
+}
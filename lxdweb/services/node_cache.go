@@ -1,12 +1,12 @@
 package services
 
 import (
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"log"
 	"lxdweb/database"
 	"lxdweb/models"
-	"net/http"
+	"lxdweb/pkg/lxdapi"
 	"sync"
 	"time"
 	
@@ -57,41 +57,12 @@ func refreshAllNodeCache() {
 }
 
 func cacheNodeInfo(node models.Node) {
-	client := &http.Client{
-		Timeout: 8 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	}
+	ctx, cancel := context.WithTimeout(lxdapi.WithClass(context.Background(), lxdapi.ClassCache), 8*time.Second)
+	defer cancel()
 	
-	req, err := http.NewRequest("GET", node.Address+"/", nil)
+	sysInfo, err := NodeClient(node).SystemInfo(ctx)
 	if err != nil {
-		log.Printf("[NODE-CACHE] 节点 %s 创建请求失败: %v", node.Name, err)
-		clearNodeCache(node.ID)
-		return
-	}
-	
-	if node.APIKey != "" {
-		req.Header.Set("apikey", node.APIKey)
-	}
-	
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("[NODE-CACHE] 节点 %s 连接失败: %v", node.Name, err)
-		clearNodeCache(node.ID)
-		return
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != 200 {
-		log.Printf("[NODE-CACHE] 节点 %s 返回状态码: %d", node.Name, resp.StatusCode)
-		clearNodeCache(node.ID)
-		return
-	}
-	
-	var sysInfo map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&sysInfo); err != nil {
-		log.Printf("[NODE-CACHE] 节点 %s 解析响应失败: %v", node.Name, err)
+		log.Printf("[NODE-CACHE] 节点 %s 获取系统信息失败: %v", node.Name, err)
 		clearNodeCache(node.ID)
 		return
 	}
@@ -120,8 +91,9 @@ func cacheNodeInfo(node models.Node) {
 	}
 }
 
+// clearNodeCache 清空系统信息，保留熔断状态
 func clearNodeCache(nodeID uint) {
-	database.DB.Unscoped().Where("node_id = ?", nodeID).Delete(&models.NodeInfoCache{})
+	database.DB.Model(&models.NodeInfoCache{}).Where("node_id = ?", nodeID).Update("system_info", "")
 }
 
 func GetNodeCache(nodeID uint) (map[string]interface{}, error) {
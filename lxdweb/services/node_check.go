@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"lxdweb/database"
+	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+)
+
+// NormalizeNodeTrust 校验节点 TLS 信任配置，返回规范化后的模式和指纹。
+// http 地址不使用 TLS，不能配置证书指纹、CA 或系统根证书，信任模式保存为空
+func NormalizeNodeTrust(address, mode, fingerprint, caCert string) (string, string, error) {
+	if IsPlainHTTP(address) {
+		if (mode != "" && mode != lxdapi.TrustPin) || strings.TrimSpace(fingerprint) != "" {
+			return "", "", errors.New("http 地址不使用 TLS，无法校验证书，请改用 https 地址")
+		}
+		return "", "", nil
+	}
+	if mode == "" {
+		mode = lxdapi.TrustPin
+	}
+	if !lxdapi.ValidTrustMode(mode) {
+		return "", "", fmt.Errorf("不支持的 TLS 信任模式: %s", mode)
+	}
+	fp, err := lxdapi.NormalizeFingerprint(fingerprint)
+	if err != nil {
+		return "", "", fmt.Errorf("证书指纹无效: %v", err)
+	}
+	if mode == lxdapi.TrustCA {
+		if _, err := lxdapi.ParseCABundle(caCert); err != nil {
+			return "", "", err
+		}
+	}
+	return mode, fp, nil
+}
+
+// IsPlainHTTP 判断节点地址是否为未加密的 http
+func IsPlainHTTP(address string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(address)), "http://")
+}
+
+// NodePinPending 判断节点是否为 pin 模式且尚未确认证书指纹的 https 节点，这类节点只能通过
+// 测试连接、添加或导入节点首次信任证书，后台同步、健康检查和控制台都会拒绝连接
+func NodePinPending(node models.Node) bool {
+	return NodeTrust(node).Mode == lxdapi.TrustPin && node.TLSFingerprint == "" && strings.HasPrefix(node.Address, "https://")
+}
+
+// PinNodeFingerprint 添加节点时获取并保存 pin 模式节点的证书指纹（TOFU），已有指纹或无需固定时不做处理
+func PinNodeFingerprint(ctx context.Context, node *models.Node) (bool, error) {
+	if !NodePinPending(*node) {
+		return false, nil
+	}
+	fingerprint, err := lxdapi.FetchFingerprint(ctx, node.Address)
+	if err != nil {
+		return false, err
+	}
+	result := database.DB.Model(&models.Node{}).
+		Where("id = ? AND (tls_fingerprint = '' OR tls_fingerprint IS NULL)", node.ID).
+		Update("tls_fingerprint", fingerprint)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	node.TLSFingerprint = fingerprint
+	return true, nil
+}
+
+// ProbeNode 请求节点的 /api/check 验证地址、证书和 API 密钥，不修改数据库。pin 模式下尚未固定指纹时，
+// 首次连接即信任当前证书（TOFU），只用于测试连接和导入节点，pinned 表示返回的 trust 中带有本次获取的指纹
+func ProbeNode(ctx context.Context, node models.Node) (trust lxdapi.TrustConfig, pinned bool, err error) {
+	trust = NodeTrust(node)
+	if NodePinPending(node) {
+		fingerprint, err := lxdapi.FetchFingerprint(ctx, node.Address)
+		if err != nil {
+			return trust, false, err
+		}
+		trust.Fingerprint = fingerprint
+		pinned = true
+	}
+
+	if err := NodeClientWithTrust(node, trust).Check(ctx); err != nil {
+		return trust, false, err
+	}
+	return trust, pinned, nil
+}
+
+// TestNodeConnection 测试节点连接并更新节点状态，首次连接固定的指纹会保存到节点
+func TestNodeConnection(ctx context.Context, node models.Node) (trust lxdapi.TrustConfig, pinned bool, err error) {
+	trust, pinned, err = ProbeNode(ctx, node)
+	if err != nil {
+		UpdateNodeStatus(node.ID, "error")
+		return trust, false, err
+	}
+
+	if pinned {
+		database.DB.Model(&models.Node{}).Where("id = ?", node.ID).Update("tls_fingerprint", trust.Fingerprint)
+	}
+	UpdateNodeStatus(node.ID, "active")
+	ResetNodeCircuit(node.ID)
+	ResetNodeHealth(node.ID)
+	return trust, pinned, nil
+}
+
+// UpdateNodeStatus 更新节点状态和最近检查时间
+func UpdateNodeStatus(nodeID uint, status string) {
+	database.DB.Model(&models.Node{}).Where("id = ?", nodeID).Updates(map[string]interface{}{
+		"status":     status,
+		"last_check": time.Now(),
+	})
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+)
+
+func TestNormalizeNodeTrust(t *testing.T) {
+	fp := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name     string
+		address  string
+		mode     string
+		fp       string
+		wantMode string
+		wantErr  bool
+	}{
+		{"https 默认 pin", "https://10.0.0.1:8443", "", "", lxdapi.TrustPin, false},
+		{"https 带指纹", "https://10.0.0.1:8443", "pin", strings.ToUpper(fp), lxdapi.TrustPin, false},
+		{"https system", "https://node.example.com", "system", "", lxdapi.TrustSystem, false},
+		{"https ca 缺少证书", "https://10.0.0.1:8443", "ca", "", "", true},
+		{"http 不设置信任模式", "http://10.0.0.1:8080", "", "", "", false},
+		{"http 旧节点的 pin", "HTTP://10.0.0.1:8080", "pin", "", "", false},
+		{"http 带指纹", "http://10.0.0.1:8080", "pin", fp, "", true},
+		{"http ca", "http://10.0.0.1:8080", "ca", "", "", true},
+		{"http system", "http://10.0.0.1:8080", "system", "", "", true},
+		{"无效模式", "https://10.0.0.1:8443", "insecure", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, _, err := NormalizeNodeTrust(tt.address, tt.mode, tt.fp, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if mode != tt.wantMode {
+				t.Fatalf("mode = %q, want %q", mode, tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestNodeClientRefusesUnpinnedNode(t *testing.T) {
+	node := models.Node{ID: 1, Name: "n1", Address: "https://127.0.0.1:1", TLSMode: lxdapi.TrustPin}
+	if !NodePinPending(node) {
+		t.Fatal("expected pin pending")
+	}
+	err := NodeClient(node).Check(context.Background())
+	if !errors.Is(err, lxdapi.ErrFingerprintNotPinned) {
+		t.Fatalf("error = %v, want ErrFingerprintNotPinned", err)
+	}
+	if _, ok := NodeLimiterStats(node.ID); ok {
+		t.Fatal("refused client should not open a connection pool")
+	}
+
+	for _, n := range []models.Node{
+		{Address: "https://127.0.0.1:1", TLSMode: lxdapi.TrustPin, TLSFingerprint: strings.Repeat("ab", 32)},
+		{Address: "https://127.0.0.1:1", TLSMode: lxdapi.TrustSystem},
+		{Address: "http://127.0.0.1:1", TLSMode: lxdapi.TrustPin},
+	} {
+		if NodePinPending(n) {
+			t.Fatalf("%s %s should not be pin pending", n.Address, n.TLSMode)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"lxdweb/database"
+	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+
+	"gorm.io/gorm/clause"
+)
+
+func newNodeBreaker(nodeID uint) *lxdapi.Breaker {
+	return lxdapi.NewBreaker(lxdapi.BreakerOptions{
+		Threshold: getNodeBreakerThreshold(),
+		Cooldown:  time.Duration(getNodeBreakerCooldown()) * time.Second,
+		OnStateChange: func(from, to lxdapi.BreakerState, snap lxdapi.BreakerSnapshot) {
+			onNodeCircuitChange(nodeID, from, to, snap)
+		},
+	})
+}
+
+// onNodeCircuitChange 熔断时立即将节点标记为 error，恢复由健康检查按连续成功次数判定
+func onNodeCircuitChange(nodeID uint, from, to lxdapi.BreakerState, snap lxdapi.BreakerSnapshot) {
+	log.Printf("[CIRCUIT] 节点 %d 熔断状态 %s -> %s (连续失败 %d 次) %s", nodeID, from, to, snap.Failures, snap.LastError)
+
+	saveNodeCircuit(nodeID, snap)
+
+	if to == lxdapi.BreakerOpen {
+		database.DB.Model(&models.Node{}).Where("id = ? AND status IN ?", nodeID, []string{"active", "degraded"}).Updates(map[string]interface{}{
+			"status":     "error",
+			"last_check": time.Now(),
+		})
+	}
+}
+
+func saveNodeCircuit(nodeID uint, snap lxdapi.BreakerSnapshot) {
+	cache := models.NodeInfoCache{
+		NodeID:           nodeID,
+		CircuitState:     string(snap.State),
+		CircuitFailures:  snap.Failures,
+		CircuitOpenedAt:  snap.OpenedAt,
+		CircuitLastError: snap.LastError,
+	}
+	result := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "node_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"circuit_state", "circuit_failures", "circuit_opened_at", "circuit_last_error"}),
+	}).Create(&cache)
+	if result.Error != nil {
+		log.Printf("[CIRCUIT] 节点 %d 保存熔断状态失败: %v", nodeID, result.Error)
+	}
+}
+
+// NodeCircuit 返回节点熔断器的实时状态，节点尚未发起过请求时返回 false
+func NodeCircuit(nodeID uint) (lxdapi.BreakerSnapshot, bool) {
+	nodeConnsMu.Lock()
+	conn := nodeConns[nodeID]
+	nodeConnsMu.Unlock()
+	if conn == nil || conn.breaker == nil {
+		return lxdapi.BreakerSnapshot{}, false
+	}
+	return conn.breaker.Snapshot(), true
+}
+
+// NodeCircuitOpen 判断节点当前是否处于熔断状态
+func NodeCircuitOpen(nodeID uint) bool {
+	snap, ok := NodeCircuit(nodeID)
+	return ok && snap.State == lxdapi.BreakerOpen
+}
+
+// ResetNodeCircuit 手动测试连接成功后恢复熔断器
+func ResetNodeCircuit(nodeID uint) {
+	nodeConnsMu.Lock()
+	conn := nodeConns[nodeID]
+	nodeConnsMu.Unlock()
+	if conn != nil && conn.breaker != nil {
+		conn.breaker.Reset()
+	}
+}
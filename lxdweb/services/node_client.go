@@ -0,0 +1,200 @@
+package services
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"lxdweb/config"
+	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+)
+
+const nodeRequestTimeout = 30 * time.Second
+
+// nodeConn 节点共享的连接池与并发额度
+type nodeConn struct {
+	key     string
+	client  *http.Client
+	pool    *http.Transport
+	limiter *lxdapi.Limiter
+	breaker *lxdapi.Breaker
+}
+
+var (
+	nodeConnsMu sync.Mutex
+	nodeConns   = make(map[uint]*nodeConn)
+)
+
+// NodeTrust 返回节点的 TLS 信任配置
+func NodeTrust(node models.Node) lxdapi.TrustConfig {
+	mode := node.TLSMode
+	if mode == "" {
+		mode = lxdapi.TrustPin
+	}
+	return lxdapi.TrustConfig{
+		Mode:        mode,
+		Fingerprint: node.TLSFingerprint,
+		CACertPEM:   node.TLSCACert,
+	}
+}
+
+// refusingTransport 直接返回错误，不发起连接
+type refusingTransport struct {
+	err error
+}
+
+func (t refusingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, t.err
+}
+
+// NodeClient 返回访问指定节点 lxdapi 的客户端，同一节点的客户端共用连接池和并发额度。
+// pin 模式下尚未确认证书指纹的 https 节点直接拒绝请求，指纹只在测试连接、添加和导入节点时获取
+func NodeClient(node models.Node) *lxdapi.Client {
+	if NodePinPending(node) {
+		return lxdapi.NewClient(node.Address, NodeAPIKey(node), &http.Client{
+			Transport: refusingTransport{err: lxdapi.ErrFingerprintNotPinned},
+		})
+	}
+	key := nodeConnKey(node.Address, NodeTrust(node))
+
+	nodeConnsMu.Lock()
+	conn := nodeConns[node.ID]
+	if conn == nil || conn.key != key {
+		var limiter *lxdapi.Limiter
+		var breaker *lxdapi.Breaker
+		if conn != nil {
+			// 地址或证书配置变更，旧连接不再复用，并发额度和熔断状态沿用
+			conn.pool.CloseIdleConnections()
+			limiter, breaker = conn.limiter, conn.breaker
+		} else {
+			limiter, breaker = newNodeLimiter(), newNodeBreaker(node.ID)
+		}
+		conn = newNodeConn(key, NodeTrust(node), limiter, breaker)
+		nodeConns[node.ID] = conn
+	}
+	nodeConnsMu.Unlock()
+
+	return lxdapi.NewClient(node.Address, NodeAPIKey(node), conn.client)
+}
+
+// NodeClientWithTrust 使用指定的信任配置创建客户端，供测试连接和首次信任时验证新指纹。
+// 该客户端不经过熔断器，节点熔断期间仍可手动测试
+func NodeClientWithTrust(node models.Node, trust lxdapi.TrustConfig) *lxdapi.Client {
+	nodeConnsMu.Lock()
+	var limiter *lxdapi.Limiter
+	if conn := nodeConns[node.ID]; conn != nil {
+		limiter = conn.limiter
+	} else {
+		limiter = newNodeLimiter()
+	}
+	nodeConnsMu.Unlock()
+
+	conn := newNodeConn("", trust, limiter, nil)
+	conn.pool.DisableKeepAlives = true
+	return lxdapi.NewClient(node.Address, NodeAPIKey(node), conn.client)
+}
+
+// ReleaseNodeClient 关闭节点的空闲连接并移除连接池，节点删除时调用
+func ReleaseNodeClient(nodeID uint) {
+	nodeConnsMu.Lock()
+	defer nodeConnsMu.Unlock()
+	if conn := nodeConns[nodeID]; conn != nil {
+		conn.pool.CloseIdleConnections()
+		delete(nodeConns, nodeID)
+	}
+}
+
+// NodeLimiterStats 返回节点当前的并发使用情况
+func NodeLimiterStats(nodeID uint) (lxdapi.LimiterStats, bool) {
+	nodeConnsMu.Lock()
+	conn := nodeConns[nodeID]
+	nodeConnsMu.Unlock()
+	if conn == nil {
+		return lxdapi.LimiterStats{}, false
+	}
+	return conn.limiter.Stats(), true
+}
+
+// newNodeConn 组装传输链：重试 -> 熔断 -> 并发额度 -> 连接池，breaker 为 nil 时不熔断也不重试
+func newNodeConn(key string, trust lxdapi.TrustConfig, limiter *lxdapi.Limiter, breaker *lxdapi.Breaker) *nodeConn {
+	pool := lxdapi.NewTransport(trust, lxdapi.TransportOptions{
+		MaxConns:        getNodeMaxConcurrent(),
+		IdleConnTimeout: time.Duration(getNodeIdleConnTimeout()) * time.Second,
+	})
+	transport := lxdapi.NewLimitedTransport(pool, limiter)
+	if breaker != nil {
+		transport = lxdapi.NewBreakerTransport(transport, breaker)
+		transport = lxdapi.NewRetryTransport(transport, getNodeRetryPolicy())
+	}
+	return &nodeConn{
+		key: key,
+		client: &http.Client{
+			Timeout:   nodeRequestTimeout,
+			Transport: transport,
+		},
+		pool:    pool,
+		limiter: limiter,
+		breaker: breaker,
+	}
+}
+
+func newNodeLimiter() *lxdapi.Limiter {
+	return lxdapi.NewLimiter(getNodeMaxConcurrent(), getNodeInteractiveReserved())
+}
+
+func nodeConnKey(address string, trust lxdapi.TrustConfig) string {
+	return strings.Join([]string{address, trust.Mode, trust.Fingerprint, trust.CACertPEM}, "\x00")
+}
+
+func getNodeMaxConcurrent() int {
+	if config.AppConfig != nil && config.AppConfig.Node.MaxConcurrent > 0 {
+		return config.AppConfig.Node.MaxConcurrent
+	}
+	return 8
+}
+
+func getNodeInteractiveReserved() int {
+	if config.AppConfig != nil && config.AppConfig.Node.InteractiveReserved > 0 {
+		return config.AppConfig.Node.InteractiveReserved
+	}
+	return 2
+}
+
+func getNodeIdleConnTimeout() int {
+	if config.AppConfig != nil && config.AppConfig.Node.IdleConnTimeout > 0 {
+		return config.AppConfig.Node.IdleConnTimeout
+	}
+	return 90
+}
+
+func getNodeBreakerThreshold() int {
+	if config.AppConfig != nil && config.AppConfig.Node.BreakerThreshold > 0 {
+		return config.AppConfig.Node.BreakerThreshold
+	}
+	return 5
+}
+
+func getNodeBreakerCooldown() int {
+	if config.AppConfig != nil && config.AppConfig.Node.BreakerCooldown > 0 {
+		return config.AppConfig.Node.BreakerCooldown
+	}
+	return 60
+}
+
+func getNodeRetryPolicy() lxdapi.RetryPolicy {
+	policy := lxdapi.RetryPolicy{Attempts: 3, Backoff: 500 * time.Millisecond}
+	if config.AppConfig != nil {
+		if config.AppConfig.Node.RetryAttempts > 0 {
+			policy.Attempts = config.AppConfig.Node.RetryAttempts
+		}
+		if config.AppConfig.Node.RetryBackoff > 0 {
+			policy.Backoff = time.Duration(config.AppConfig.Node.RetryBackoff) * time.Millisecond
+		}
+	}
+	return policy
+}
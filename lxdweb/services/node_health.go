@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"lxdweb/config"
+	"lxdweb/database"
+	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+)
+
+// healthCounter 记录节点连续探测结果，用于状态切换的滞回判断
+type healthCounter struct {
+	failures  int // 连续失败
+	successes int // 连续成功（含响应慢）
+	fast      int // 连续成功且响应正常
+	bad       int // 连续失败或响应慢
+}
+
+var (
+	healthMu       sync.Mutex
+	healthCounters = make(map[uint]*healthCounter)
+)
+
+func StartNodeHealthService() {
+	log.Println("[HEALTH] 节点健康检查服务启动")
+
+	interval := time.Duration(getHealthConfig().Interval) * time.Second
+	go func() {
+		checkAllNodesHealth()
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			checkAllNodesHealth()
+		}
+	}()
+
+	go func() {
+		pruneHealthHistory()
+		ticker := time.NewTicker(1 * time.Hour)
+		for range ticker.C {
+			pruneHealthHistory()
+		}
+	}()
+}
+
+func checkAllNodesHealth() {
+	var nodes []models.Node
+	database.DB.Find(&nodes)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 5)
+
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(n models.Node) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			CheckNodeHealth(n)
+		}(node)
+	}
+
+	wg.Wait()
+}
+
+// CheckNodeHealth 探测一次节点 /api/check，记录结果并按滞回规则更新节点状态
+func CheckNodeHealth(node models.Node) string {
+	cfg := getHealthConfig()
+
+	ctx, cancel := context.WithTimeout(lxdapi.WithClass(context.Background(), lxdapi.ClassCache), time.Duration(cfg.Timeout)*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := probeNodeHealth(ctx, node)
+	latency := time.Since(start).Milliseconds()
+
+	ok := err == nil
+	slow := ok && latency > int64(cfg.DegradedLatency)
+
+	healthMu.Lock()
+	counter := healthCounters[node.ID]
+	if counter == nil {
+		counter = &healthCounter{}
+		healthCounters[node.ID] = counter
+	}
+	status := nextHealthStatus(node.Status, counter, ok, slow, cfg)
+	healthMu.Unlock()
+
+	// 状态切换时计数器会清零，fail_count 按节点记录累加，保持连续失败次数
+	failures := 0
+	if !ok {
+		failures = node.FailCount + 1
+	}
+
+	now := time.Now()
+	record := models.NodeHealthCheck{
+		NodeID:    node.ID,
+		Status:    status,
+		Success:   ok,
+		LatencyMs: latency,
+		CheckedAt: now,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	database.DB.Create(&record)
+
+	updates := map[string]interface{}{
+		"last_check": now,
+		"fail_count": failures,
+	}
+	if ok {
+		updates["latency_ms"] = latency
+	}
+	if status != node.Status {
+		updates["status"] = status
+		log.Printf("[HEALTH] 节点 %s 状态 %s -> %s (延迟 %dms, 连续失败 %d 次)", node.Name, node.Status, status, latency, failures)
+	}
+	database.DB.Model(&models.Node{}).Where("id = ?", node.ID).Updates(updates)
+
+	return status
+}
+
+// probeNodeHealth 请求节点 /api/check。健康检查不做首次信任，尚未确认证书指纹的节点直接记为失败
+func probeNodeHealth(ctx context.Context, node models.Node) error {
+	return NodeClient(node).Check(ctx)
+}
+
+// nextHealthStatus 根据本次探测结果计算节点状态，连续计数只在当前状态内累计，状态切换后清零。
+// 连续失败 FailThreshold 次进入 error；active 连续 RecoverThreshold 次失败或响应慢进入 degraded；
+// degraded、error 需连续 RecoverThreshold 次成功才恢复，避免状态来回抖动。
+// 尚未检查过的节点首次探测成功即视为上线，新节点无需手动测试即可开始自动同步。
+func nextHealthStatus(current string, c *healthCounter, ok, slow bool, cfg config.HealthConfig) string {
+	next := evalHealthStatus(current, c, ok, slow, cfg)
+	if next != current {
+		// 状态切换后重新累计，上一状态的连续计数不带入新状态
+		*c = healthCounter{}
+	}
+	return next
+}
+
+func evalHealthStatus(current string, c *healthCounter, ok, slow bool, cfg config.HealthConfig) string {
+	switch {
+	case !ok:
+		c.failures++
+		c.successes, c.fast = 0, 0
+		c.bad++
+	case slow:
+		c.failures, c.fast = 0, 0
+		c.successes++
+		c.bad++
+	default:
+		c.failures, c.bad = 0, 0
+		c.successes++
+		c.fast++
+	}
+
+	recovered := "active"
+	if slow {
+		recovered = "degraded"
+	}
+
+	switch current {
+	case "active":
+		if c.failures >= cfg.FailThreshold {
+			return "error"
+		}
+		if c.bad >= cfg.RecoverThreshold {
+			return "degraded"
+		}
+	case "degraded":
+		if c.failures >= cfg.FailThreshold {
+			return "error"
+		}
+		if c.fast >= cfg.RecoverThreshold {
+			return "active"
+		}
+	case "error":
+		if c.successes >= cfg.RecoverThreshold {
+			return recovered
+		}
+	default:
+		if ok {
+			return recovered
+		}
+		if c.failures >= cfg.FailThreshold {
+			return "error"
+		}
+	}
+	return current
+}
+
+// ResetNodeHealth 手动测试连接后清空计数，从测试结果重新开始判断
+func ResetNodeHealth(nodeID uint) {
+	healthMu.Lock()
+	delete(healthCounters, nodeID)
+	healthMu.Unlock()
+}
+
+func pruneHealthHistory() {
+	cutoff := time.Now().AddDate(0, 0, -getHealthConfig().Retention)
+	result := database.DB.Where("checked_at < ?", cutoff).Delete(&models.NodeHealthCheck{})
+	if result.Error != nil {
+		log.Printf("[HEALTH] 清理健康记录失败: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("[HEALTH] 清理 %d 条过期健康记录", result.RowsAffected)
+	}
+}
+
+func getHealthConfig() config.HealthConfig {
+	cfg := config.HealthConfig{
+		Interval:         30,
+		Timeout:          5,
+		DegradedLatency:  1500,
+		FailThreshold:    3,
+		RecoverThreshold: 2,
+		Retention:        7,
+	}
+	if config.AppConfig == nil {
+		return cfg
+	}
+	h := config.AppConfig.Health
+	if h.Interval > 0 {
+		cfg.Interval = h.Interval
+	}
+	if h.Timeout > 0 {
+		cfg.Timeout = h.Timeout
+	}
+	if h.DegradedLatency > 0 {
+		cfg.DegradedLatency = h.DegradedLatency
+	}
+	if h.FailThreshold > 0 {
+		cfg.FailThreshold = h.FailThreshold
+	}
+	if h.RecoverThreshold > 0 {
+		cfg.RecoverThreshold = h.RecoverThreshold
+	}
+	if h.Retention > 0 {
+		cfg.Retention = h.Retention
+	}
+	return cfg
+}
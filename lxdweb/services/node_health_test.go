@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+
+	"lxdweb/config"
+)
+
+func TestNextHealthStatus(t *testing.T) {
+	cfg := config.HealthConfig{FailThreshold: 3, RecoverThreshold: 2}
+
+	type probe struct {
+		ok, slow bool
+		want     string
+	}
+	var (
+		fast = func(want string) probe { return probe{ok: true, want: want} }
+		slow = func(want string) probe { return probe{ok: true, slow: true, want: want} }
+		fail = func(want string) probe { return probe{want: want} }
+	)
+
+	tests := []struct {
+		name   string
+		start  string
+		probes []probe
+	}{
+		{"新节点首次成功上线", "inactive", []probe{fast("active")}},
+		{"新节点连续失败", "inactive", []probe{fail("inactive"), fail("inactive"), fail("error")}},
+		{"active -> degraded -> error -> active", "active", []probe{
+			fail("active"), fail("degraded"),
+			// 进入 degraded 后重新计数，之前的两次失败不计入
+			fail("degraded"), fail("degraded"), fail("error"),
+			fast("error"), fast("active"),
+			// 恢复后之前的成功次数不带入，一次失败不会立刻降级
+			fail("active"), fast("active"),
+		}},
+		{"响应慢降级后恢复", "active", []probe{
+			slow("active"), slow("degraded"),
+			fast("degraded"), slow("degraded"), fast("degraded"), fast("active"),
+		}},
+		{"error 以响应慢恢复为 degraded", "error", []probe{
+			slow("error"), slow("degraded"), fast("degraded"), fast("active"),
+		}},
+		{"error 中的成功被失败打断", "error", []probe{
+			fast("error"), fail("error"), fast("error"), fast("active"),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := tt.start
+			counter := &healthCounter{}
+			for i, p := range tt.probes {
+				next := nextHealthStatus(status, counter, p.ok, p.slow, cfg)
+				if next != p.want {
+					t.Fatalf("probe %d: %s -> %s, want %s", i, status, next, p.want)
+				}
+				if next != status && *counter != (healthCounter{}) {
+					t.Fatalf("probe %d: counters not reset on %s -> %s: %+v", i, status, next, *counter)
+				}
+				status = next
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://idp.example.com"
+	testClientID = "lxdweb"
+	testNonce    = "nonce-123"
+)
+
+type testSigner struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testSigner{rsa: rsaKey, ec: ecKey}
+}
+
+func testClaims(mutate func(map[string]interface{})) map[string]interface{} {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":   testIssuer,
+		"aud":   testClientID,
+		"sub":   "user-1",
+		"nonce": testNonce,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+	}
+	if mutate != nil {
+		mutate(claims)
+	}
+	return claims
+}
+
+func jwtSegment(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// sign 按 alg 生成 JWT，none 和 HS256 用于模拟攻击者构造的令牌
+func (s *testSigner) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	signed := jwtSegment(t, map[string]string{"alg": alg, "kid": kid}) + "." + jwtSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	var err error
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, s.rsa, crypto.SHA256, digest[:])
+	case "PS256":
+		sig, err = rsa.SignPSS(rand.Reader, s.rsa, crypto.SHA256, digest[:], nil)
+	case "ES256":
+		r, ss, signErr := ecdsa.Sign(rand.Reader, s.ec, digest[:])
+		err = signErr
+		if err == nil {
+			sig = make([]byte, 64)
+			r.FillBytes(sig[:32])
+			ss.FillBytes(sig[32:])
+		}
+	case "HS256":
+		// 以公钥作为 HMAC 密钥，经典的算法混淆攻击
+		pub, _ := x509.MarshalPKIXPublicKey(&s.rsa.PublicKey)
+		mac := hmac.New(sha256.New, pub)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case "none":
+	default:
+		t.Fatalf("unsupported alg %s", alg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func testProvider(s *testSigner) *oidcProvider {
+	return &oidcProvider{
+		keys: map[string]crypto.PublicKey{
+			"rsa": &s.rsa.PublicKey,
+			"ec":  &s.ec.PublicKey,
+		},
+		keysFetched: time.Now(),
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	signer := newTestSigner(t)
+	attacker := newTestSigner(t)
+	provider := testProvider(signer)
+
+	valid := signer.sign(t, "RS256", "rsa", testClaims(nil))
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + jwtSegment(t, testClaims(func(c map[string]interface{}) { c["sub"] = "admin" })) + "." + parts[2]
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{"RS256 有效", valid, ""},
+		{"PS256 有效", signer.sign(t, "PS256", "rsa", testClaims(nil)), ""},
+		{"ES256 有效", signer.sign(t, "ES256", "ec", testClaims(nil)), ""},
+		{"多个 audience 且 azp 正确", signer.sign(t, "RS256", "rsa", testClaims(func(c map[string]interface{}) {
+			c["aud"] = []string{"other", testClientID}
+			c["azp"] = testClientID
+		})), ""},
+		{"其他密钥伪造签名", attacker.sign(t, "RS256", "rsa", testClaims(nil)), "签名校验失败"},
+		{"篡改内容", tampered, "签名校验失败"},
+		{"alg none", signer.sign(t, "none", "rsa", testClaims(nil)), "不支持的签名算法"},
+		{"alg HS256", signer.sign(t, "HS256", "rsa", testClaims(nil)), "不支持的签名算法"},
+		{"算法与公钥类型不匹配", signer.sign(t, "RS256", "ec", testClaims(nil)), "不匹配"},
+		{"未知 kid", signer.sign(t, "RS256", "unknown", testClaims(nil)), "未找到签名公钥"},
+		{"issuer 错误", signer.sign(t, "RS256", "rsa", testClaims(func(c map[string]interface{}) {
+			c["iss"] = "https://evil.example.com"
+		})), "issuer"},
+		{"audience 错误", signer.sign(t, "RS256", "rsa", testClaims(func(c map[string]interface{}) {
+			c["aud"] = "other-client"
+		})), "audience"},
+		{"azp 错误", signer.sign(t, "RS256", "rsa", testClaims(func(c map[string]interface{}) {
+			c["aud"] = []string{"other", testClientID}
+			c["azp"] = "other"
+		})), "azp"},
+		{"nonce 错误", signer.sign(t, "RS256", "rsa", testClaims(func(c map[string]interface{}) {
+			c["nonce"] = "replayed"
+		})), "nonce"},
+		{"缺少 nonce", signer.sign(t, "RS256", "rsa", testClaims(func(c map[string]interface{}) {
+			delete(c, "nonce")
+		})), "nonce"},
+		{"已过期", signer.sign(t, "RS256", "rsa", testClaims(func(c map[string]interface{}) {
+			c["exp"] = time.Now().Add(-oidcClockSkew - time.Minute).Unix()
+		})), "已过期"},
+		{"缺少 exp", signer.sign(t, "RS256", "rsa", testClaims(func(c map[string]interface{}) {
+			delete(c, "exp")
+		})), "已过期"},
+		{"签发时间在未来", signer.sign(t, "RS256", "rsa", testClaims(func(c map[string]interface{}) {
+			c["iat"] = time.Now().Add(oidcClockSkew + time.Hour).Unix()
+		})), "签发时间无效"},
+		{"格式错误", "a.b", "格式错误"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := provider.verifyIDToken(context.Background(), tt.token, testIssuer, testClientID, testNonce)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if claims["sub"] != "user-1" {
+					t.Fatalf("sub = %v", claims["sub"])
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q does not contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyJWTSignature(t *testing.T) {
+	signer := newTestSigner(t)
+	signed := []byte("header.payload")
+	digest := sha256.Sum256(signed)
+	rsaSig, err := rsa.SignPKCS1v15(rand.Reader, signer.rsa, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		alg     string
+		key     crypto.PublicKey
+		sig     []byte
+		wantErr bool
+	}{
+		{"RS256 有效", "RS256", &signer.rsa.PublicKey, rsaSig, false},
+		{"RS384 摘要不一致", "RS384", &signer.rsa.PublicKey, rsaSig, true},
+		{"none", "none", &signer.rsa.PublicKey, nil, true},
+		{"HS256", "HS256", &signer.rsa.PublicKey, rsaSig, true},
+		{"RS1", "RS1", &signer.rsa.PublicKey, rsaSig, true},
+		{"ES256 使用 RSA 公钥", "ES256", &signer.rsa.PublicKey, rsaSig, true},
+		{"RS256 使用 EC 公钥", "RS256", &signer.ec.PublicKey, rsaSig, true},
+		{"ES256 签名长度错误", "ES256", &signer.ec.PublicKey, rsaSig, true},
+		{"空签名", "RS256", &signer.rsa.PublicKey, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyJWTSignature(tt.alg, tt.key, signed, tt.sig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyJWTSignature(%s) error = %v, wantErr %v", tt.alg, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"lxdweb/config"
+)
+
+// maxPasswordBytes bcrypt 只处理前 72 字节，超出部分会被拒绝
+const maxPasswordBytes = 72
+
+// commonPasswords 常见弱密码，比较时忽略大小写
+var commonPasswords = map[string]bool{
+	"admin123": true, "admin@123": true, "administrator": true, "password": true, "password1": true,
+	"password123": true, "p@ssw0rd": true, "p@ssword": true, "qwerty123": true, "qwertyuiop": true,
+	"1q2w3e4r": true, "1q2w3e4r5t": true, "12345678": true, "123456789": true, "1234567890": true,
+	"11111111": true, "88888888": true, "abc12345": true, "abcd1234": true, "iloveyou": true,
+	"changeme": true, "letmein123": true, "welcome123": true, "root1234": true,
+}
+
+// ValidatePassword 按密码策略检查新密码：长度、字符种类、不能包含用户名、不能是常见弱密码
+func ValidatePassword(password, username string) error {
+	cfg := getPasswordPolicyConfig()
+
+	if utf8.RuneCountInString(password) < cfg.MinLength {
+		return fmt.Errorf("密码长度不能少于%d位", cfg.MinLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("密码长度不能超过%d字节", maxPasswordBytes)
+	}
+
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit, other} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < cfg.MinClasses {
+		return fmt.Errorf("密码需包含大写字母、小写字母、数字、符号中的至少%d种", cfg.MinClasses)
+	}
+
+	lowered := strings.ToLower(password)
+	if name := strings.ToLower(strings.TrimSpace(username)); len(name) >= 3 && strings.Contains(lowered, name) {
+		return fmt.Errorf("密码不能包含用户名")
+	}
+	if commonPasswords[lowered] {
+		return fmt.Errorf("密码过于常见，请更换")
+	}
+	return nil
+}
+
+func getPasswordPolicyConfig() config.PasswordPolicyConfig {
+	cfg := config.PasswordPolicyConfig{
+		MinLength:  10,
+		MinClasses: 3,
+	}
+	if config.AppConfig == nil {
+		return cfg
+	}
+	p := config.AppConfig.PasswordPolicy
+	if p.MinLength > 0 {
+		cfg.MinLength = p.MinLength
+	}
+	if p.MinClasses > 0 && p.MinClasses <= 4 {
+		cfg.MinClasses = p.MinClasses
+	}
+	return cfg
+}
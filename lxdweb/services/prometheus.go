@@ -0,0 +1,292 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"lxdweb/database"
+	"lxdweb/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// MetricsRegistry Prometheus 指标注册表，/metrics 只输出此注册表中的指标
+var MetricsRegistry = prometheus.NewRegistry()
+
+var (
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lxdweb_http_request_duration_seconds",
+		Help:    "HTTP 请求处理耗时",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	syncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lxdweb_sync_duration_seconds",
+		Help:    "容器同步任务耗时",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	}, []string{"node", "status"})
+)
+
+func init() {
+	MetricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestDuration,
+		syncDuration,
+		&dbCollector{},
+	)
+}
+
+// ObserveHTTPRequest 记录一次 HTTP 请求耗时
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	httpRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// observeSyncTask 同步任务结束时记录耗时
+func observeSyncTask(task *models.SyncTask) {
+	if task.StartTime == nil || task.EndTime == nil {
+		return
+	}
+	syncDuration.WithLabelValues(task.NodeName, task.Status).Observe(task.EndTime.Sub(*task.StartTime).Seconds())
+}
+
+var (
+	containerLabels = []string{"node", "hostname"}
+
+	descContainerUp         = prometheus.NewDesc("lxdweb_container_up", "容器是否运行中", containerLabels, nil)
+	descContainerCPU        = prometheus.NewDesc("lxdweb_container_cpu_usage_percent", "容器 CPU 使用率", containerLabels, nil)
+	descContainerCPUs       = prometheus.NewDesc("lxdweb_container_cpus", "容器 CPU 核数", containerLabels, nil)
+	descContainerMemUsage   = prometheus.NewDesc("lxdweb_container_memory_usage_bytes", "容器内存使用量", containerLabels, nil)
+	descContainerMemTotal   = prometheus.NewDesc("lxdweb_container_memory_total_bytes", "容器内存总量", containerLabels, nil)
+	descContainerDiskUsage  = prometheus.NewDesc("lxdweb_container_disk_usage_bytes", "容器磁盘使用量", containerLabels, nil)
+	descContainerDiskTotal  = prometheus.NewDesc("lxdweb_container_disk_total_bytes", "容器磁盘总量", containerLabels, nil)
+	descContainerTraffic    = prometheus.NewDesc("lxdweb_container_traffic_bytes", "容器流量", append(containerLabels, "direction"), nil)
+	descContainerTrafficLim = prometheus.NewDesc("lxdweb_container_traffic_limit_bytes", "容器流量限额，0 表示不限", containerLabels, nil)
+	descContainerLastSync   = prometheus.NewDesc("lxdweb_container_last_sync_timestamp_seconds", "容器缓存最后同步时间", containerLabels, nil)
+
+	descNodeUp          = prometheus.NewDesc("lxdweb_node_up", "节点是否在线（active 或 degraded）", []string{"node"}, nil)
+	descNodeStatus      = prometheus.NewDesc("lxdweb_node_status", "节点当前状态", []string{"node", "status"}, nil)
+	descNodeLatency     = prometheus.NewDesc("lxdweb_node_check_latency_seconds", "最近一次健康检查延迟", []string{"node"}, nil)
+	descNodeFailures    = prometheus.NewDesc("lxdweb_node_check_failures", "健康检查连续失败次数", []string{"node"}, nil)
+	descNodeCircuitOpen = prometheus.NewDesc("lxdweb_node_circuit_open", "节点是否处于熔断状态", []string{"node"}, nil)
+	descNodeContainers  = prometheus.NewDesc("lxdweb_node_containers", "节点容器数量", []string{"node"}, nil)
+	descNodeInfo        = prometheus.NewDesc("lxdweb_node_info", "节点版本信息", []string{"node", "version", "lxd_version", "arch", "distribution"}, nil)
+	descNodeSystem      = prometheus.NewDesc("lxdweb_node_system_value", "节点系统信息中的数值字段", []string{"node", "key"}, nil)
+	descNodeInfoAge     = prometheus.NewDesc("lxdweb_node_info_last_sync_timestamp_seconds", "节点系统信息最后刷新时间", []string{"node"}, nil)
+
+	descSyncTasks       = prometheus.NewDesc("lxdweb_sync_tasks_total", "同步任务数量", []string{"node", "status"}, nil)
+	descSyncRunning     = prometheus.NewDesc("lxdweb_sync_running", "节点是否正在同步", []string{"node"}, nil)
+	descSyncRunningFor  = prometheus.NewDesc("lxdweb_sync_running_duration_seconds", "当前运行中的同步任务已持续时间", []string{"node"}, nil)
+	descSyncLastSuccess = prometheus.NewDesc("lxdweb_sync_last_success_timestamp_seconds", "最后一次同步成功完成时间", []string{"node"}, nil)
+	descSyncLastDur     = prometheus.NewDesc("lxdweb_sync_last_duration_seconds", "最后一次同步耗时", []string{"node"}, nil)
+	descSyncLastFailed  = prometheus.NewDesc("lxdweb_sync_last_failed_containers", "最后一次同步失败的容器数", []string{"node"}, nil)
+)
+
+// dbCollector 抓取时从数据库读取容器缓存、节点状态和同步任务
+type dbCollector struct{}
+
+func (c *dbCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		descContainerUp, descContainerCPU, descContainerCPUs, descContainerMemUsage, descContainerMemTotal,
+		descContainerDiskUsage, descContainerDiskTotal, descContainerTraffic, descContainerTrafficLim, descContainerLastSync,
+		descNodeUp, descNodeStatus, descNodeLatency, descNodeFailures, descNodeCircuitOpen, descNodeContainers,
+		descNodeInfo, descNodeSystem, descNodeInfoAge,
+		descSyncTasks, descSyncRunning, descSyncRunningFor, descSyncLastSuccess, descSyncLastDur, descSyncLastFailed,
+	} {
+		ch <- d
+	}
+}
+
+func (c *dbCollector) Collect(ch chan<- prometheus.Metric) {
+	var nodes []models.Node
+	database.DB.Find(&nodes)
+	nodeNames := make(map[uint]string, len(nodes))
+	for _, node := range nodes {
+		nodeNames[node.ID] = node.Name
+	}
+
+	c.collectContainers(ch, nodeNames)
+	c.collectNodes(ch, nodes)
+	c.collectSync(ch, nodes)
+}
+
+func (c *dbCollector) collectContainers(ch chan<- prometheus.Metric, nodeNames map[uint]string) {
+	var containers []models.ContainerCache
+	database.DB.Find(&containers)
+
+	perNode := make(map[uint]int)
+	for _, ct := range containers {
+		perNode[ct.NodeID]++
+		node, ok := nodeNames[ct.NodeID]
+		if !ok {
+			node = ct.NodeName
+		}
+		labels := []string{node, ct.Hostname}
+
+		up := 0.0
+		if ct.Status == "Running" {
+			up = 1
+		}
+		gauge(ch, descContainerUp, up, labels...)
+		gauge(ch, descContainerCPU, ct.CPUUsage, labels...)
+		gauge(ch, descContainerCPUs, float64(ct.CPUs), labels...)
+		gauge(ch, descContainerMemUsage, float64(ct.MemoryUsage), labels...)
+		gauge(ch, descContainerMemTotal, float64(ct.MemoryTotal), labels...)
+		gauge(ch, descContainerDiskUsage, float64(ct.DiskUsage), labels...)
+		gauge(ch, descContainerDiskTotal, float64(ct.DiskTotal), labels...)
+		gauge(ch, descContainerTraffic, float64(ct.TrafficTotal), node, ct.Hostname, "total")
+		gauge(ch, descContainerTraffic, float64(ct.TrafficIn), node, ct.Hostname, "in")
+		gauge(ch, descContainerTraffic, float64(ct.TrafficOut), node, ct.Hostname, "out")
+		gauge(ch, descContainerTrafficLim, float64(ct.TrafficLimit)*1024*1024*1024, labels...)
+		gauge(ch, descContainerLastSync, float64(ct.LastSync.Unix()), labels...)
+	}
+
+	for id, name := range nodeNames {
+		gauge(ch, descNodeContainers, float64(perNode[id]), name)
+	}
+}
+
+func (c *dbCollector) collectNodes(ch chan<- prometheus.Metric, nodes []models.Node) {
+	var caches []models.NodeInfoCache
+	database.DB.Find(&caches)
+	cacheMap := make(map[uint]models.NodeInfoCache, len(caches))
+	for _, cache := range caches {
+		cacheMap[cache.NodeID] = cache
+	}
+
+	for _, node := range nodes {
+		up := 0.0
+		if node.Status == "active" || node.Status == "degraded" {
+			up = 1
+		}
+		gauge(ch, descNodeUp, up, node.Name)
+		for _, status := range []string{"active", "degraded", "error", "inactive"} {
+			v := 0.0
+			if node.Status == status {
+				v = 1
+			}
+			gauge(ch, descNodeStatus, v, node.Name, status)
+		}
+		gauge(ch, descNodeLatency, float64(node.LatencyMs)/1000, node.Name)
+		gauge(ch, descNodeFailures, float64(node.FailCount), node.Name)
+
+		cache, hasCache := cacheMap[node.ID]
+		circuitOpen := 0.0
+		if snap, ok := NodeCircuit(node.ID); ok {
+			if snap.State != "closed" {
+				circuitOpen = 1
+			}
+		} else if hasCache && cache.CircuitState == "open" {
+			circuitOpen = 1
+		}
+		gauge(ch, descNodeCircuitOpen, circuitOpen, node.Name)
+
+		if !hasCache || cache.SystemInfo == "" {
+			continue
+		}
+		var sysInfo map[string]interface{}
+		if err := json.Unmarshal([]byte(cache.SystemInfo), &sysInfo); err != nil {
+			continue
+		}
+		gauge(ch, descNodeInfoAge, float64(cache.LastSync.Unix()), node.Name)
+
+		system, _ := sysInfo["system"].(map[string]interface{})
+		gauge(ch, descNodeInfo, 1, node.Name,
+			stringField(sysInfo, "version"), stringField(sysInfo, "lxd_version"),
+			stringField(system, "arch"), stringField(system, "distribution"))
+
+		values := make(map[string]float64)
+		flattenNumbers("", sysInfo, values)
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			gauge(ch, descNodeSystem, values[k], node.Name, k)
+		}
+	}
+}
+
+func (c *dbCollector) collectSync(ch chan<- prometheus.Metric, nodes []models.Node) {
+	type statusCount struct {
+		NodeID uint
+		Status string
+		Count  int64
+	}
+	var counts []statusCount
+	database.DB.Model(&models.SyncTask{}).Select("node_id, status, count(*) as count").Group("node_id, status").Scan(&counts)
+	nodeNames := make(map[uint]string, len(nodes))
+	for _, node := range nodes {
+		nodeNames[node.ID] = node.Name
+	}
+	for _, sc := range counts {
+		if name, ok := nodeNames[sc.NodeID]; ok {
+			gauge(ch, descSyncTasks, float64(sc.Count), name, sc.Status)
+		}
+	}
+
+	for _, node := range nodes {
+		running := 0.0
+		if IsSyncing(node.ID) {
+			running = 1
+		}
+		gauge(ch, descSyncRunning, running, node.Name)
+
+		var current models.SyncTask
+		if err := database.DB.Where("node_id = ? AND status = ?", node.ID, "running").Order("id desc").First(&current).Error; err == nil && current.StartTime != nil {
+			gauge(ch, descSyncRunningFor, time.Since(*current.StartTime).Seconds(), node.Name)
+		}
+
+		var last models.SyncTask
+		if err := database.DB.Where("node_id = ? AND start_time IS NOT NULL AND end_time IS NOT NULL", node.ID).Order("id desc").First(&last).Error; err == nil {
+			gauge(ch, descSyncLastDur, last.EndTime.Sub(*last.StartTime).Seconds(), node.Name)
+			gauge(ch, descSyncLastFailed, float64(last.FailedCount), node.Name)
+		}
+
+		var lastOK models.SyncTask
+		if err := database.DB.Where("node_id = ? AND status = ?", node.ID, "completed").Order("id desc").First(&lastOK).Error; err == nil && lastOK.EndTime != nil {
+			gauge(ch, descSyncLastSuccess, float64(lastOK.EndTime.Unix()), node.Name)
+		}
+	}
+}
+
+func gauge(ch chan<- prometheus.Metric, desc *prometheus.Desc, value float64, labels ...string) {
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	if m == nil {
+		return ""
+	}
+	if v, ok := m[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// flattenNumbers 将系统信息中的数值字段展开为 a.b.c 形式的键
+func flattenNumbers(prefix string, v interface{}, out map[string]float64) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			flattenNumbers(key, child, out)
+		}
+	case float64:
+		out[prefix] = val
+	case bool:
+		if val {
+			out[prefix] = 1
+		} else {
+			out[prefix] = 0
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"lxdweb/config"
+	"lxdweb/database"
+	"lxdweb/models"
+
+	"gorm.io/gorm"
+)
+
+// 加密后的密文格式: enc:v1:<主密钥ID>:<base64(nonce + 密文)>，没有前缀的值视为尚未加密的明文
+const secretPrefix = "enc:v1:"
+
+// secretAAD 绑定密文用途，防止密文被挪作他用
+var secretAAD = []byte("lxdweb/node/api_key")
+
+var (
+	ErrMasterKeyMissing  = errors.New("主密钥未初始化")
+	ErrMasterKeyMismatch = errors.New("密文不是由当前主密钥加密的")
+)
+
+type masterKey struct {
+	key    []byte
+	id     string
+	source string
+}
+
+var (
+	masterKeyMu sync.RWMutex
+	currentKey  *masterKey
+	// previousKey 本进程轮换前使用的主密钥，用于重新加密轮换期间写入的旧密文
+	previousKey *masterKey
+)
+
+// InitSecrets 加载主密钥，环境变量优先，其次为密钥文件，两者都没有时生成新的密钥文件
+func InitSecrets() error {
+	mk, err := loadMasterKey(true)
+	if err != nil {
+		return err
+	}
+	masterKeyMu.Lock()
+	currentKey = mk
+	masterKeyMu.Unlock()
+	log.Printf("[SECRETS] 主密钥加载完成: 来源=%s, ID=%s", mk.source, mk.id)
+	return nil
+}
+
+// MasterKeyFromEnv 当前主密钥是否来自环境变量
+func MasterKeyFromEnv() bool {
+	masterKeyMu.RLock()
+	defer masterKeyMu.RUnlock()
+	return currentKey != nil && strings.HasPrefix(currentKey.source, "env:")
+}
+
+func loadMasterKey(create bool) (*masterKey, error) {
+	cfg := getSecretsConfig()
+	if v := strings.TrimSpace(os.Getenv(cfg.MasterKeyEnv)); v != "" {
+		key, err := ParseMasterKey(v)
+		if err != nil {
+			return nil, fmt.Errorf("环境变量 %s 中的主密钥无效: %v", cfg.MasterKeyEnv, err)
+		}
+		return newMasterKey(key, "env:"+cfg.MasterKeyEnv), nil
+	}
+
+	data, err := os.ReadFile(cfg.MasterKeyFile)
+	if os.IsNotExist(err) && create {
+		key, err := GenerateMasterKey()
+		if err != nil {
+			return nil, err
+		}
+		if err := WriteMasterKeyFile(cfg.MasterKeyFile, key); err != nil {
+			return nil, fmt.Errorf("写入主密钥文件失败: %v", err)
+		}
+		log.Printf("[SECRETS] 已生成主密钥文件 %s，请妥善备份", cfg.MasterKeyFile)
+		return newMasterKey(key, "file:"+cfg.MasterKeyFile), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取主密钥文件失败: %v", err)
+	}
+	key, err := ParseMasterKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("主密钥文件 %s 无效: %v", cfg.MasterKeyFile, err)
+	}
+	return newMasterKey(key, "file:"+cfg.MasterKeyFile), nil
+}
+
+func newMasterKey(key []byte, source string) *masterKey {
+	sum := sha256.Sum256(key)
+	return &masterKey{key: key, id: hex.EncodeToString(sum[:4]), source: source}
+}
+
+// GenerateMasterKey 生成 32 字节的随机主密钥
+func GenerateMasterKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ParseMasterKey 解析 base64 或十六进制编码的 32 字节主密钥
+func ParseMasterKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("需要 base64 或十六进制编码的 32 字节密钥")
+}
+
+// WriteMasterKeyFile 以 0600 权限写入新的主密钥文件，文件已存在时返回错误
+func WriteMasterKeyFile(path string, key []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(base64.StdEncoding.EncodeToString(key) + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// EncryptSecret 使用当前主密钥加密，空字符串原样返回。
+// 主密钥来自文件时每次加密前重新读取密钥文件，命令行轮换密钥后运行中的服务不会再用旧密钥写入
+func EncryptSecret(plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	masterKeyMu.RLock()
+	mk := currentKey
+	masterKeyMu.RUnlock()
+	if mk == nil {
+		return "", ErrMasterKeyMissing
+	}
+	if strings.HasPrefix(mk.source, "file:") {
+		reloaded, err := loadMasterKey(false)
+		if err != nil {
+			return "", fmt.Errorf("读取主密钥文件失败: %v", err)
+		}
+		if reloaded.id != mk.id {
+			masterKeyMu.Lock()
+			currentKey = reloaded
+			masterKeyMu.Unlock()
+			log.Printf("[SECRETS] 检测到主密钥已轮换，已重新加载: ID=%s", reloaded.id)
+			mk = reloaded
+		}
+	}
+	return encryptWith(mk, plain)
+}
+
+// DecryptSecret 解密 EncryptSecret 的结果，未加密的旧数据原样返回。
+// 密钥ID不一致时重新读取一次主密钥，运行中的服务无需重启即可使用轮换后的新密钥
+func DecryptSecret(value string) (string, error) {
+	if !strings.HasPrefix(value, secretPrefix) {
+		return value, nil
+	}
+	masterKeyMu.RLock()
+	mk := currentKey
+	masterKeyMu.RUnlock()
+	if mk == nil {
+		return "", ErrMasterKeyMissing
+	}
+
+	plain, err := decryptWith(mk, value)
+	if !errors.Is(err, ErrMasterKeyMismatch) {
+		return plain, err
+	}
+	reloaded, loadErr := loadMasterKey(false)
+	if loadErr != nil || reloaded.id == mk.id {
+		return "", err
+	}
+	plain, err = decryptWith(reloaded, value)
+	if err == nil {
+		masterKeyMu.Lock()
+		currentKey = reloaded
+		masterKeyMu.Unlock()
+		log.Printf("[SECRETS] 检测到主密钥已轮换，已重新加载: ID=%s", reloaded.id)
+	}
+	return plain, err
+}
+
+func encryptWith(mk *masterKey, plain string) (string, error) {
+	aead, err := newAEAD(mk.key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), secretAAD)
+	return secretPrefix + mk.id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptWith(mk *masterKey, value string) (string, error) {
+	keyID, payload, ok := strings.Cut(strings.TrimPrefix(value, secretPrefix), ":")
+	if !ok {
+		return "", errors.New("密文格式错误")
+	}
+	if keyID != mk.id {
+		return "", fmt.Errorf("%w: 密文密钥ID %s，当前主密钥ID %s", ErrMasterKeyMismatch, keyID, mk.id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", errors.New("密文格式错误")
+	}
+	aead, err := newAEAD(mk.key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("密文格式错误")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], secretAAD)
+	if err != nil {
+		return "", errors.New("密文校验失败，数据可能被篡改")
+	}
+	return string(plain), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// NodeAPIKey 返回节点解密后的 API 密钥，仅供节点客户端使用，解密失败时返回空字符串
+func NodeAPIKey(node models.Node) string {
+	plain, err := DecryptSecret(node.APIKey)
+	if err != nil {
+		log.Printf("[SECRETS] 节点 %d 的 API 密钥解密失败: %v", node.ID, err)
+		return ""
+	}
+	return plain
+}
+
+// MaskNodeAPIKey 返回节点 API 密钥的掩码，只保留末尾 4 位用于核对
+func MaskNodeAPIKey(node models.Node) string {
+	if node.APIKey == "" {
+		return ""
+	}
+	plain, err := DecryptSecret(node.APIKey)
+	if err != nil || len(plain) < 12 {
+		return "********"
+	}
+	return "********" + plain[len(plain)-4:]
+}
+
+// EncryptNodeAPIKeys 加密数据库中仍为明文的节点 API 密钥，返回处理的节点数
+func EncryptNodeAPIKeys() (int, error) {
+	var nodes []models.Node
+	if err := database.DB.Unscoped().Where("api_key <> '' AND api_key NOT LIKE ?", secretPrefix+"%").Find(&nodes).Error; err != nil {
+		return 0, err
+	}
+	for _, node := range nodes {
+		enc, err := EncryptSecret(node.APIKey)
+		if err != nil {
+			return 0, err
+		}
+		if err := database.DB.Unscoped().Model(&models.Node{}).Where("id = ?", node.ID).Update("api_key", enc).Error; err != nil {
+			return 0, err
+		}
+	}
+	if len(nodes) > 0 {
+		log.Printf("[SECRETS] 已加密 %d 个节点的明文 API 密钥", len(nodes))
+	}
+	return len(nodes), nil
+}
+
+// RotateMasterKey 用新主密钥重新加密全部节点 API 密钥，在一个事务中完成，失败时数据保持不变。
+// 调用方需在事务成功后再替换主密钥文件或环境变量
+func RotateMasterKey(newKey []byte) (int, string, error) {
+	next := newMasterKey(newKey, "")
+	var nodes []models.Node
+	if err := database.DB.Unscoped().Where("api_key <> ''").Find(&nodes).Error; err != nil {
+		return 0, "", err
+	}
+
+	plains := make(map[uint]string, len(nodes))
+	for _, node := range nodes {
+		plain, err := DecryptSecret(node.APIKey)
+		if err != nil {
+			return 0, "", fmt.Errorf("节点 %s 的 API 密钥解密失败: %v", node.Name, err)
+		}
+		plains[node.ID] = plain
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for id, plain := range plains {
+			enc, err := encryptWith(next, plain)
+			if err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.Node{}).Where("id = ?", id).
+				Updates(map[string]interface{}{"api_key": enc, "updated_at": time.Now()}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	masterKeyMu.Lock()
+	if currentKey != nil {
+		next.source = currentKey.source
+	}
+	previousKey = currentKey
+	currentKey = next
+	masterKeyMu.Unlock()
+	return len(plains), next.id, nil
+}
+
+// ReencryptPreviousKeySecrets 用当前主密钥重新加密仍由轮换前主密钥加密的节点 API 密钥，返回处理的节点数。
+// 替换密钥文件前运行中的服务仍会用旧密钥写入，轮换命令在替换密钥文件后调用一次
+func ReencryptPreviousKeySecrets() (int, error) {
+	masterKeyMu.RLock()
+	prev, mk := previousKey, currentKey
+	masterKeyMu.RUnlock()
+	if prev == nil || mk == nil {
+		return 0, nil
+	}
+
+	var nodes []models.Node
+	if err := database.DB.Unscoped().Where("api_key LIKE ?", secretPrefix+prev.id+":%").Find(&nodes).Error; err != nil {
+		return 0, err
+	}
+	for _, node := range nodes {
+		plain, err := decryptWith(prev, node.APIKey)
+		if err != nil {
+			return 0, fmt.Errorf("节点 %s 的 API 密钥解密失败: %v", node.Name, err)
+		}
+		enc, err := encryptWith(mk, plain)
+		if err != nil {
+			return 0, err
+		}
+		if err := database.DB.Unscoped().Model(&models.Node{}).Where("id = ? AND api_key = ?", node.ID, node.APIKey).
+			Update("api_key", enc).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(nodes), nil
+}
+
+func getSecretsConfig() config.SecretsConfig {
+	cfg := config.SecretsConfig{
+		MasterKeyFile: "master.key",
+		MasterKeyEnv:  "LXDWEB_MASTER_KEY",
+	}
+	if config.AppConfig == nil {
+		return cfg
+	}
+	if config.AppConfig.Secrets.MasterKeyFile != "" {
+		cfg.MasterKeyFile = config.AppConfig.Secrets.MasterKeyFile
+	}
+	if config.AppConfig.Secrets.MasterKeyEnv != "" {
+		cfg.MasterKeyEnv = config.AppConfig.Secrets.MasterKeyEnv
+	}
+	return cfg
+}
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"lxdweb/config"
+	"lxdweb/database"
+	"lxdweb/models"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gorilla/securecookie"
+	gsessions "github.com/gorilla/sessions"
+)
+
+// sessionTouchInterval 最近活动时间的最小更新间隔，避免每个请求都写库
+const sessionTouchInterval = time.Minute
+
+// SessionStore 基于数据库的会话存储，实现 gin-contrib/sessions 的 Store 接口。
+// 已登录会话的 Cookie 中只保存签名后的会话ID，会话数据、空闲超时和绝对超时都在服务端判断；
+// 未登录访客的 CSRF 令牌、验证码ID等登录前状态直接签名保存在 Cookie 中，不写数据库
+type SessionStore struct {
+	codecs   []securecookie.Codec
+	options  *gsessions.Options
+	idle     time.Duration
+	absolute time.Duration
+}
+
+// NewSessionStore 按 session 配置创建会话存储
+func NewSessionStore(secret []byte) *SessionStore {
+	cfg := getSessionConfig()
+	codecs := securecookie.CodecsFromPairs(secret)
+	for _, codec := range codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(cfg.AbsoluteTimeout)
+		}
+	}
+
+	return &SessionStore{
+		codecs: codecs,
+		options: &gsessions.Options{
+			Path:     "/",
+			MaxAge:   cfg.AbsoluteTimeout,
+			Secure:   *cfg.CookieSecure,
+			HttpOnly: *cfg.CookieHTTPOnly,
+			SameSite: parseSameSite(cfg.CookieSameSite),
+		},
+		idle:     time.Duration(cfg.IdleTimeout) * time.Second,
+		absolute: time.Duration(cfg.AbsoluteTimeout) * time.Second,
+	}
+}
+
+func (s *SessionStore) Options(options sessions.Options) {
+	s.options = options.ToGorillaOptions()
+}
+
+func (s *SessionStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+// New 读取 Cookie 对应的会话，无效、过期或已吊销时返回空的新会话
+func (s *SessionStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	options := *s.options
+	session.Options = &options
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var key string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &key, s.codecs...); err != nil {
+		values := make(map[interface{}]interface{})
+		if err := securecookie.DecodeMulti(name, cookie.Value, &values, s.codecs...); err == nil && !persistentSession(values) {
+			session.Values = values
+		}
+		return session, nil
+	}
+	row, ok := s.load(key)
+	if !ok {
+		return session, nil
+	}
+	if err := gob.NewDecoder(bytes.NewReader(row.Data)).Decode(&session.Values); err != nil {
+		return session, nil
+	}
+	session.ID = key
+	session.IsNew = false
+	return session, nil
+}
+
+// Save 保存会话数据。登录用户变化时更换会话ID防止会话固定，MaxAge < 0 或会话已清空时删除会话
+func (s *SessionStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	if session.Options == nil {
+		options := *s.options
+		session.Options = &options
+	}
+	if session.Options.MaxAge < 0 || len(session.Values) == 0 {
+		if session.ID != "" {
+			database.DB.Where("session_key = ?", session.ID).Delete(&models.AdminSession{})
+		}
+		options := *session.Options
+		options.MaxAge = -1
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", &options))
+		return nil
+	}
+
+	if !persistentSession(session.Values) {
+		if session.ID != "" {
+			database.DB.Where("session_key = ?", session.ID).Delete(&models.AdminSession{})
+			session.ID = ""
+		}
+		encoded, err := securecookie.EncodeMulti(session.Name(), session.Values, s.codecs...)
+		if err != nil {
+			return err
+		}
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), encoded, session.Options))
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(session.Values); err != nil {
+		return err
+	}
+	adminID := sessionAdminID(session.Values)
+	now := time.Now()
+
+	var row models.AdminSession
+	if session.ID != "" {
+		database.DB.Where("session_key = ?", session.ID).First(&row)
+	}
+	if row.ID != 0 && row.AdminID != adminID {
+		database.DB.Delete(&row)
+		row = models.AdminSession{}
+	}
+	if row.ID == 0 {
+		key, err := newSessionKey()
+		if err != nil {
+			return err
+		}
+		row = models.AdminSession{
+			SessionKey: key,
+			IPAddress:  requestIP(r),
+			UserAgent:  truncate(r.UserAgent(), 255),
+			CreatedAt:  now,
+			ExpiresAt:  now.Add(s.absolute),
+		}
+		session.ID = key
+	}
+	row.AdminID = adminID
+	row.Data = buf.Bytes()
+	row.LastSeenAt = now
+	if err := database.DB.Save(&row).Error; err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// load 读取会话记录，超过空闲时间或绝对有效期的会话直接删除
+func (s *SessionStore) load(key string) (*models.AdminSession, bool) {
+	var row models.AdminSession
+	if err := database.DB.Where("session_key = ?", key).First(&row).Error; err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	if now.After(row.ExpiresAt) || now.Sub(row.LastSeenAt) > s.idle {
+		database.DB.Delete(&row)
+		return nil, false
+	}
+	if now.Sub(row.LastSeenAt) >= sessionTouchInterval {
+		database.DB.Model(&row).Update("last_seen_at", now)
+	}
+	return &row, true
+}
+
+func StartSessionService() {
+	cfg := getSessionConfig()
+	log.Printf("[SESSION] 会话空闲超时 %d 秒，最长有效期 %d 秒", cfg.IdleTimeout, cfg.AbsoluteTimeout)
+
+	go func() {
+		pruneSessions()
+		ticker := time.NewTicker(10 * time.Minute)
+		for range ticker.C {
+			pruneSessions()
+		}
+	}()
+}
+
+// ListAdminSessions 返回管理员仍然有效的会话
+func ListAdminSessions(adminID uint) []models.AdminSession {
+	idle := time.Duration(getSessionConfig().IdleTimeout) * time.Second
+	now := time.Now()
+
+	var rows []models.AdminSession
+	database.DB.Where("admin_id = ? AND expires_at > ? AND last_seen_at > ?", adminID, now, now.Add(-idle)).
+		Order("last_seen_at desc").Find(&rows)
+	return rows
+}
+
+// RevokeAdminSessions 吊销管理员的全部会话，exceptKey 不为空时保留该会话
+func RevokeAdminSessions(adminID uint, exceptKey string) int64 {
+	query := database.DB.Where("admin_id = ?", adminID)
+	if exceptKey != "" {
+		query = query.Where("session_key <> ?", exceptKey)
+	}
+	result := query.Delete(&models.AdminSession{})
+	if result.Error != nil {
+		log.Printf("[SESSION] 吊销管理员 %d 的会话失败: %v", adminID, result.Error)
+		return 0
+	}
+	return result.RowsAffected
+}
+
+func pruneSessions() {
+	idle := time.Duration(getSessionConfig().IdleTimeout) * time.Second
+	now := time.Now()
+	result := database.DB.Where("expires_at < ? OR last_seen_at < ?", now, now.Add(-idle)).Delete(&models.AdminSession{})
+	if result.Error != nil {
+		log.Printf("[SESSION] 清理过期会话失败: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("[SESSION] 清理 %d 个过期会话", result.RowsAffected)
+	}
+}
+
+// persistentSession 已登录或已通过密码验证、等待两步验证的会话才保存到数据库。
+// 两步验证的尝试次数必须在服务端计数，不能放在可被客户端重放的 Cookie 中
+func persistentSession(values map[interface{}]interface{}) bool {
+	return sessionAdminID(values) != 0 || values["2fa_admin_id"] != nil
+}
+
+func sessionAdminID(values map[interface{}]interface{}) uint {
+	switch v := values["admin_id"].(type) {
+	case uint:
+		return v
+	case int:
+		return uint(v)
+	case int64:
+		return uint(v)
+	}
+	return 0
+}
+
+func newSessionKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// requestIP 与 gin 的 ClientIP 取值顺序一致
+func requestIP(r *http.Request) string {
+	if v := r.Header.Get("X-Forwarded-For"); v != "" {
+		return strings.TrimSpace(strings.Split(v, ",")[0])
+	}
+	if v := r.Header.Get("X-Real-IP"); v != "" {
+		return strings.TrimSpace(v)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// SessionCookieOptions 返回会话 Cookie 的 Secure 与 SameSite 设置，供其他 Cookie 保持一致
+func SessionCookieOptions() (bool, http.SameSite) {
+	cfg := getSessionConfig()
+	return *cfg.CookieSecure, parseSameSite(cfg.CookieSameSite)
+}
+
+func parseSameSite(v string) http.SameSite {
+	switch strings.ToLower(v) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+func getSessionConfig() config.SessionConfig {
+	secure, httpOnly := false, true
+	cfg := config.SessionConfig{
+		IdleTimeout:     1800,
+		AbsoluteTimeout: 43200,
+		CookieSecure:    &secure,
+		CookieHTTPOnly:  &httpOnly,
+		CookieSameSite:  "lax",
+	}
+	if config.AppConfig == nil {
+		return cfg
+	}
+	s := config.AppConfig.Session
+	if s.IdleTimeout > 0 {
+		cfg.IdleTimeout = s.IdleTimeout
+	}
+	if s.AbsoluteTimeout > 0 {
+		cfg.AbsoluteTimeout = s.AbsoluteTimeout
+	}
+	if s.CookieSecure != nil {
+		cfg.CookieSecure = s.CookieSecure
+	}
+	if s.CookieHTTPOnly != nil {
+		cfg.CookieHTTPOnly = s.CookieHTTPOnly
+	}
+	if s.CookieSameSite != "" {
+		cfg.CookieSameSite = s.CookieSameSite
+	}
+	return cfg
+}
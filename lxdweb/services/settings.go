@@ -0,0 +1,81 @@
+package services
+
+import (
+	"strconv"
+	"sync"
+
+	"lxdweb/database"
+	"lxdweb/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// 全局设置键
+const (
+	SettingEnforce2FA = "security.enforce_2fa"
+)
+
+var (
+	settingsMu    sync.RWMutex
+	settingsCache map[string]string
+)
+
+// GetSetting 读取设置，未设置时返回 def。首次调用时整体加载到内存
+func GetSetting(key, def string) string {
+	settingsMu.RLock()
+	loaded := settingsCache != nil
+	value, ok := settingsCache[key]
+	settingsMu.RUnlock()
+
+	if !loaded {
+		loadSettings()
+		settingsMu.RLock()
+		value, ok = settingsCache[key]
+		settingsMu.RUnlock()
+	}
+	if !ok {
+		return def
+	}
+	return value
+}
+
+// GetBoolSetting 读取布尔设置
+func GetBoolSetting(key string, def bool) bool {
+	b, err := strconv.ParseBool(GetSetting(key, strconv.FormatBool(def)))
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// SetSetting 写入设置并更新缓存
+func SetSetting(key, value string) error {
+	setting := models.Setting{Key: key, Value: value}
+	err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&setting).Error
+	if err != nil {
+		return err
+	}
+
+	settingsMu.Lock()
+	if settingsCache != nil {
+		settingsCache[key] = value
+	}
+	settingsMu.Unlock()
+	return nil
+}
+
+func loadSettings() {
+	var settings []models.Setting
+	database.DB.Find(&settings)
+
+	cache := make(map[string]string, len(settings))
+	for _, s := range settings {
+		cache[s.Key] = s.Value
+	}
+	settingsMu.Lock()
+	settingsCache = cache
+	settingsMu.Unlock()
+}
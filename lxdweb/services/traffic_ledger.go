@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"lxdweb/database"
+	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+)
+
+// ledgerMu 串行化账本读写，同步任务与手动重置可能同时更新同一容器
+var ledgerMu sync.Mutex
+
+// TrafficPeriod 返回时间所在的账期，格式 YYYY-MM
+func TrafficPeriod(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+// fetchContainerTraffic 读取容器流量计数，失败时返回 nil 由调用方回退到 traffic_usage_raw
+func fetchContainerTraffic(ctx context.Context, client *lxdapi.Client, hostname string) *lxdapi.TrafficStats {
+	stats, err := client.Traffic(ctx, hostname)
+	if err != nil {
+		log.Printf("[TRAFFIC] 获取容器 %s 流量计数失败: %v", hostname, err)
+		return nil
+	}
+	return stats
+}
+
+// fetchNodeTraffic 按节点批次设置读取多个容器的流量计数，失败或熔断后未读取的容器不在结果中
+func fetchNodeTraffic(ctx context.Context, client *lxdapi.Client, node models.Node, hostnames []string) map[string]*lxdapi.TrafficStats {
+	var mu sync.Mutex
+	result := make(map[string]*lxdapi.TrafficStats, len(hostnames))
+	err := runNodeBatches(node, hostnames, func(hostname string) {
+		if stats := fetchContainerTraffic(ctx, client, hostname); stats != nil {
+			mu.Lock()
+			result[hostname] = stats
+			mu.Unlock()
+		}
+	})
+	if err != nil {
+		log.Printf("[TRAFFIC] 节点 %s 读取流量计数中止: %v", node.Name, err)
+	}
+	return result
+}
+
+// loadLedgerEntry 读取容器当期账本，跨月时新建账本并沿用上一期的计数器基线。
+// 第二个返回值表示是否存在基线，首次采样没有基线时只记录计数器不计增量
+func loadLedgerEntry(nodeID uint, hostname, period string) (models.TrafficLedger, bool) {
+	var last models.TrafficLedger
+	err := database.DB.Where("node_id = ? AND hostname = ?", nodeID, hostname).
+		Order("period desc").First(&last).Error
+	if err != nil {
+		return models.TrafficLedger{NodeID: nodeID, Hostname: hostname, Period: period}, false
+	}
+	// 旧账本没有记录基线来源，有分方向计数即视为分方向基线
+	if !last.LastDirectional && (last.LastRx > 0 || last.LastTx > 0) {
+		last.LastDirectional = true
+	}
+	if last.Period == period {
+		return last, true
+	}
+	return models.TrafficLedger{
+		NodeID:          nodeID,
+		Hostname:        hostname,
+		Period:          period,
+		LastRx:          last.LastRx,
+		LastTx:          last.LastTx,
+		LastTotal:       last.LastTotal,
+		LastDirectional: last.LastDirectional,
+	}, true
+}
+
+// recordTrafficSample 根据节点计数器计算增量并累加到当期账本。
+// 任一计数器比上次小视为节点侧已重置，此时本次读数全部计为增量。
+// 已有分方向基线时，/api/traffic 临时失败回退的总量样本直接忽略，基线保持不变，
+// 这段时间的流量在下一次分方向采样时计入；计数来源切换时只重建基线，避免误判为重置
+func recordTrafficSample(nodeID uint, hostname string, stats *lxdapi.TrafficStats, at time.Time) {
+	ledgerMu.Lock()
+	defer ledgerMu.Unlock()
+
+	entry, hasBaseline := loadLedgerEntry(nodeID, hostname, TrafficPeriod(at))
+	if hasBaseline && entry.LastDirectional && !stats.Directional {
+		return
+	}
+	if hasBaseline && entry.LastDirectional != stats.Directional {
+		log.Printf("[TRAFFIC] 容器 %s 流量计数来源变更，重新建立基线", hostname)
+		hasBaseline = false
+	}
+
+	if hasBaseline {
+		reset := stats.TotalBytes < entry.LastTotal ||
+			(stats.Directional && (stats.RxBytes < entry.LastRx || stats.TxBytes < entry.LastTx))
+		if reset {
+			entry.Resets++
+			log.Printf("[TRAFFIC] 容器 %s 流量计数器已重置 (%d -> %d)", hostname, entry.LastTotal, stats.TotalBytes)
+			entry.TotalBytes += stats.TotalBytes
+			if stats.Directional {
+				entry.RxBytes += stats.RxBytes
+				entry.TxBytes += stats.TxBytes
+			}
+		} else {
+			entry.TotalBytes += stats.TotalBytes - entry.LastTotal
+			if stats.Directional {
+				entry.RxBytes += stats.RxBytes - entry.LastRx
+				entry.TxBytes += stats.TxBytes - entry.LastTx
+			}
+		}
+	}
+
+	entry.LastTotal = stats.TotalBytes
+	entry.LastRx, entry.LastTx = 0, 0
+	if stats.Directional {
+		entry.LastRx, entry.LastTx = stats.RxBytes, stats.TxBytes
+	}
+	entry.LastDirectional = stats.Directional
+	entry.LastSample = at
+
+	if err := database.DB.Save(&entry).Error; err != nil {
+		log.Printf("[TRAFFIC] 更新容器 %s 流量账本失败: %v", hostname, err)
+	}
+}
+
+// MarkTrafficReset 节点流量重置成功后清零账本基线，之后的读数全部计为增量
+func MarkTrafficReset(nodeID uint, hostname string) {
+	ledgerMu.Lock()
+	defer ledgerMu.Unlock()
+
+	entry, _ := loadLedgerEntry(nodeID, hostname, TrafficPeriod(time.Now()))
+	entry.LastRx, entry.LastTx, entry.LastTotal = 0, 0, 0
+	entry.Resets++
+	entry.LastSample = time.Now()
+
+	if err := database.DB.Save(&entry).Error; err != nil {
+		log.Printf("[TRAFFIC] 记录容器 %s 流量重置失败: %v", hostname, err)
+		return
+	}
+	log.Printf("[TRAFFIC] 容器 %s 流量已重置，账期 %s", hostname, entry.Period)
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"lxdweb/config"
+	"lxdweb/database"
+	"lxdweb/models"
+	"lxdweb/pkg/lxdapi"
+)
+
+const gigabyte = 1024 * 1024 * 1024
+
+func StartTrafficQuotaService() {
+	cfg := getQuotaConfig()
+	if !cfg.Enabled {
+		log.Println("[QUOTA] 流量配额调度未启用")
+		return
+	}
+	log.Printf("[QUOTA] 流量配额调度启动，检查间隔 %d 秒，超额处理: %s", cfg.Interval, cfg.OverQuotaAction)
+
+	go func() {
+		checkTrafficQuotas()
+		ticker := time.NewTicker(time.Duration(cfg.Interval) * time.Second)
+		for range ticker.C {
+			checkTrafficQuotas()
+		}
+	}()
+
+	go func() {
+		pruneQuotaEvents()
+		ticker := time.NewTicker(1 * time.Hour)
+		for range ticker.C {
+			pruneQuotaEvents()
+		}
+	}()
+}
+
+// QuotaCycle 计算账单日为 anchorDay 时 now 所在周期的起止时间，账单日超过当月天数时取月末
+func QuotaCycle(anchorDay int, now time.Time) (time.Time, time.Time) {
+	anchor := func(year int, month time.Month) time.Time {
+		day := anchorDay
+		if last := time.Date(year, month+1, 0, 0, 0, 0, 0, now.Location()).Day(); day > last {
+			day = last
+		}
+		return time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	}
+
+	start := anchor(now.Year(), now.Month())
+	if now.Before(start) {
+		prev := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, now.Location())
+		start = anchor(prev.Year(), prev.Month())
+	}
+	next := time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, now.Location())
+	return start, anchor(next.Year(), next.Month())
+}
+
+// EffectiveQuota 返回容器实际生效的账单日和超额处理方式
+func EffectiveQuota(q *models.TrafficQuota) (int, string) {
+	cfg := getQuotaConfig()
+	anchorDay, action := cfg.AnchorDay, cfg.OverQuotaAction
+	if q.AnchorDay > 0 {
+		anchorDay = q.AnchorDay
+	}
+	if q.Action != "" {
+		action = q.Action
+	}
+	return anchorDay, action
+}
+
+// LoadTrafficQuota 读取容器配额状态，不存在时按当前配置初始化周期并保存
+func LoadTrafficQuota(nodeID uint, hostname string) models.TrafficQuota {
+	var quota models.TrafficQuota
+	if err := database.DB.Where("node_id = ? AND hostname = ?", nodeID, hostname).First(&quota).Error; err == nil {
+		return quota
+	}
+	quota = models.TrafficQuota{NodeID: nodeID, Hostname: hostname}
+	anchorDay, _ := EffectiveQuota(&quota)
+	quota.CycleStart, quota.NextReset = QuotaCycle(anchorDay, time.Now())
+	database.DB.Create(&quota)
+	return quota
+}
+
+func checkTrafficQuotas() {
+	var nodes []models.Node
+	database.DB.Where("status IN ?", []string{"active", "degraded"}).Find(&nodes)
+
+	for _, node := range nodes {
+		if NodeCircuitOpen(node.ID) {
+			continue
+		}
+
+		var containers []models.ContainerCache
+		database.DB.Where("node_id = ?", node.ID).Find(&containers)
+		if len(containers) == 0 {
+			continue
+		}
+
+		ctx := lxdapi.WithClass(context.Background(), lxdapi.ClassSync)
+		client := NodeClient(node)
+		for i := range containers {
+			checkContainerQuota(ctx, client, node, &containers[i])
+		}
+	}
+}
+
+func checkContainerQuota(ctx context.Context, client *lxdapi.Client, node models.Node, cache *models.ContainerCache) {
+	quota := LoadTrafficQuota(node.ID, cache.Hostname)
+	now := time.Now()
+
+	if !now.Before(quota.NextReset) {
+		resetQuotaCycle(ctx, client, node, &quota, cache, now)
+		return
+	}
+
+	if cache.TrafficLimit <= 0 {
+		return
+	}
+	limit := uint64(cache.TrafficLimit) * gigabyte
+	percent := int(cache.TrafficTotal * 100 / limit)
+
+	thresholds := append([]int(nil), getQuotaConfig().WarnThresholds...)
+	sort.Ints(thresholds)
+	level := 0
+	for _, t := range thresholds {
+		if percent >= t {
+			level = t
+		}
+	}
+	if level > quota.WarnedLevel {
+		log.Printf("[QUOTA] 容器 %s (节点 %s) 流量已用 %d%%: %.2f GB / %d GB",
+			cache.Hostname, node.Name, percent, float64(cache.TrafficTotal)/gigabyte, cache.TrafficLimit)
+		recordQuotaEvent(cache, "warn", level, nil)
+		quota.WarnedLevel = level
+		database.DB.Model(&quota).Update("warned_level", level)
+	}
+
+	_, action := EffectiveQuota(&quota)
+	if percent >= 100 && action == "suspend" && !quota.SuspendedByQuota {
+		_, err := client.Suspend(ctx, cache.Hostname)
+		recordQuotaEvent(cache, "suspend", 100, err)
+		if err != nil {
+			log.Printf("[QUOTA] 暂停超额容器 %s 失败: %v", cache.Hostname, err)
+			return
+		}
+		log.Printf("[QUOTA] 容器 %s 流量超额，已暂停", cache.Hostname)
+		database.DB.Model(&quota).Update("suspended_by_quota", true)
+	}
+}
+
+// resetQuotaCycle 到达账单日时重置节点流量计数，并恢复本周期因超额暂停的容器
+func resetQuotaCycle(ctx context.Context, client *lxdapi.Client, node models.Node, quota *models.TrafficQuota, cache *models.ContainerCache, now time.Time) {
+	_, err := client.ResetTraffic(ctx, cache.Hostname)
+	recordQuotaEvent(cache, "reset", 0, err)
+	if err != nil {
+		log.Printf("[QUOTA] 容器 %s (节点 %s) 周期流量重置失败: %v", cache.Hostname, node.Name, err)
+		return
+	}
+	MarkTrafficReset(node.ID, cache.Hostname)
+	database.DB.Model(&models.ContainerCache{}).Where("id = ?", cache.ID).
+		Updates(map[string]interface{}{"traffic_total": 0, "traffic_in": 0, "traffic_out": 0})
+	cache.TrafficTotal = 0
+
+	if quota.SuspendedByQuota {
+		_, err := client.Unsuspend(ctx, cache.Hostname)
+		recordQuotaEvent(cache, "unsuspend", 0, err)
+		if err != nil {
+			log.Printf("[QUOTA] 恢复容器 %s 失败: %v", cache.Hostname, err)
+		} else {
+			quota.SuspendedByQuota = false
+		}
+	}
+
+	anchorDay, _ := EffectiveQuota(quota)
+	quota.CycleStart, quota.NextReset = QuotaCycle(anchorDay, now)
+	quota.WarnedLevel = 0
+	quota.LastResetAt = &now
+	database.DB.Save(quota)
+
+	log.Printf("[QUOTA] 容器 %s (节点 %s) 已进入新周期，下次重置 %s",
+		cache.Hostname, node.Name, quota.NextReset.Format("2006-01-02"))
+}
+
+// ClearQuotaWarnings 手动重置流量后清除本周期告警级别，用量再次达到阈值时重新告警
+func ClearQuotaWarnings(nodeID uint, hostname string) {
+	database.DB.Model(&models.TrafficQuota{}).Where("node_id = ? AND hostname = ?", nodeID, hostname).
+		Update("warned_level", 0)
+}
+
+func recordQuotaEvent(cache *models.ContainerCache, action string, threshold int, err error) {
+	event := models.TrafficQuotaEvent{
+		NodeID:     cache.NodeID,
+		Hostname:   cache.Hostname,
+		Action:     action,
+		Threshold:  threshold,
+		UsageBytes: cache.TrafficTotal,
+		LimitBytes: uint64(cache.TrafficLimit) * gigabyte,
+		Success:    err == nil,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	database.DB.Create(&event)
+}
+
+func pruneQuotaEvents() {
+	cutoff := time.Now().AddDate(0, 0, -getQuotaConfig().EventRetention)
+	result := database.DB.Where("created_at < ?", cutoff).Delete(&models.TrafficQuotaEvent{})
+	if result.Error != nil {
+		log.Printf("[QUOTA] 清理配额事件失败: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("[QUOTA] 清理 %d 条过期配额事件", result.RowsAffected)
+	}
+}
+
+func getQuotaConfig() config.QuotaConfig {
+	cfg := config.QuotaConfig{
+		Interval:        300,
+		AnchorDay:       1,
+		WarnThresholds:  []int{80, 100},
+		OverQuotaAction: "none",
+		EventRetention:  90,
+	}
+	if config.AppConfig == nil {
+		return cfg
+	}
+	q := config.AppConfig.Quota
+	cfg.Enabled = q.Enabled
+	if q.Interval > 0 {
+		cfg.Interval = q.Interval
+	}
+	if q.AnchorDay > 0 {
+		cfg.AnchorDay = q.AnchorDay
+	}
+	if len(q.WarnThresholds) > 0 {
+		cfg.WarnThresholds = q.WarnThresholds
+	}
+	if q.OverQuotaAction != "" {
+		cfg.OverQuotaAction = q.OverQuotaAction
+	}
+	if q.EventRetention > 0 {
+		cfg.EventRetention = q.EventRetention
+	}
+	return cfg
+}
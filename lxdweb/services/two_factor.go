@@ -0,0 +1,124 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"lxdweb/database"
+	"lxdweb/models"
+	"lxdweb/utils"
+
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount 每次生成的恢复码数量
+const recoveryCodeCount = 10
+
+// TwoFactorEnforced 是否要求所有管理员启用两步验证
+func TwoFactorEnforced() bool {
+	return GetBoolSetting(SettingEnforce2FA, false)
+}
+
+// EnableTwoFactor 保存已校验的密钥并生成新的恢复码，返回明文恢复码（仅此一次）
+func EnableTwoFactor(admin *models.Admin, secret string, step int64) ([]string, error) {
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(admin).Updates(map[string]interface{}{
+			"totp_secret":    secret,
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error
+		if err != nil {
+			return err
+		}
+		codes, err = issueRecoveryCodes(tx, admin.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	admin.TOTPSecret = secret
+	admin.TOTPEnabled = true
+	admin.TOTPLastStep = step
+	return codes, nil
+}
+
+// DisableTwoFactor 关闭两步验证并删除恢复码
+func DisableTwoFactor(adminID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Admin{}).Where("id = ?", adminID).Updates(map[string]interface{}{
+			"totp_secret":    "",
+			"totp_enabled":   false,
+			"totp_last_step": 0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("admin_id = ?", adminID).Delete(&models.AdminRecoveryCode{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes 作废旧恢复码并生成新的一组
+func RegenerateRecoveryCodes(adminID uint) ([]string, error) {
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = issueRecoveryCodes(tx, adminID)
+		return err
+	})
+	return codes, err
+}
+
+// RemainingRecoveryCodes 未使用的恢复码数量
+func RemainingRecoveryCodes(adminID uint) int64 {
+	var count int64
+	database.DB.Model(&models.AdminRecoveryCode{}).Where("admin_id = ? AND used_at IS NULL", adminID).Count(&count)
+	return count
+}
+
+// VerifySecondFactor 校验 TOTP 验证码或恢复码，两者都只能使用一次
+func VerifySecondFactor(admin *models.Admin, code string) bool {
+	if !admin.TOTPEnabled || code == "" {
+		return false
+	}
+
+	if step, ok := utils.VerifyTOTP(admin.TOTPSecret, code, time.Now(), admin.TOTPLastStep); ok {
+		// 条件更新保证并发请求中同一时间步只有一个成功
+		result := database.DB.Model(&models.Admin{}).
+			Where("id = ? AND totp_last_step < ?", admin.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return false
+		}
+		admin.TOTPLastStep = step
+		return true
+	}
+
+	result := database.DB.Model(&models.AdminRecoveryCode{}).
+		Where("admin_id = ? AND code_hash = ? AND used_at IS NULL", admin.ID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+func issueRecoveryCodes(tx *gorm.DB, adminID uint) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("admin_id = ?", adminID).Delete(&models.AdminRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		row := models.AdminRecoveryCode{AdminID: adminID, CodeHash: hashRecoveryCode(code)}
+		if err := tx.Create(&row).Error; err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(utils.NormalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
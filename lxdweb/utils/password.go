@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+// passwordAlphabet 去掉了易混淆的 0/O/1/l/I
+const passwordAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// GeneratePassword 生成包含大小写字母和数字的随机密码
+func GeneratePassword(length int) (string, error) {
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	buf := make([]byte, length)
+	for {
+		for i := range buf {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", err
+			}
+			buf[i] = passwordAlphabet[n.Int64()]
+		}
+		password := string(buf)
+		if strings.ContainsAny(password, "abcdefghijkmnpqrstuvwxyz") &&
+			strings.ContainsAny(password, "ABCDEFGHJKLMNPQRSTUVWXYZ") &&
+			strings.ContainsAny(password, "23456789") {
+			return password, nil
+		}
+	}
+}